@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"gcs_sync/internal/config"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a shell completion script for gcs-sync.
+
+Examples:
+  source <(gcs-sync completion bash)
+  gcs-sync completion zsh > "${fpath[1]}/_gcs-sync"
+  gcs-sync completion fish > ~/.config/fish/completions/gcs-sync.fish
+  gcs-sync completion powershell | Out-String | Invoke-Expression
+
+Rule names for --rule are completed from the file given with --config.`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	DisableFlagsInUseLine: true,
+	RunE:                  completion,
+}
+
+// init registers the completion command in place of cobra's default one.
+func init() {
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(completionCmd)
+}
+
+// completion writes the completion script for the requested shell to stdout.
+//
+// Parameters:
+//   - cmd: The Cobra command, used for output.
+//   - args: The shell name.
+//
+// Returns:
+//   - error: An error if the shell is not supported or the script could not be written.
+func completion(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	switch args[0] {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(out, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(out)
+	case "fish":
+		return rootCmd.GenFishCompletion(out, true)
+	case "powershell":
+		return rootCmd.GenPowerShellCompletionWithDesc(out)
+	default:
+		return fmt.Errorf("unsupported shell %q", args[0])
+	}
+}
+
+// completeRules suggests the IDs of the rules in the configuration file for --rule flags.
+// Without a readable configuration nothing is suggested.
+func completeRules(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if _, err := os.Stat(cfgPath); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var ids []string
+	for _, r := range cfg.Sync {
+		if id := r.ID(); strings.HasPrefix(id, toComplete) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
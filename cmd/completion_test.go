@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		shell   string
+		want    string
+		wantErr bool
+	}{
+		{"bash", "__start_gcs-sync", false},
+		{"zsh", "#compdef gcs-sync", false},
+		{"fish", "complete -c gcs-sync", false},
+		{"powershell", "Register-ArgumentCompleter", false},
+		{"tcsh", "", true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		completionCmd.SetOut(&out)
+		err := completion(completionCmd, []string{tt.shell})
+		if (err != nil) != tt.wantErr || !strings.Contains(out.String(), tt.want) {
+			t.Errorf("completion %s = %v, output without %q", tt.shell, err, tt.want)
+		}
+	}
+}
+
+func TestCompleteRules(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	doc := `sync:
+  - {name: photos, enabled: false, src: /srv/photos, dst: gs://b/photos, debounce_window: 1s}
+  - {name: podcasts, enabled: false, src: /srv/podcasts, dst: gs://b/podcasts, debounce_window: 1s}
+  - {name: videos, enabled: false, src: /srv/videos, dst: gs://b/videos, debounce_window: 1s}
+`
+	if err := os.WriteFile(cfg, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("sync: [{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := cfgPath
+	t.Cleanup(func() { cfgPath = old })
+
+	tests := []struct {
+		config, prefix string
+		want           []string
+	}{
+		{cfg, "", []string{"photos", "podcasts", "videos"}},
+		{cfg, "p", []string{"photos", "podcasts"}},
+		{cfg, "pod", []string{"podcasts"}},
+		{cfg, "x", nil},
+		{invalid, "", nil},
+		{filepath.Join(dir, "missing.yaml"), "", nil},
+	}
+	for _, tt := range tests {
+		cfgPath = tt.config
+		got, directive := completeRules(nil, nil, tt.prefix)
+		if !reflect.DeepEqual(got, tt.want) || directive != cobra.ShellCompDirectiveNoFileComp {
+			t.Errorf("completeRules(%s, %q) = %q, %d, want %q without file completion", filepath.Base(tt.config), tt.prefix, got, directive, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"gcs_sync/internal/watcher"
+	"go.uber.org/fx"
+	"time"
+)
+
+// heartbeat calls m.Heartbeat every interval while the daemon runs, so that log-based
+// or StatsD-based monitoring can tell the daemon and its rules are alive without the
+// status API. A zero interval disables it.
+//
+// Parameters:
+//   - lc: The fx.Lifecycle the ticker is tied to.
+//   - m: The Manager whose rules report in.
+//   - interval: The time between two heartbeats.
+func heartbeat(lc fx.Lifecycle, m *watcher.Manager, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(stopped)
+				t := time.NewTicker(interval)
+				defer t.Stop()
+				for {
+					select {
+					case <-t.C:
+						m.Heartbeat()
+					case <-done:
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			<-stopped
+			return nil
+		},
+	})
+}
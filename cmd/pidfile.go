@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+	"gcs_sync/internal/pidfile"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+)
+
+// pidFile writes the daemon's PID file on startup and removes it on shutdown.
+// Startup fails if the file names another gcs-sync that is still running.
+// An empty path disables the PID file.
+//
+// Parameters:
+//   - lc: The fx.Lifecycle the file is tied to.
+//   - log: A logrus.Logger for reporting stale files and cleanup failures.
+//   - path: The PID file location.
+func pidFile(lc fx.Lifecycle, log *logrus.Logger, path string) {
+	if path == "" {
+		return
+	}
+	entry := log.WithField("pid_file", path)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return pidfile.Acquire(path, entry)
+		},
+		OnStop: func(context.Context) error {
+			if err := pidfile.Release(path); err != nil {
+				entry.WithError(err).Warn("failed to remove pid file")
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gcs_sync/internal/audit"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/logging"
+	"gcs_sync/internal/watcher"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var (
+	pruneRule      string
+	pruneApply     bool
+	pruneYes       bool
+	pruneThreshold int
+	pruneCmd       = &cobra.Command{
+		Use:   "prune",
+		Short: "List (and optionally delete) destination objects missing from the source",
+		Args:  cobra.NoArgs,
+		RunE:  prune,
+	}
+)
+
+// init registers the prune command and its flags.
+func init() {
+	pruneCmd.Flags().StringVarP(&pruneRule, "rule", "r", "", "rule name (or src) to prune")
+	pruneCmd.Flags().BoolVar(&pruneApply, "apply", false, "delete the listed objects instead of only listing them")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "do not ask for confirmation")
+	pruneCmd.Flags().IntVar(&pruneThreshold, "confirm-threshold", config.DefaultDeleteThreshold,
+		"ask for confirmation when more objects than this would be deleted")
+	_ = pruneCmd.MarkFlagRequired("rule")
+	_ = pruneCmd.RegisterFlagCompletionFunc("rule", completeRules)
+	rootCmd.AddCommand(pruneCmd)
+}
+
+// prune lists destination objects that have no counterpart in the rule's source.
+//
+// Orphans are found with a `gsutil rsync -n -d` dry-run using the rule's sync options (see
+// watcher.Pruner), so exactly the objects a deleting sync would remove are reported. Without --apply nothing is modified; with
+// --apply the objects are removed, asking for confirmation when their number exceeds
+// --confirm-threshold unless --yes is given.
+//
+// Parameters:
+//   - cmd: The Cobra command, used for output.
+//   - _ []string: Unused positional arguments.
+//
+// Returns:
+//   - error: An error if the rule is unknown, gsutil fails, the deletion is declined or it
+//     would run as root without allow_root_delete.
+func prune(cmd *cobra.Command, _ []string) error {
+	cfg, err := setup()
+	if err != nil {
+		return err
+	}
+	rule, ok := cfg.Rule(pruneRule)
+	if !ok {
+		return fmt.Errorf("unknown rule %q", pruneRule)
+	}
+	p, err := watcher.NewPruner(cfg, rule)
+	if err != nil {
+		return err
+	}
+	orphans, err := p.Orphans(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, o := range orphans {
+		fmt.Fprintln(out, o)
+	}
+	fmt.Fprintf(out, "%d orphaned object(s)\n", len(orphans))
+	if !pruneApply || len(orphans) == 0 {
+		if len(orphans) > 0 {
+			fmt.Fprintln(out, "re-run with --apply to delete them")
+		}
+		return nil
+	}
+
+	if p.DryRun() {
+		fmt.Fprintf(out, "dry-run: would delete %d object(s)\n", len(orphans))
+		return nil
+	}
+	if len(orphans) > pruneThreshold && !pruneYes {
+		fmt.Fprintf(out, "Delete %d objects from %s? [y/N] ", len(orphans), p.Dst())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return errors.New("prune aborted")
+		}
+	}
+	auditLog, err := audit.Open(cfg.AuditLog)
+	if err != nil {
+		return fmt.Errorf("audit_log: %w", err)
+	}
+	defer auditLog.Close()
+	log := logging.L().WithField("rule", rule.ID())
+	onRemove := func(url string) {
+		if err := auditLog.Deleted(rule.ID(), "prune", url); err != nil {
+			log.WithError(err).Errorf("failed to write audit entry for deleted %s", url)
+		}
+	}
+	if err := p.Remove(cmd.Context(), orphans, onRemove); err != nil {
+		return fmt.Errorf("deleting orphans: %w", err)
+	}
+	fmt.Fprintf(out, "deleted %d object(s)\n", len(orphans))
+	return nil
+}
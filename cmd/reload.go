@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/watcher"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadDelay debounces configuration file events, so that an editor writing the file
+// in several steps triggers a single reload of the complete file.
+const reloadDelay = time.Second
+
+// configWatcher reloads the configuration into m whenever the file at path changes.
+//
+// The file's directory is watched rather than the file itself, so that editors and
+// Kubernetes ConfigMaps replacing the file by a rename are noticed as well. Events are
+// debounced by reloadDelay and a file whose content did not change is ignored. A
+// configuration that fails to parse or validate is logged and ignored; the running rules
+// stay as they are until a valid one is written.
+//
+// Parameters:
+//   - lc: The fx.Lifecycle the watcher is tied to.
+//   - m: The Manager receiving the new configurations.
+//   - log: A logrus.Logger for reload results.
+//   - path: The configuration file.
+//   - enabled: Whether to watch at all (--watch-config).
+func configWatcher(lc fx.Lifecycle, m *watcher.Manager, log *logrus.Logger, path string, enabled bool) {
+	if !enabled {
+		return
+	}
+	entry := log.WithField("config", path)
+	var (
+		w     *fsnotify.Watcher
+		mu    sync.Mutex // serialises reloads
+		timer *time.Timer
+		last  []byte // the content of the configuration running now
+	)
+	reload := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			entry.WithError(err).Warn("failed to read configuration, keeping the running one")
+			return
+		}
+		if bytes.Equal(data, last) {
+			return
+		}
+		cfg, err := config.Parse(data, strings.EqualFold(filepath.Ext(path), ".json"))
+		if err == nil {
+			err = checkConfirm(cfg)
+		}
+		if err != nil {
+			entry.WithError(err).Error("ignoring invalid configuration, keeping the running one")
+			return
+		}
+		if dryRun {
+			cfg.ForceDryRun()
+		}
+		if backendName != "" {
+			cfg.Backend = backendName
+		}
+		if err := m.Reload(cfg); err != nil {
+			entry.WithError(err).Error("failed to reload configuration, keeping the running one")
+			return
+		}
+		last = data
+	}
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			var err error
+			if last, err = os.ReadFile(path); err != nil {
+				return err
+			}
+			if w, err = fsnotify.NewWatcher(); err != nil {
+				return err
+			}
+			if err = w.Add(filepath.Dir(path)); err != nil {
+				_ = w.Close()
+				return err
+			}
+			timer = time.AfterFunc(time.Hour, reload)
+			timer.Stop()
+			go func() {
+				for {
+					select {
+					case _, ok := <-w.Events:
+						if !ok {
+							return
+						}
+						timer.Reset(reloadDelay)
+					case err, ok := <-w.Errors:
+						if !ok {
+							return
+						}
+						entry.WithError(err).Warn("configuration watcher error")
+					}
+				}
+			}()
+			entry.Info("watching configuration for changes")
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			timer.Stop()
+			return w.Close()
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/watcher"
+	"github.com/sirupsen/logrus/hooks/test"
+	"go.uber.org/fx/fxtest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestConfigWatcherDebounces writes the configuration several times in a burst and checks
+// that it is reloaded once, with the last content, and that invalid content is ignored.
+func TestConfigWatcherDebounces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	doc := func(i int) []byte {
+		return []byte(fmt.Sprintf("# revision %d\nsync: [{enabled: false, src: /srv/data, dst: gs://b, debounce_window: 1s}]\n", i))
+	}
+	if err := os.WriteFile(path, doc(0), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logger, hook := test.NewNullLogger()
+	cfg, err := config.Parse(doc(0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := watcher.NewManager(cfg, logger, nil)
+	lc := fxtest.NewLifecycle(t)
+	configWatcher(lc, m, logger, path, true)
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	reloads := func() (ok, invalid int) {
+		for _, e := range hook.AllEntries() {
+			switch e.Message {
+			case "configuration reloaded, no rule changed":
+				ok++
+			case "ignoring invalid configuration, keeping the running one":
+				invalid++
+			}
+		}
+		return ok, invalid
+	}
+	settle := func() { time.Sleep(reloadDelay + 500*time.Millisecond) }
+
+	for i := 1; i <= 5; i++ {
+		if err := os.WriteFile(path, doc(i), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	settle()
+	if ok, invalid := reloads(); ok != 1 || invalid != 0 {
+		t.Fatalf("a burst of writes reloaded %d times (%d invalid), want once", ok, invalid)
+	}
+
+	if err := os.WriteFile(path, []byte("sync: [{enabled: true, src: /srv/data}]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settle()
+	if ok, invalid := reloads(); ok != 1 || invalid != 1 {
+		t.Errorf("after an invalid write: %d reloads, %d rejected, want 1 and 1", ok, invalid)
+	}
+}
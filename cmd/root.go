@@ -3,16 +3,39 @@ package cmd
 import (
 	"fmt"
 	"gcs_sync/internal/config"
+	"gcs_sync/internal/gsutil"
 	"gcs_sync/internal/logging"
+	"gcs_sync/internal/metrics"
+	"gcs_sync/internal/server"
+	"gcs_sync/internal/versions"
 	"gcs_sync/internal/watcher"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"go.uber.org/fx"
+	"os/exec"
+	"strings"
+	"time"
 )
 
 var (
-	cfgPath  string
-	logLevel string
-	rootCmd  = &cobra.Command{
+	cfgPath      string
+	logLevel     string
+	logFormat    string
+	verbose      int
+	logLevelFlag *pflag.Flag // tells whether --log-level was given, which wins over -v
+	noColor      bool
+	dryRun       bool
+	pprofAddr    string
+	statusAddr   string
+	metricsAddr  string
+	healthAddr   string
+	maxRuntime   time.Duration
+	heartbeatInt time.Duration
+	pidPath      string
+	watchConfig  bool
+	backendName  string
+	rootCmd      = &cobra.Command{
 		Use:   "gcs-sync",
 		Short: "Bi-directional Google Cloud Storage synchronizer",
 		RunE:  run,
@@ -20,14 +43,52 @@ var (
 )
 
 // init initializes the command-line flags for the root command.
-// It sets up two persistent flags:
+// It sets up the following persistent flags:
 //   - config: Specifies the path to the YAML configuration file.
 //   - log-level: Sets the logging level for the application.
+//   - log-format: Selects text or JSON log lines.
+//   - verbose: Repeatable shorthand for debug (-v) and trace (-vv) logging; log-level wins when both are given.
+//   - no-color: Disables colored log output (also honoured via NO_COLOR).
+//   - dry-run: Runs every rule in dry-run mode, whatever the configuration says.
+//   - backend: Selects the CLI running rsync, overriding the configuration's backend.
+//
+// The daemon-only profile-addr, status-addr, metrics-addr and health-addr flags enable the
+// pprof, status, Prometheus and health probe endpoints;
+// max-runtime stops the daemon gracefully after the given duration; pid-file writes the
+// process ID for service managers; watch-config reloads the configuration when its file changes;
+// heartbeat-interval periodically reports that the daemon and its rules are alive.
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgPath, "config", "c",
 		"/app/settings/config.yaml", "path to YAML configuration")
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l",
 		"info", "log level (trace|debug|info|warn|error)")
+	rootCmd.PersistentFlags().CountVarP(&verbose, "verbose", "v",
+		"log more: -v for debug, -vv for trace (ignored when --log-level is given)")
+	logLevelFlag = rootCmd.PersistentFlags().Lookup("log-level")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatText,
+		"log format (text|json); json writes one object per line for log shippers")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false,
+		"disable colored log output (same as setting NO_COLOR)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false,
+		"only report what would be transferred or deleted, for every rule (same as dry_run: true)")
+	rootCmd.PersistentFlags().StringVar(&backendName, "backend", "",
+		"CLI running rsync (gsutil|gcloud), overriding the configuration's backend")
+	rootCmd.Flags().StringVar(&pprofAddr, "profile-addr", "",
+		"expose net/http/pprof on this address, e.g. localhost:6060 (disabled when empty)")
+	rootCmd.Flags().StringVar(&statusAddr, "status-addr", "",
+		"serve the status API on this address, e.g. localhost:8080 (disabled when empty)")
+	rootCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "",
+		"serve Prometheus metrics at /metrics on this address, e.g. :9090 (disabled when empty)")
+	rootCmd.Flags().StringVar(&healthAddr, "health-addr", "",
+		"serve /healthz and /readyz probes on this address, e.g. :8081 (disabled when empty)")
+	rootCmd.Flags().DurationVar(&maxRuntime, "max-runtime", 0,
+		"shut down gracefully after running this long, e.g. 24h (disabled when 0)")
+	rootCmd.Flags().StringVar(&pidPath, "pid-file", "",
+		"write the process ID to this file while running; refuses to start if it names a live process")
+	rootCmd.Flags().BoolVar(&watchConfig, "watch-config", false,
+		"reload the configuration when its file changes, starting, stopping and restarting only the affected rules")
+	rootCmd.Flags().DurationVar(&heartbeatInt, "heartbeat-interval", 0,
+		"log a heartbeat per rule with the time since its last sync, and send it as a metric, this often, e.g. 1m (disabled when 0)")
 }
 
 // run is the main execution function for the gcs-sync command.
@@ -41,27 +102,142 @@ func init() {
 // Returns:
 //   - error: An error if any step in the process fails, nil otherwise.
 func run(_ *cobra.Command, _ []string) error {
-	// Configure global logger
-	logging.Init(logLevel)
-
-	// Load config early so startup fails fast if YAML is invalid
-	cfg, err := config.Load(cfgPath)
+	cfg, err := setup()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
+	}
+	if err := checkVersions(cfg); err != nil {
+		return err
+	}
+	if err := checkConfirm(cfg); err != nil {
+		return err
 	}
 
 	// Build Fx app
 	app := fx.New(
+		fxLogger(),
 		fx.Supply(cfg),
 		fx.Supply(logging.L()),
+		fx.Provide(server.New),
+		fx.Provide(prometheus),
+		fx.Provide(metrics.New),
+		fx.Provide(watcher.NewManager),
+		fx.Invoke(func(r *server.Registry) { server.Pprof(r, pprofAddr) }),
+		fx.Invoke(func(r *server.Registry, p *metrics.Prometheus) {
+			if p != nil {
+				r.Handle(metricsAddr, "/metrics", p)
+			}
+		}),
+		fx.Invoke(func(r *server.Registry, m *watcher.Manager) {
+			r.Handle(statusAddr, "/status/watched", m.WatchedHandler())
+			r.Handle(statusAddr, "/status/latency", m.LatencyHandler())
+			r.Handle(statusAddr, "/status/confirm", m.ConfirmHandler())
+			r.Handle(statusAddr, "/status/reconcile", m.ReconcileHandler())
+			r.Handle(healthAddr, "/healthz", m.HealthHandler())
+			r.Handle(healthAddr, "/readyz", m.ReadyHandler())
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, log *logrus.Logger) { pidFile(lc, log, pidPath) }),
+		fx.Invoke(server.Register),
 		fx.Invoke(watcher.StartAll),
+		fx.Invoke(func(lc fx.Lifecycle, m *watcher.Manager, log *logrus.Logger) {
+			configWatcher(lc, m, log, cfgPath, watchConfig)
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, sd fx.Shutdowner, log *logrus.Logger) {
+			watchdog(lc, sd, log, maxRuntime)
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, m *watcher.Manager) { heartbeat(lc, m, heartbeatInt) }),
 	)
 
-	// Blocks until SIGINT / SIGTERM
+	// Blocks until SIGINT / SIGTERM (or --max-runtime)
 	app.Run()
 
 	return nil
 }
 
+// setup configures the global logger from the persistent flags and loads the configuration.
+// Every command calls it first so that startup fails fast if the YAML is invalid.
+//
+// Returns:
+//   - *config.Config: The loaded configuration.
+//   - error: An error if the configuration could not be loaded.
+func setup() (*config.Config, error) {
+	level := logLevel
+	if verbose > 0 && !logLevelFlag.Changed {
+		level = logging.VerboseLevel(verbose).String()
+	}
+	if err := logging.Init(level, logFormat, noColor); err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if dryRun {
+		cfg.ForceDryRun()
+		logging.L().Warn("dry-run: nothing will be transferred or deleted")
+	}
+	if backendName != "" {
+		if _, err := gsutil.NewBackend(backendName); err != nil {
+			return nil, fmt.Errorf("--backend: %w", err)
+		}
+		cfg.Backend = backendName
+	}
+	return cfg, nil
+}
+
+// prometheus returns the Prometheus exporter when --metrics-addr is set, and nil otherwise.
+func prometheus() *metrics.Prometheus {
+	if metricsAddr == "" {
+		return nil
+	}
+	return metrics.NewPrometheus()
+}
+
+// fxLogger keeps fx's plain-text startup lines out of JSON logs, which log shippers
+// could not parse.
+func fxLogger() fx.Option {
+	if strings.EqualFold(logFormat, logging.FormatJSON) {
+		return fx.NopLogger
+	}
+	return fx.Options()
+}
+
+// checkConfirm rejects rules with initial_confirm when the status API that confirms
+// them is disabled.
+func checkConfirm(cfg *config.Config) error {
+	if statusAddr != "" {
+		return nil
+	}
+	for _, r := range cfg.Sync {
+		if r.Enabled && r.InitialConfirm {
+			return fmt.Errorf("rule %q: initial_confirm needs the status API (--status-addr)", r.ID())
+		}
+	}
+	return nil
+}
+
+// checkVersions verifies the installed gsutil and gcloud against the configured minimums.
+// Outdated tools are logged, or fail startup with strict_versions. A configured
+// gsutil_path must exist in any case, as a typo there would fail every sync.
+func checkVersions(cfg *config.Config) error {
+	log := logging.L().WithField("check", "versions")
+	gsutilTool := versions.Gsutil
+	if cfg.GsutilPath != "" {
+		if _, err := exec.LookPath(cfg.GsutilPath); err != nil {
+			return fmt.Errorf("gsutil_path: %w", err)
+		}
+		gsutilTool.Name = cfg.GsutilPath
+	}
+	minGsutil := versions.DefaultMinGsutil
+	if cfg.MinGsutilVersion != nil {
+		minGsutil = *cfg.MinGsutilVersion
+	}
+	if err := versions.Check(gsutilTool, minGsutil, cfg.StrictVersions, log); err != nil {
+		return err
+	}
+	return versions.Check(versions.Gcloud, cfg.MinGcloudVersion, cfg.StrictVersions, log)
+}
+
 // Execute lets main.go launch the CLI.
 func Execute() error { return rootCmd.Execute() }
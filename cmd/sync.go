@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"gcs_sync/internal/logging"
+	"gcs_sync/internal/metrics"
+	"gcs_sync/internal/watcher"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var (
+	syncSince time.Duration
+	syncCmd   = &cobra.Command{
+		Use:          "sync",
+		Short:        "Sync every enabled rule once and exit, e.g. from cron",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         syncAll,
+	}
+)
+
+// init registers the sync command and its flags.
+func init() {
+	syncCmd.Flags().DurationVar(&syncSince, "since", 0,
+		"only push files modified within this duration, e.g. 24h, overriding exclude_older_than (disabled when 0)")
+	rootCmd.AddCommand(syncCmd)
+}
+
+// syncAll runs every enabled rule once, in its configured directions, without starting
+// any watcher, and exits non-zero if any rule failed. --dry-run is honoured like for the
+// daemon. SIGINT and SIGTERM kill the running transfers.
+//
+// With --since, pushes only consider files modified within that duration, like a rule's
+// exclude_older_than; incremental cron backups use it to skip comparing old files.
+//
+// Parameters:
+//   - cmd: The Cobra command, providing the context.
+//   - _ []string: Unused positional arguments.
+//
+// Returns:
+//   - error: An error if the configuration is invalid or any rule failed.
+func syncAll(cmd *cobra.Command, _ []string) error {
+	cfg, err := setup()
+	if err != nil {
+		return err
+	}
+	if err := checkVersions(cfg); err != nil {
+		return err
+	}
+	rec, err := metrics.New(cfg, nil)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	m := watcher.NewManager(cfg, logging.L(), rec)
+	m.Since = syncSince
+	return m.RunOnce(ctx)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/gsutil"
+	"gcs_sync/internal/util"
+	"github.com/spf13/cobra"
+	"os/exec"
+	"strings"
+)
+
+var validateCmd = &cobra.Command{
+	Use:          "validate",
+	Short:        "Check the configuration and print a per-rule summary without starting any watcher",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         validate,
+}
+
+// init registers the validate command.
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+// validate loads and validates the configuration, checks that gsutil is on PATH and prints
+// each rule's directions and expanded locations. It neither watches files nor runs gsutil,
+// which makes it suitable for checking a configuration change in CI.
+//
+// Parameters:
+//   - cmd: The Cobra command, used for output.
+//   - _ []string: Unused positional arguments.
+//
+// Returns:
+//   - error: An error describing the first problem found, making the process exit with 1.
+func validate(cmd *cobra.Command, _ []string) error {
+	cfg, err := setup() // Load runs Config.Validate
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(cfg.Gsutil()); err != nil {
+		return fmt.Errorf("gsutil not found: %w", err)
+	}
+	if cfg.Backend == gsutil.BackendGcloud {
+		if _, err := exec.LookPath("gcloud"); err != nil {
+			return fmt.Errorf("gcloud not found on PATH, required by backend: gcloud: %w", err)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	for i, r := range cfg.Sync {
+		state := "enabled"
+		if !r.Enabled {
+			state = "disabled"
+		}
+		dirs := make([]string, 0, len(r.Directions))
+		for _, d := range r.Directions {
+			dirs = append(dirs, d.String())
+		}
+		if len(dirs) == 0 {
+			dirs = append(dirs, config.LocalToRemote.String())
+		}
+		src, missing := util.ExpandEnv(r.Src)
+		dst, missingDst := util.ExpandEnv(r.Dst)
+		fmt.Fprintf(out, "rule %d (%s): %s, %s\n", i, r.ID(), state, strings.Join(dirs, ", "))
+		fmt.Fprintf(out, "  src: %s\n", util.Expand(src))
+		fmt.Fprintf(out, "  dst: %s\n", dst)
+		for _, name := range append(missing, missingDst...) {
+			fmt.Fprintf(out, "  warning: environment variable %s is not set\n", name)
+		}
+	}
+	fmt.Fprintf(out, "%s: %d rule(s) OK\n", cfgPath, len(cfg.Sync))
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gsutil is a shell script")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "gsutil"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	t.Setenv("BUCKET", "backups")
+	t.Setenv("GCS_SYNC_UNSET", "")
+	os.Unsetenv("GCS_SYNC_UNSET")
+	old := cfgPath
+	t.Cleanup(func() { cfgPath = old })
+
+	rules := `sync:
+  - name: photos
+    enabled: true
+    src: ` + src + `
+    dst: gs://${BUCKET}/photos
+    debounce_window: 1s
+  - enabled: false
+    src: /srv/archive
+    dst: gs://${GCS_SYNC_UNSET}/archive
+    debounce_window: 1s
+    directions: [full]
+    remote_poll_window: 1m
+`
+	tests := []struct {
+		name    string
+		doc     string
+		path    string // PATH
+		want    []string
+		wantErr string
+	}{
+		{"valid", rules, bin, []string{
+			"rule 0 (photos): enabled, local_to_remote\n  src: " + src + "\n  dst: gs://backups/photos\n",
+			"rule 1 (/srv/archive): disabled, full\n  src: /srv/archive\n  dst: gs:///archive\n" +
+				"  warning: environment variable GCS_SYNC_UNSET is not set\n",
+			": 2 rule(s) OK\n",
+		}, ""},
+		{"invalid config", "sync: [{enabled: true, src: /srv/data, dst: gs://b, debounce_window: -1s}]\n", bin, nil, "failed to load config"},
+		{"gsutil missing", rules, t.TempDir(), nil, "gsutil not found"},
+		{"gcloud missing", "backend: gcloud\n" + rules, bin, nil, "gcloud not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgPath = filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(cfgPath, []byte(tt.doc), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PATH", tt.path)
+			var out bytes.Buffer
+			validateCmd.SetOut(&out)
+			err := validate(validateCmd, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("validate = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output\n%s\nlacks\n%s", out.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"gcs_sync/internal/logging"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"testing"
+)
+
+// TestVerboseFlag parses -v and --log-level combinations and checks the level setup applies.
+func TestVerboseFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want logrus.Level
+	}{
+		{nil, logrus.InfoLevel},
+		{[]string{"-v"}, logrus.DebugLevel},
+		{[]string{"-vv"}, logrus.TraceLevel},
+		{[]string{"-v", "-v", "-v"}, logrus.TraceLevel},
+		{[]string{"--verbose"}, logrus.DebugLevel},
+		// an explicit --log-level wins
+		{[]string{"-vv", "--log-level", "warn"}, logrus.WarnLevel},
+		{[]string{"--log-level=info", "-v"}, logrus.InfoLevel},
+	}
+	level := logging.L().GetLevel()
+	t.Cleanup(func() { logging.L().SetLevel(level) })
+	old := cfgPath
+	t.Cleanup(func() { cfgPath = old })
+	cfgPath = filepath.Join(t.TempDir(), "missing.yaml") // setup fails after configuring logging
+
+	flags := rootCmd.PersistentFlags()
+	for _, tt := range tests {
+		verbose, logLevel, logLevelFlag.Changed = 0, "info", false
+		if err := flags.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = setup()
+		if got := logging.L().GetLevel(); got != tt.want {
+			t.Errorf("%q: level %s, want %s", tt.args, got, tt.want)
+		}
+	}
+	verbose, logLevel, logLevelFlag.Changed = 0, "info", false
+}
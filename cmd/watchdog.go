@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+	"time"
+)
+
+// afterFunc schedules the watchdog; replaceable for tests.
+var afterFunc = func(d time.Duration, f func()) (stop func() bool) {
+	return time.AfterFunc(d, f).Stop
+}
+
+// watchdog shuts the daemon down gracefully once it has been running for maxRuntime,
+// so that an orchestrator can restart it. The shutdown takes the same path as SIGTERM
+// and the process exits with code 0. A zero maxRuntime disables the watchdog.
+//
+// Parameters:
+//   - lc: The fx.Lifecycle the timer is tied to.
+//   - sd: The fx.Shutdowner used to stop the application.
+//   - log: A logrus.Logger for reporting the shutdown.
+//   - maxRuntime: The runtime after which to stop.
+func watchdog(lc fx.Lifecycle, sd fx.Shutdowner, log *logrus.Logger, maxRuntime time.Duration) {
+	if maxRuntime <= 0 {
+		return
+	}
+	var stop func() bool
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			stop = afterFunc(maxRuntime, func() {
+				log.Infof("max runtime of %s reached, shutting down", maxRuntime)
+				_ = sd.Shutdown()
+			})
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			stop()
+			return nil
+		},
+	})
+}
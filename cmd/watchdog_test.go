@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"github.com/sirupsen/logrus/hooks/test"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+	"testing"
+	"time"
+)
+
+// shutdowner counts shutdown requests.
+type shutdowner struct{ calls int }
+
+func (s *shutdowner) Shutdown(...fx.ShutdownOption) error {
+	s.calls++
+	return nil
+}
+
+func TestWatchdog(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRuntime   time.Duration
+		fire         bool // the timer runs out before the application stops
+		wantShutdown int
+	}{
+		{"disabled", 0, false, 0},
+		{"negative", -time.Hour, false, 0},
+		{"runs out", time.Hour, true, 1},
+		{"stopped first", time.Hour, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				scheduled time.Duration
+				fire      func()
+				stopped   bool
+			)
+			old := afterFunc
+			afterFunc = func(d time.Duration, f func()) func() bool {
+				scheduled, fire = d, f
+				return func() bool { stopped = true; return true }
+			}
+			t.Cleanup(func() { afterFunc = old })
+
+			lc := fxtest.NewLifecycle(t)
+			sd := &shutdowner{}
+			logger, hook := test.NewNullLogger()
+			watchdog(lc, sd, logger, tt.maxRuntime)
+			lc.RequireStart()
+			if tt.maxRuntime <= 0 {
+				if fire != nil {
+					t.Fatal("a disabled watchdog scheduled a timer")
+				}
+				lc.RequireStop()
+				return
+			}
+			if scheduled != tt.maxRuntime {
+				t.Errorf("scheduled after %s, want %s", scheduled, tt.maxRuntime)
+			}
+			if tt.fire {
+				fire()
+				if e := hook.LastEntry(); e == nil || e.Message != "max runtime of 1h0m0s reached, shutting down" {
+					t.Errorf("logged %v, want the max runtime message", e)
+				}
+			}
+			lc.RequireStop()
+			if !stopped {
+				t.Error("the timer was not stopped with the application")
+			}
+			if sd.calls != tt.wantShutdown {
+				t.Errorf("%d shutdowns, want %d", sd.calls, tt.wantShutdown)
+			}
+		})
+	}
+}
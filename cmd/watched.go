@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+var (
+	watchedAddr string
+	watchedRule string
+	watchedCmd  = &cobra.Command{
+		Use:   "watched",
+		Short: "Show the directories a running daemon is watching, per rule",
+		Args:  cobra.NoArgs,
+		RunE:  watched,
+	}
+)
+
+// init registers the watched command and its flags.
+func init() {
+	watchedCmd.Flags().StringVar(&watchedAddr, "addr", "localhost:8080",
+		"status API address of the running daemon (its --status-addr)")
+	watchedCmd.Flags().StringVarP(&watchedRule, "rule", "r", "", "only show this rule")
+	_ = watchedCmd.RegisterFlagCompletionFunc("rule", completeRules)
+	rootCmd.AddCommand(watchedCmd)
+}
+
+// watched queries a running daemon's status API and prints its watched directories.
+//
+// Parameters:
+//   - cmd: The Cobra command, used for output.
+//   - _ []string: Unused positional arguments.
+//
+// Returns:
+//   - error: An error if the daemon could not be reached or answered with an error.
+func watched(cmd *cobra.Command, _ []string) error {
+	u := url.URL{Scheme: "http", Host: watchedAddr, Path: "/status/watched"}
+	if watchedRule != "" {
+		u.RawQuery = url.Values{"rule": {watchedRule}}.Encode()
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status API returned %s", resp.Status)
+	}
+
+	var res map[string][]string
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	rules := make([]string, 0, len(res))
+	for r := range res {
+		rules = append(rules, r)
+	}
+	sort.Strings(rules)
+
+	out := cmd.OutOrStdout()
+	for _, r := range rules {
+		fmt.Fprintf(out, "%s (%d)\n", r, len(res[r]))
+		for _, d := range res[r] {
+			fmt.Fprintf(out, "  %s\n", d)
+		}
+	}
+	return nil
+}
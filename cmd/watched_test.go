@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWatchedCommand(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status/watched" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		if r.URL.Query().Get("rule") == "missing" {
+			http.Error(w, "unknown rule", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"videos":["/srv/videos"],"photos":["/srv/photos","/srv/photos/2024"]}`))
+	}))
+	defer srv.Close()
+	addr, rule := watchedAddr, watchedRule
+	t.Cleanup(func() { watchedAddr, watchedRule = addr, rule })
+	watchedAddr = strings.TrimPrefix(srv.URL, "http://")
+
+	tests := []struct {
+		rule      string
+		wantQuery string
+		want      string
+		wantErr   bool
+	}{
+		{"", "", "photos (2)\n  /srv/photos\n  /srv/photos/2024\nvideos (1)\n  /srv/videos\n", false},
+		{"photos", "rule=photos", "", false},
+		{"missing", "rule=missing", "", true},
+	}
+	for _, tt := range tests {
+		watchedRule = tt.rule
+		var out bytes.Buffer
+		watchedCmd.SetOut(&out)
+		err := watched(watchedCmd, nil)
+		if (err != nil) != tt.wantErr || query != tt.wantQuery {
+			t.Errorf("rule %q: error %v, query %q, want error %v, query %q", tt.rule, err, query, tt.wantErr, tt.wantQuery)
+		}
+		if tt.want != "" && out.String() != tt.want {
+			t.Errorf("rule %q printed\n%s\nwant\n%s", tt.rule, out.String(), tt.want)
+		}
+	}
+}
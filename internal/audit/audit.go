@@ -0,0 +1,92 @@
+// Package audit records every object gcs-sync deletes to a dedicated audit log.
+//
+// The audit log is kept apart from the regular log so that it can be retained and shipped
+// for compliance independently of the log level. Each deletion is one JSON line.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Rule   string    `json:"rule"`
+	Source string    `json:"source"` // the operation that deleted the object: "sync" or "prune"
+	Object string    `json:"object"` // the deleted object or file URL as reported by gsutil
+}
+
+// Log writes audit entries to a file or a standard stream. A nil *Log discards every
+// entry, so callers need no checks when auditing is disabled. It is safe for concurrent use.
+type Log struct {
+	mu  sync.Mutex
+	w   io.Writer
+	c   io.Closer
+	now func() time.Time
+}
+
+// Open opens the audit destination.
+//
+// Parameters:
+//   - dest: "stdout", "stderr", or the path of a file entries are appended to (missing
+//     parent directories are created). An empty dest disables auditing.
+//
+// Returns:
+//   - *Log: The audit log, or nil when dest is empty.
+//   - error: An error if the file could not be opened.
+func Open(dest string) (*Log, error) {
+	switch dest {
+	case "":
+		return nil, nil
+	case "stdout":
+		return &Log{w: os.Stdout, now: time.Now}, nil
+	case "stderr":
+		return &Log{w: os.Stderr, now: time.Now}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{w: f, c: f, now: time.Now}, nil
+}
+
+// Deleted records that an object was deleted.
+//
+// Parameters:
+//   - rule: The ID of the rule the deletion belongs to.
+//   - source: The operation that deleted the object, e.g. "sync" or "prune".
+//   - object: The deleted object's URL.
+//
+// Returns:
+//   - error: An error if the entry could not be written.
+func (l *Log) Deleted(rule, source, object string) error {
+	if l == nil {
+		return nil
+	}
+	line, err := json.Marshal(Entry{Time: l.now().UTC(), Rule: rule, Source: source, Object: object})
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the audit file. Standard streams are left open.
+func (l *Log) Close() error {
+	if l == nil || l.c == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.c.Close()
+}
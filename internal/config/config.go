@@ -1,14 +1,112 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"gcs_sync/internal/util"
+	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 )
 
-// Config mirrors the YAML schema.
+// Config mirrors the YAML schema. JSON files with the same keys are accepted as well.
 type Config struct {
-	Sync []SyncRule `yaml:"sync"`
+	Sync     []SyncRule `yaml:"sync" json:"sync"`
+	MaxRules int        `yaml:"max_rules" json:"max_rules"`
+	// MaxIgnorePatterns caps the ignore patterns of a rule, inline and from ignore_file
+	// together; 0 means unlimited.
+	MaxIgnorePatterns int     `yaml:"max_ignore_patterns" json:"max_ignore_patterns"`
+	DryRun            bool    `yaml:"dry_run" json:"dry_run"`
+	StatsD            *StatsD `yaml:"statsd" json:"statsd"`
+
+	AllowRootDelete    bool           `yaml:"allow_root_delete" json:"allow_root_delete"`
+	ExitCodeLevels     map[int]string `yaml:"exit_code_levels" json:"exit_code_levels"`
+	MeteredCommand     string         `yaml:"metered_command" json:"metered_command"`
+	MinOpenFiles       uint64         `yaml:"min_open_files" json:"min_open_files"`
+	StderrErrors       []string       `yaml:"stderr_errors" json:"stderr_errors"`
+	StderrNoise        []string       `yaml:"stderr_noise" json:"stderr_noise"`
+	MaxTransferWorkers int            `yaml:"max_transfer_workers" json:"max_transfer_workers"`
+	// MaxConcurrentSyncs caps the syncs running at once across all rules; 0 means unlimited.
+	MaxConcurrentSyncs int `yaml:"max_concurrent_syncs" json:"max_concurrent_syncs"`
+	// Backend selects the CLI running rsync: "gsutil" (the default) or "gcloud".
+	Backend string `yaml:"backend" json:"backend"`
+	// MaintenanceFile pauses every sync while a file exists at this path.
+	MaintenanceFile string `yaml:"maintenance_file" json:"maintenance_file"`
+	// GsutilPath is the gsutil executable, a name looked up on PATH or a path; see Gsutil.
+	GsutilPath string `yaml:"gsutil_path" json:"gsutil_path"`
+	// GsutilGlobalFlags replace gsutil's default top-level -m when set.
+	GsutilGlobalFlags []string `yaml:"gsutil_global_flags" json:"gsutil_global_flags"`
+
+	// MinGsutilVersion defaults to versions.DefaultMinGsutil when nil; "" disables the check.
+	MinGsutilVersion *string `yaml:"min_gsutil_version" json:"min_gsutil_version"`
+	MinGcloudVersion string  `yaml:"min_gcloud_version" json:"min_gcloud_version"`
+	StrictVersions   bool    `yaml:"strict_versions" json:"strict_versions"`
+
+	// AuditLog receives one JSON line per deleted object: "stdout", "stderr" or a file path.
+	AuditLog string `yaml:"audit_log" json:"audit_log"`
+}
+
+// ExitLevels parses ExitCodeLevels into logrus levels.
+//
+// Returns:
+//   - map[int]logrus.Level: The level to log each mapped gsutil exit code at.
+//   - error: An error naming the first unknown level.
+func (c *Config) ExitLevels() (map[int]logrus.Level, error) {
+	res := make(map[int]logrus.Level, len(c.ExitCodeLevels))
+	for code, name := range c.ExitCodeLevels {
+		lvl, err := logrus.ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("exit_code_levels[%d]: %w", code, err)
+		}
+		res[code] = lvl
+	}
+	return res, nil
+}
+
+// StatsD configures the optional StatsD metrics sink.
+type StatsD struct {
+	Address string `yaml:"address" json:"address"` // host:port of the UDP listener
+	Prefix  string `yaml:"prefix" json:"prefix"`   // prepended to every metric name
+}
+
+// Rule returns the rule with the given ID (see SyncRule.ID).
+func (c *Config) Rule(id string) (SyncRule, bool) {
+	for _, r := range c.Sync {
+		if r.ID() == id {
+			return r, true
+		}
+	}
+	return SyncRule{}, false
+}
+
+// ForceDryRun puts every rule into dry-run mode, overriding rule-level dry_run: false.
+// It backs the --dry-run command-line flag.
+func (c *Config) ForceDryRun() {
+	c.DryRun = true
+	for i := range c.Sync {
+		c.Sync[i].DryRun = nil
+	}
+}
+
+// Gsutil returns the gsutil executable to run: gsutil_path, or "gsutil" looked up on PATH.
+func (c *Config) Gsutil() string {
+	if c.GsutilPath == "" {
+		return "gsutil"
+	}
+	return c.GsutilPath
+}
+
+// RuleDryRun resolves whether a rule runs in dry-run mode.
+// A rule's own dry_run setting, when present, wins over the global one in either direction.
+func (c *Config) RuleDryRun(r SyncRule) bool {
+	if r.DryRun != nil {
+		return *r.DryRun
+	}
+	return c.DryRun
 }
 
 type SyncDirection string
@@ -24,34 +122,337 @@ const (
 )
 
 type SyncRule struct {
-	Src              string          `yaml:"src"`
-	Dst              string          `yaml:"dst"`
-	Directions       []SyncDirection `yaml:"directions"`
-	Ignore           []string        `yaml:"ignore"`
-	Enabled          bool            `yaml:"enabled"`
-	DebounceWindow   time.Duration   `yaml:"debounce_window"`
-	RemotePollWindow time.Duration   `yaml:"remote_poll_window"`
+	Name                    string          `yaml:"name" json:"name"`
+	Src                     string          `yaml:"src" json:"src"`
+	Dst                     string          `yaml:"dst" json:"dst"`
+	Directions              []SyncDirection `yaml:"directions" json:"directions"`
+	Ignore                  []string        `yaml:"ignore" json:"ignore"`
+	IgnoreFile              string          `yaml:"ignore_file" json:"ignore_file"`
+	IgnoreGit               bool            `yaml:"ignore_git" json:"ignore_git"`
+	IgnoreCaseInsensitive   bool            `yaml:"ignore_case_insensitive" json:"ignore_case_insensitive"`
+	Enabled                 bool            `yaml:"enabled" json:"enabled"`
+	DebounceWindow          time.Duration   `yaml:"debounce_window" json:"debounce_window"`
+	RemotePollWindow        time.Duration   `yaml:"remote_poll_window" json:"remote_poll_window"`
+	RemotePollImmediate     bool            `yaml:"remote_poll_immediate" json:"remote_poll_immediate"`
+	FollowSymlinks          bool            `yaml:"follow_symlinks" json:"follow_symlinks"`
+	BrokenSymlinks          string          `yaml:"broken_symlinks" json:"broken_symlinks"`
+	StateDir                string          `yaml:"state_dir" json:"state_dir"`
+	TrackerMaxAge           time.Duration   `yaml:"tracker_max_age" json:"tracker_max_age"`
+	ContentKind             string          `yaml:"content_kind" json:"content_kind"`
+	ExcludeTypes            []string        `yaml:"exclude_types" json:"exclude_types"`
+	ActiveWindow            *ActiveWindow   `yaml:"active_window" json:"active_window"`
+	Subpaths                []string        `yaml:"subpaths" json:"subpaths"`
+	PullFileMode            string          `yaml:"pull_file_mode" json:"pull_file_mode"`
+	DryRun                  *bool           `yaml:"dry_run" json:"dry_run"`
+	Owner                   string          `yaml:"owner" json:"owner"`
+	Include                 []string        `yaml:"include" json:"include"`
+	StrictAllowlist         bool            `yaml:"strict_allowlist" json:"strict_allowlist"`
+	PreSync                 string          `yaml:"pre_sync" json:"pre_sync"`
+	SkipUnchanged           bool            `yaml:"skip_unchanged" json:"skip_unchanged"`
+	FlushOnShutdown         bool            `yaml:"flush_on_shutdown" json:"flush_on_shutdown"`
+	DrainOnShutdown         bool            `yaml:"drain_on_shutdown" json:"drain_on_shutdown"`
+	PollGenerations         bool            `yaml:"poll_generations" json:"poll_generations"`
+	ExcludeOlderThan        time.Duration   `yaml:"exclude_older_than" json:"exclude_older_than"`
+	SkipEmptyFiles          bool            `yaml:"skip_empty_files" json:"skip_empty_files"`
+	MaxFileSize             string          `yaml:"max_file_size" json:"max_file_size"`
+	AppendOnly              bool            `yaml:"append_only" json:"append_only"`
+	ImmediateDeletes        bool            `yaml:"immediate_deletes" json:"immediate_deletes"`
+	ImmediateDeleteLimit    int             `yaml:"immediate_delete_limit" json:"immediate_delete_limit"`
+	BatchSize               int             `yaml:"batch_size" json:"batch_size"`
+	InitialPreview          bool            `yaml:"initial_preview" json:"initial_preview"`
+	InitialConfirm          bool            `yaml:"initial_confirm" json:"initial_confirm"`
+	CaseCollisions          string          `yaml:"case_collisions" json:"case_collisions"`
+	QuarantineDir           string          `yaml:"quarantine_dir" json:"quarantine_dir"`
+	ReconcileReport         string          `yaml:"reconcile_report" json:"reconcile_report"`
+	LogFile                 string          `yaml:"log_file" json:"log_file"`
+	LogFileMaxSize          string          `yaml:"log_file_max_size" json:"log_file_max_size"`
+	LogFileMaxTotal         string          `yaml:"log_file_max_total" json:"log_file_max_total"`
+	LogFileMaxAge           time.Duration   `yaml:"log_file_max_age" json:"log_file_max_age"`
+	PauseWhenMetered        bool            `yaml:"pause_when_metered" json:"pause_when_metered"`
+	EmptyPollBackoff        time.Duration   `yaml:"empty_poll_backoff" json:"empty_poll_backoff"`
+	SkipOpenFiles           bool            `yaml:"skip_open_files" json:"skip_open_files"`
+	TransferWorkers         int             `yaml:"transfer_workers" json:"transfer_workers"`
+	DeleteOrphans           bool            `yaml:"delete_orphans" json:"delete_orphans"`
+	Mirror                  bool            `yaml:"mirror" json:"mirror"`
+	MaxEventRate            int             `yaml:"max_event_rate" json:"max_event_rate"`
+	RecreateGrace           time.Duration   `yaml:"recreate_grace" json:"recreate_grace"`
+	MaxRetries              int             `yaml:"max_retries" json:"max_retries"`
+	RetryBackoff            time.Duration   `yaml:"retry_backoff" json:"retry_backoff"`
+	RetryJitter             *float64        `yaml:"retry_jitter" json:"retry_jitter"`
+	CompositeThreshold      string          `yaml:"composite_upload_threshold" json:"composite_upload_threshold"`
+	BandwidthLimit          string          `yaml:"bandwidth_limit" json:"bandwidth_limit"`
+	ParallelProcessCount    int             `yaml:"parallel_process_count" json:"parallel_process_count"`
+	ParallelThreadCount     int             `yaml:"parallel_thread_count" json:"parallel_thread_count"`
+	SlicedDownloadThreshold string          `yaml:"sliced_object_download_threshold" json:"sliced_object_download_threshold"`
+	RemoteDeletePolls       int             `yaml:"remote_delete_polls" json:"remote_delete_polls"`
+	LogTemplate             string          `yaml:"log_template" json:"log_template"`
+	DriftCheck              time.Duration   `yaml:"drift_check" json:"drift_check"`
+}
+
+// DefaultDeleteThreshold is how many deletions at once are considered safe without a
+// second look: prune asks for confirmation above it, and immediate_deletes leaves larger
+// bursts to the next sync unless immediate_delete_limit says otherwise.
+const DefaultDeleteThreshold = 100
+
+// DefaultBatchSize is the number of files passed to one gsutil invocation when a rule
+// transfers an explicit file list and batch_size is not set.
+const DefaultBatchSize = 100
+
+// Batch returns the rule's batch_size, or DefaultBatchSize when it is not positive.
+func (r SyncRule) Batch() int {
+	if r.BatchSize > 0 {
+		return r.BatchSize
+	}
+	return DefaultBatchSize
+}
+
+// GitPatterns are the ignore globs ignore_git adds: a .git directory (or the .git file of a
+// worktree or submodule) at the root or at any depth, together with everything inside it.
+var GitPatterns = []string{".git", ".git/**", "**/.git", "**/.git/**"}
+
+// IgnorePatterns returns the rule's inline ignore globs, preceded by GitPatterns when
+// ignore_git is set. Coming first, they can still be overridden by '!' patterns in ignore.
+func (r SyncRule) IgnorePatterns() []string {
+	if !r.IgnoreGit {
+		return r.Ignore
+	}
+	return append(append([]string(nil), GitPatterns...), r.Ignore...)
+}
+
+// ID returns the rule's name, falling back to its source path for unnamed rules.
+func (r SyncRule) ID() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Src
+}
+
+// ActiveWindow restricts syncing to certain hours of certain days.
+type ActiveWindow struct {
+	Start    string   `yaml:"start" json:"start"`       // "HH:MM", inclusive
+	End      string   `yaml:"end" json:"end"`           // "HH:MM", exclusive; may be earlier than Start to span midnight
+	Timezone string   `yaml:"timezone" json:"timezone"` // IANA name, defaults to the local zone
+	Weekdays []string `yaml:"weekdays" json:"weekdays"` // e.g. ["mon", "tue"]; empty means every day
 }
 
 // Load parses a YAML configuration file and returns a Config struct.
 //
-// It reads the file from the specified path, unmarshals the YAML content
-// into a Config struct, and returns a pointer to the resulting Config.
+// It reads the file from the specified path and hands its content to Parse, which
+// unmarshals the YAML (or, for *.json files, JSON) into a Config struct and validates it.
 //
 // Parameters:
-//   - path: A string representing the file path of the YAML configuration file to be loaded.
+//   - path: A string representing the file path of the YAML or JSON configuration file to be loaded.
 //
 // Returns:
 //   - *Config: A pointer to the parsed Config struct containing the configuration data.
-//   - error: An error if any occurred during file reading or YAML unmarshaling. It returns nil if successful.
+//   - error: An error if any occurred during file reading, unmarshaling or validation. It returns nil if successful.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return Parse(data, strings.EqualFold(filepath.Ext(path), ".json"))
+}
+
+// Parse decodes and validates a configuration held in memory, e.g. one embedded in a
+// program or built by a test. A Config literal needs no parsing; call Validate on it instead.
+//
+// Parameters:
+//   - data: The YAML or JSON document.
+//   - isJSON: Whether data is JSON rather than YAML.
+//
+// Returns:
+//   - *Config: The decoded configuration.
+//   - error: An error if data could not be unmarshaled or is not a valid configuration.
+func Parse(data []byte, isJSON bool) (*Config, error) {
 	var cfg Config
-	if err = yaml.Unmarshal(data, &cfg); err != nil {
+	var err error
+	if isJSON {
+		err = json.Unmarshal(data, &cfg)
+	} else {
+		err = yaml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = cfg.Validate(); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
+
+// Validate checks the fields of every enabled rule and the constraints spanning the whole
+// configuration. Load and Parse call it, so a loaded Config is always valid; Manager.Start
+// calls it as well, for configurations built in code.
+//
+// Returns:
+//   - error: A descriptive error for the first violated constraint, naming the rule index and
+//     field where applicable, or nil if the configuration is usable.
+func (c *Config) Validate() error {
+	if _, err := c.ExitLevels(); err != nil {
+		return err
+	}
+	for _, e := range append(append([]string(nil), c.StderrErrors...), c.StderrNoise...) {
+		if _, err := regexp.Compile(e); err != nil {
+			return fmt.Errorf("stderr pattern %q: %w", e, err)
+		}
+	}
+	switch c.Backend {
+	case "", "gsutil", "gcloud":
+	default:
+		return fmt.Errorf("backend: unknown backend %q (want gsutil|gcloud)", c.Backend)
+	}
+	if c.MaxRules > 0 {
+		if n := c.enabledCount(); n > c.MaxRules {
+			return fmt.Errorf("%d enabled rules exceed max_rules=%d", n, c.MaxRules)
+		}
+	}
+	for i, r := range c.Sync {
+		if !r.Enabled {
+			continue
+		}
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, r.ID(), err)
+		}
+		for _, sp := range r.Subpaths {
+			if clean := filepath.Clean(sp); filepath.IsAbs(clean) || clean == "." || clean == ".." ||
+				strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("rule %d (%s): subpath %q must be a directory inside src", i, r.ID(), sp)
+			}
+		}
+		if r.PollGenerations && !util.IsRemote(r.Dst) {
+			return fmt.Errorf("rule %d (%s): poll_generations requires a cloud dst", i, r.ID())
+		}
+		// compare the locations the rule will actually use, e.g. ~/data and $HOME/data/mirror
+		src, _ := util.ExpandEnv(r.Src)
+		dst, _ := util.ExpandEnv(r.Dst)
+		if util.IsRemote(dst) || src == "" || dst == "" {
+			continue
+		}
+		// a local dst inside src (or vice versa) would sync the tree into itself
+		if util.IsNested(src, dst) {
+			return fmt.Errorf("rule %d (%s): local src %q and dst %q overlap", i, r.ID(), src, dst)
+		}
+	}
+	return nil
+}
+
+// sizePattern matches the sizes gsutil accepts in its boto options, e.g. 0, 150M or 2GiB.
+var sizePattern = regexp.MustCompile(`(?i)^\d+\s*([kmgtp]i?)?b?$`)
+
+// validate checks the fields of a single rule.
+//
+// Returns:
+//   - error: An error naming the offending field, or nil if the rule is usable.
+func (r SyncRule) validate() error {
+	if strings.TrimSpace(r.Src) == "" {
+		return fmt.Errorf("src must not be empty")
+	}
+	if strings.TrimSpace(r.Dst) == "" {
+		return fmt.Errorf("dst must not be empty")
+	}
+	// no directions at all means the documented default, local_to_remote
+	pushes, pulls := len(r.Directions) == 0, false
+	for _, d := range r.Directions {
+		switch d {
+		case LocalToRemote:
+			pushes = true
+		case RemoteToLocal:
+			pulls = true
+		case Full:
+			pushes, pulls = true, true
+		default:
+			return fmt.Errorf("directions: unknown direction %q (want %s, %s or %s)", d, LocalToRemote, RemoteToLocal, Full)
+		}
+	}
+	if r.ImmediateDeletes && r.AppendOnly {
+		return fmt.Errorf("immediate_deletes contradicts append_only, which never deletes")
+	}
+	if r.ImmediateDeletes && !util.IsRemote(r.Dst) {
+		return fmt.Errorf("immediate_deletes requires a cloud dst")
+	}
+	if r.CompositeThreshold != "" && !sizePattern.MatchString(r.CompositeThreshold) {
+		return fmt.Errorf("composite_upload_threshold: %q is not a size like 150M", r.CompositeThreshold)
+	}
+	if r.BandwidthLimit != "" {
+		n, err := util.ParseSize(r.BandwidthLimit)
+		if err != nil {
+			return fmt.Errorf("bandwidth_limit: %w", err)
+		}
+		if n <= 0 {
+			return fmt.Errorf("bandwidth_limit must be positive, got %q", r.BandwidthLimit)
+		}
+	}
+	if r.MaxFileSize != "" {
+		n, err := util.ParseSize(r.MaxFileSize)
+		if err != nil {
+			return fmt.Errorf("max_file_size: %w", err)
+		}
+		if n <= 0 {
+			return fmt.Errorf("max_file_size must be positive, got %q", r.MaxFileSize)
+		}
+	}
+	switch r.BrokenSymlinks {
+	case "", "skip", "warn", "error":
+	default:
+		return fmt.Errorf("broken_symlinks: unknown policy %q (want skip|warn|error)", r.BrokenSymlinks)
+	}
+	if r.SlicedDownloadThreshold != "" && !sizePattern.MatchString(r.SlicedDownloadThreshold) {
+		return fmt.Errorf("sliced_object_download_threshold: %q is not a size like 150M", r.SlicedDownloadThreshold)
+	}
+	if r.ParallelProcessCount < 0 {
+		return fmt.Errorf("parallel_process_count must not be negative, got %d", r.ParallelProcessCount)
+	}
+	if r.ParallelThreadCount < 0 {
+		return fmt.Errorf("parallel_thread_count must not be negative, got %d", r.ParallelThreadCount)
+	}
+	for _, o := range [...]struct{ name, size string }{
+		{"log_file_max_size", r.LogFileMaxSize},
+		{"log_file_max_total", r.LogFileMaxTotal},
+	} {
+		name, size := o.name, o.size
+		if size == "" {
+			continue
+		}
+		if r.LogFile == "" {
+			return fmt.Errorf("%s requires log_file", name)
+		}
+		if n, err := util.ParseSize(size); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		} else if n <= 0 {
+			return fmt.Errorf("%s must be positive, got %q", name, size)
+		}
+	}
+	if r.LogFileMaxAge < 0 {
+		return fmt.Errorf("log_file_max_age must not be negative, got %s", r.LogFileMaxAge)
+	}
+	if r.LogFileMaxAge > 0 && r.LogFile == "" {
+		return fmt.Errorf("log_file_max_age requires log_file")
+	}
+	if r.RetryJitter != nil && (*r.RetryJitter < 0 || *r.RetryJitter > 1) {
+		return fmt.Errorf("retry_jitter must be between 0 and 1, got %g", *r.RetryJitter)
+	}
+	// the debounce window only delays syncs triggered by local file events
+	if pushes && r.DebounceWindow <= 0 {
+		return fmt.Errorf("debounce_window must be positive, got %s", r.DebounceWindow)
+	}
+	// the poll ticker is the only trigger of a pull
+	if pulls && r.RemotePollWindow <= 0 {
+		return fmt.Errorf("remote_poll_window must be positive for rules that pull, got %s", r.RemotePollWindow)
+	}
+	if r.DriftCheck < 0 {
+		return fmt.Errorf("drift_check must not be negative, got %s", r.DriftCheck)
+	}
+	return nil
+}
+
+// enabledCount returns the number of enabled rules.
+func (c *Config) enabledCount() int {
+	n := 0
+	for _, r := range c.Sync {
+		if r.Enabled {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"github.com/sirupsen/logrus"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validRule returns an enabled push rule that passes validation.
+func validRule() SyncRule {
+	return SyncRule{
+		Enabled:        true,
+		Src:            "/srv/data",
+		Dst:            "gs://bucket/data",
+		DebounceWindow: time.Second,
+	}
+}
+
+func TestValidateOverlap(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	root := t.TempDir()
+	t.Setenv("DATA_ROOT", root)
+	tests := []struct {
+		name     string
+		src, dst string
+		wantErr  string
+	}{
+		{"cloud dst", "/srv/data", "gs://bucket/data", ""},
+		{"siblings", filepath.Join(root, "a"), filepath.Join(root, "b"), ""},
+		{"same directory", filepath.Join(root, "a"), filepath.Join(root, "a") + "/", "overlap"},
+		{"dst inside src", filepath.Join(root, "a"), filepath.Join(root, "a", "mirror"), "overlap"},
+		{"src inside dst", filepath.Join(root, "a", "sub"), "file://" + filepath.Join(root, "a"), "overlap"},
+		{"variables", "$DATA_ROOT/a", "${DATA_ROOT}/a/mirror", "overlap"},
+		{"variable and literal", "$DATA_ROOT/a", filepath.Join(root, "a", "mirror"), "overlap"},
+		{"tilde and literal", "~/data", filepath.Join(u.HomeDir, "data", "mirror"), "overlap"},
+		{"variables apart", "$DATA_ROOT/a", "$DATA_ROOT/ab", ""},
+		{"variable expanding to a cloud dst", "$DATA_ROOT/a", "gs://$DATA_ROOT/a", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := validRule()
+			r.Src, r.Dst = tt.src, tt.dst
+			err := (&Config{Sync: []SyncRule{r}}).Validate()
+			checkErr(t, err, tt.wantErr)
+			// the error names the expanded locations
+			if src := filepath.Join(root, "a"); err != nil && strings.HasPrefix(tt.src, "$") && !strings.Contains(err.Error(), src) {
+				t.Errorf("Validate error %q does not name the expanded src %s", err, src)
+			}
+		})
+	}
+}
+
+// checkErr fails t unless err contains want, or is nil when want is empty.
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("Validate = %v, want nil", err)
+	case want != "" && err == nil:
+		t.Errorf("Validate = nil, want an error containing %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("Validate = %v, want an error containing %q", err, want)
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		isJSON  bool
+		want    *float64
+		wantErr string
+	}{
+		{"unset", "sync: [{enabled: true, src: /srv/data, dst: gs://b, debounce_window: 1s}]", false, nil, ""},
+		{"zero is kept", "sync: [{enabled: true, src: /srv/data, dst: gs://b, debounce_window: 1s, retry_jitter: 0}]", false, ptr(0.0), ""},
+		{"yaml", "sync: [{enabled: true, src: /srv/data, dst: gs://b, debounce_window: 1s, retry_jitter: 0.25}]", false, ptr(0.25), ""},
+		{"json", `{"sync": [{"enabled": true, "src": "/srv/data", "dst": "gs://b", "debounce_window": "1s", "retry_jitter": 1}]}`, true, ptr(1.0), ""},
+		{"negative", "sync: [{enabled: true, src: /srv/data, dst: gs://b, debounce_window: 1s, retry_jitter: -0.1}]", false, nil, "retry_jitter"},
+		{"above one", "sync: [{enabled: true, src: /srv/data, dst: gs://b, debounce_window: 1s, retry_jitter: 1.5}]", false, nil, "retry_jitter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.doc), tt.isJSON)
+			checkErr(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			got := cfg.Sync[0].RetryJitter
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("RetryJitter = %v, want %v", deref(got), deref(tt.want))
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// deref formats an optional value for test messages.
+func deref(p *float64) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func TestValidateLogFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(r *SyncRule)
+		wantErr string
+	}{
+		{"no log file", func(r *SyncRule) {}, ""},
+		{"rotation", func(r *SyncRule) {
+			r.LogFile, r.LogFileMaxSize, r.LogFileMaxTotal, r.LogFileMaxAge = "/var/log/rule.log", "10M", "1G", 24*time.Hour
+		}, ""},
+		{"max size without log file", func(r *SyncRule) { r.LogFileMaxSize = "10M" }, "log_file_max_size requires log_file"},
+		{"max total without log file", func(r *SyncRule) { r.LogFileMaxTotal = "1G" }, "log_file_max_total requires log_file"},
+		{"max age without log file", func(r *SyncRule) { r.LogFileMaxAge = time.Hour }, "log_file_max_age requires log_file"},
+		{"unparsable max size", func(r *SyncRule) { r.LogFile, r.LogFileMaxSize = "/var/log/rule.log", "lots" }, "log_file_max_size"},
+		{"zero max total", func(r *SyncRule) { r.LogFile, r.LogFileMaxTotal = "/var/log/rule.log", "0" }, "log_file_max_total must be positive"},
+		{"negative max age", func(r *SyncRule) { r.LogFile, r.LogFileMaxAge = "/var/log/rule.log", -time.Hour }, "log_file_max_age must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := validRule()
+			tt.edit(&r)
+			checkErr(t, (&Config{Sync: []SyncRule{r}}).Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestLogFileMaxAgeJSON(t *testing.T) {
+	doc := `{"sync": [{"enabled": true, "src": "/srv/data", "dst": "gs://b", "debounce_window": "1s",
+		"log_file": "/var/log/rule.log", "log_file_max_age": "36h"}]}`
+	cfg, err := Parse([]byte(doc), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Sync[0].LogFileMaxAge; got != 36*time.Hour {
+		t.Errorf("LogFileMaxAge = %s, want 36h", got)
+	}
+}
+
+func TestValidateDirections(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(r *SyncRule)
+		wantErr string
+	}{
+		{"empty directions push", func(r *SyncRule) { r.Directions = nil }, ""},
+		{"empty directions need a debounce window", func(r *SyncRule) { r.Directions, r.DebounceWindow = []SyncDirection{}, 0 }, "debounce_window"},
+		{"pull", func(r *SyncRule) {
+			r.Directions, r.DebounceWindow, r.RemotePollWindow = []SyncDirection{RemoteToLocal}, 0, time.Minute
+		}, ""},
+		{"pull without poll window", func(r *SyncRule) { r.Directions = []SyncDirection{RemoteToLocal} }, "remote_poll_window must be positive"},
+		{"full without poll window", func(r *SyncRule) { r.Directions = []SyncDirection{Full} }, "remote_poll_window must be positive"},
+		{"negative poll window", func(r *SyncRule) {
+			r.Directions, r.RemotePollWindow = []SyncDirection{Full}, -time.Minute
+		}, "remote_poll_window must be positive"},
+		{"push ignores the poll window", func(r *SyncRule) { r.Directions = []SyncDirection{LocalToRemote} }, ""},
+		{"unknown direction", func(r *SyncRule) { r.Directions = []SyncDirection{"sideways"} }, "unknown direction"},
+		{"drift check", func(r *SyncRule) { r.DriftCheck = time.Hour }, ""},
+		{"negative drift check", func(r *SyncRule) { r.DriftCheck = -time.Hour }, "drift_check must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := validRule()
+			tt.edit(&r)
+			checkErr(t, (&Config{Sync: []SyncRule{r}}).Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateBandwidthLimit(t *testing.T) {
+	tests := []struct {
+		limit   string
+		wantErr string
+	}{
+		{"", ""},
+		{"512k", ""},
+		{"10MiB", ""},
+		{"0", "bandwidth_limit must be positive"},
+		{"fast", "bandwidth_limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			r := validRule()
+			r.BandwidthLimit = tt.limit
+			checkErr(t, (&Config{Sync: []SyncRule{r}}).Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateMaxRules(t *testing.T) {
+	tests := []struct {
+		name            string
+		max             int
+		enabled, paused int
+		wantErr         string
+	}{
+		{"no limit", 0, 5, 0, ""},
+		{"at the limit", 3, 3, 0, ""},
+		{"disabled rules do not count", 3, 3, 4, ""},
+		{"above the limit", 3, 4, 0, "4 enabled rules exceed max_rules=3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{MaxRules: tt.max}
+			for i := range tt.enabled + tt.paused {
+				r := validRule()
+				r.Src = filepath.Join("/srv", strconv.Itoa(i))
+				r.Dst = "gs://bucket/" + strconv.Itoa(i)
+				r.Enabled = i < tt.enabled
+				cfg.Sync = append(cfg.Sync, r)
+			}
+			checkErr(t, cfg.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateSubpaths(t *testing.T) {
+	tests := []struct {
+		subpath string
+		wantErr string
+	}{
+		{"photos", ""},
+		{"photos/raw/", ""},
+		{"./docs", ""},
+		{"a/../b", ""},
+		{"/abs", "must be a directory inside src"},
+		{".", "must be a directory inside src"},
+		{"..", "must be a directory inside src"},
+		{"../sibling", "must be a directory inside src"},
+		{"a/../../b", "must be a directory inside src"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subpath, func(t *testing.T) {
+			r := validRule()
+			r.Subpaths = []string{tt.subpath}
+			checkErr(t, (&Config{Sync: []SyncRule{r}}).Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestRuleDryRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		global bool
+		rule   *bool
+		force  bool
+		want   bool
+	}{
+		{"off", false, nil, false, false},
+		{"global", true, nil, false, true},
+		{"rule opts in", false, ptr(true), false, true},
+		{"rule opts out", true, ptr(false), false, false},
+		{"--dry-run overrides an opt-out", true, ptr(false), true, true},
+		{"--dry-run without the global setting", false, nil, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := validRule()
+			r.DryRun = tt.rule
+			cfg := &Config{DryRun: tt.global, Sync: []SyncRule{r}}
+			if tt.force {
+				cfg.ForceDryRun()
+			}
+			if got := cfg.RuleDryRun(cfg.Sync[0]); got != tt.want {
+				t.Errorf("RuleDryRun = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunYAML(t *testing.T) {
+	doc := `dry_run: true
+sync:
+  - {enabled: true, src: /srv/a, dst: gs://b/a, debounce_window: 1s}
+  - {enabled: true, src: /srv/b, dst: gs://b/b, debounce_window: 1s, dry_run: false}`
+	cfg, err := Parse([]byte(doc), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.RuleDryRun(cfg.Sync[0]) || cfg.RuleDryRun(cfg.Sync[1]) {
+		t.Errorf("dry-run = %v, %v, want true, false", cfg.RuleDryRun(cfg.Sync[0]), cfg.RuleDryRun(cfg.Sync[1]))
+	}
+}
+
+func TestExitLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		levels  map[int]string
+		want    map[int]logrus.Level
+		wantErr string
+	}{
+		{"none", nil, map[int]logrus.Level{}, ""},
+		{"levels", map[int]string{1: "warn", 2: "INFO", 3: "debug"},
+			map[int]logrus.Level{1: logrus.WarnLevel, 2: logrus.InfoLevel, 3: logrus.DebugLevel}, ""},
+		{"unknown level", map[int]string{1: "warn", 7: "loud"}, nil, "exit_code_levels[7]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{ExitCodeLevels: tt.levels, Sync: []SyncRule{validRule()}}
+			got, err := cfg.ExitLevels()
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr == "" && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExitLevels = %v, want %v", got, tt.want)
+			}
+			// Validate rejects what ExitLevels cannot parse
+			checkErr(t, cfg.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	for size, want := range map[int]int{0: DefaultBatchSize, -5: DefaultBatchSize, 1: 1, 500: 500} {
+		r := validRule()
+		r.BatchSize = size
+		if got := r.Batch(); got != want {
+			t.Errorf("Batch() with batch_size %d = %d, want %d", size, got, want)
+		}
+	}
+}
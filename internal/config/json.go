@@ -0,0 +1,73 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// duration decodes a JSON duration written either as a Go duration string ("5m", "1h30m")
+// or as a whole number of nanoseconds, mirroring what yaml.v3 accepts for time.Duration.
+type duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler. Numbers are decoded as int64 rather than
+// float64, which would round nanosecond counts above 2^53.
+func (d *duration) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case nil:
+		*d = 0
+	case json.Number:
+		n, err := val.Int64()
+		if err != nil {
+			return fmt.Errorf("invalid duration %s: want whole nanoseconds or a string like \"5m\"", b)
+		}
+		*d = duration(n)
+	case string:
+		parsed, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		*d = duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a rule from JSON, accepting human-readable strings for the
+// duration fields just like the YAML loader does.
+func (r *SyncRule) UnmarshalJSON(b []byte) error {
+	type plain SyncRule
+	aux := struct {
+		*plain
+		DebounceWindow   duration `json:"debounce_window"`
+		RemotePollWindow duration `json:"remote_poll_window"`
+		TrackerMaxAge    duration `json:"tracker_max_age"`
+		ExcludeOlderThan duration `json:"exclude_older_than"`
+		EmptyPollBackoff duration `json:"empty_poll_backoff"`
+		RecreateGrace    duration `json:"recreate_grace"`
+		RetryBackoff     duration `json:"retry_backoff"`
+		DriftCheck       duration `json:"drift_check"`
+		LogFileMaxAge    duration `json:"log_file_max_age"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	r.DebounceWindow = time.Duration(aux.DebounceWindow)
+	r.RemotePollWindow = time.Duration(aux.RemotePollWindow)
+	r.TrackerMaxAge = time.Duration(aux.TrackerMaxAge)
+	r.ExcludeOlderThan = time.Duration(aux.ExcludeOlderThan)
+	r.EmptyPollBackoff = time.Duration(aux.EmptyPollBackoff)
+	r.RecreateGrace = time.Duration(aux.RecreateGrace)
+	r.RetryBackoff = time.Duration(aux.RetryBackoff)
+	r.DriftCheck = time.Duration(aux.DriftCheck)
+	r.LogFileMaxAge = time.Duration(aux.LogFileMaxAge)
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{`"5m"`, 5 * time.Minute, false},
+		{`"1h30m"`, 90 * time.Minute, false},
+		{`1500000000`, 1500 * time.Millisecond, false},
+		{`0`, 0, false},
+		{`null`, 0, false},
+		// above 2^53, where a float64 would round to 9007199254740992
+		{`9007199254740993`, 9007199254740993, false},
+		{`-1000`, -time.Microsecond, false},
+		{`1.5`, 0, true},
+		{`1e9`, 0, true},
+		{`99999999999999999999`, 0, true},
+		{`true`, 0, true},
+		{`"bogus"`, 0, true},
+		{`["5m"]`, 0, true},
+	}
+	for _, tt := range tests {
+		d := duration(time.Hour)
+		err := d.UnmarshalJSON([]byte(tt.in))
+		if (err != nil) != tt.wantErr || (!tt.wantErr && time.Duration(d) != tt.want) {
+			t.Errorf("UnmarshalJSON(%s) = %s, %v, want %s, error %v", tt.in, time.Duration(d), err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRuleJSONDurations(t *testing.T) {
+	doc := `{"sync": [{"enabled": true, "src": "/srv/data", "dst": "gs://b", "debounce_window": "2s",
+		"remote_poll_window": 60000000000, "drift_check": null, "retry_backoff": "1m30s",
+		"exclude_older_than": "720h"}]}`
+	cfg, err := Parse([]byte(doc), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := cfg.Sync[0]
+	for name, got := range map[string][2]time.Duration{
+		"debounce_window":    {r.DebounceWindow, 2 * time.Second},
+		"remote_poll_window": {r.RemotePollWindow, time.Minute},
+		"drift_check":        {r.DriftCheck, 0},
+		"retry_backoff":      {r.RetryBackoff, 90 * time.Second},
+		"exclude_older_than": {r.ExcludeOlderThan, 30 * 24 * time.Hour},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("%s = %s, want %s", name, got[0], got[1])
+		}
+	}
+	if _, err := Parse([]byte(`{"sync": [{"src": "/srv/data", "dst": "gs://b", "debounce_window": true}]}`), true); err == nil {
+		t.Error("Parse accepted a boolean debounce_window")
+	}
+}
@@ -0,0 +1,18 @@
+package filter
+
+import "time"
+
+// OlderThan returns a filter excluding files whose modification time lies more than
+// age before now.
+//
+// Parameters:
+//   - age: The maximum age of a synced file.
+//   - now: The clock to measure age against, typically time.Now.
+//
+// Returns:
+//   - Func: The age filter.
+func OlderThan(age time.Duration, now func() time.Time) Func {
+	return func(f File) (bool, error) {
+		return f.Info.ModTime().Before(now().Add(-age)), nil
+	}
+}
@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// sniffLen is the number of leading bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// Content kinds accepted by ContentKind.
+const (
+	Text   = "text"
+	Binary = "binary"
+)
+
+// ContentKind returns a filter keeping only files of the given kind ("text" or "binary").
+//
+// Files are classified by sniffing their first 512 bytes with http.DetectContentType;
+// anything reported as text/* is text, everything else is binary. Empty files count as text.
+//
+// Parameters:
+//   - kind: The kind of files to keep.
+//
+// Returns:
+//   - Func: A filter excluding every file of the other kind.
+//   - error: An error if kind is not recognised.
+func ContentKind(kind string) (Func, error) {
+	if kind != Text && kind != Binary {
+		return nil, fmt.Errorf("unknown content kind %q (want %s|%s)", kind, Text, Binary)
+	}
+	return func(f File) (bool, error) {
+		isText, err := IsText(f.Path)
+		if err != nil {
+			return false, err
+		}
+		return isText != (kind == Text), nil
+	}, nil
+}
+
+// IsText reports whether the file at path looks like text.
+func IsText(path string) (bool, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer fh.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(fh, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return strings.HasPrefix(http.DetectContentType(buf[:n]), "text/"), nil
+}
@@ -0,0 +1,12 @@
+package filter
+
+// Empty returns a filter excluding zero-byte files, e.g. placeholders written by tools
+// that fill them in later.
+//
+// Returns:
+//   - Func: The empty-file filter.
+func Empty() Func {
+	return func(f File) (bool, error) {
+		return f.Info.Size() == 0, nil
+	}
+}
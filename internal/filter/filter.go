@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"gcs_sync/internal/ignore"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// File describes a regular file visited during a pre-sync walk of a rule's source.
+type File struct {
+	Path string      // path on disk
+	Rel  string      // unix-style path relative to the source root
+	Info fs.FileInfo // lstat information
+}
+
+// Func decides whether a file must be excluded from the next transfer.
+// A non-nil error aborts the walk.
+type Func func(f File) (bool, error)
+
+// Excludes walks the source tree and collects the files rejected by any of the filters.
+//
+// gsutil rsync can only exclude by regular expression, so attributes that are not
+// visible in a path (content, size, age, ...) are evaluated here before each sync and
+// turned into an explicit list of relative paths.
+//
+// Broken symlinks are the one kind of non-regular file the walk looks at: they are passed
+// to broken, which decides whether they are excluded or fail the walk, so gsutil never
+// trips over them when following symlinks.
+//
+// Parameters:
+//   - root: The source root to walk.
+//   - ign: Already-compiled ignore rules; matching files and directories are not visited.
+//   - fns: The filters to apply to every regular file.
+//   - broken: Decides about broken symlinks; nil leaves them to gsutil.
+//
+// Returns:
+//   - []string: Unix-style paths, relative to root, of the excluded files.
+//   - error: An error if the walk, any filter or broken failed.
+func Excludes(root string, ign []ignore.Pattern, fns []Func, broken BrokenLinkFunc) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && ignore.Match(rel, ign) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 && broken != nil && isBroken(p) {
+			skip, err := broken(rel)
+			if skip {
+				out = append(out, rel)
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f := File{Path: p, Rel: rel, Info: info}
+		for _, fn := range fns {
+			skip, err := fn(f)
+			if err != nil {
+				return err
+			}
+			if skip {
+				out = append(out, rel)
+				break
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Regex builds a single anchored expression matching exactly the given relative paths.
+// It returns nil when paths is empty.
+func Regex(paths []string) *regexp.Regexp {
+	if len(paths) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^(?:" + strings.Join(quoted, "|") + ")$")
+}
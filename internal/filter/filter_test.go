@@ -0,0 +1,281 @@
+package filter
+
+import (
+	"gcs_sync/internal/ignore"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTree creates the files under root, mapping unix-style relative paths to contents.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// symlink creates a symlink or skips the test where that is not possible.
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		if runtime.GOOS == "windows" {
+			t.Skipf("symlinks need privileges on windows: %v", err)
+		}
+		t.Fatal(err)
+	}
+}
+
+func TestExcludes(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	magic, err := MagicBytes([]string{"archive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	textOnly, err := ContentKind(Text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		filters []Func
+		ignore  []string
+		want    []string
+	}{
+		{"no filters", nil, nil, nil},
+		{"empty files", []Func{Empty()}, nil, []string{"dir/empty"}},
+		{"larger than", []Func{LargerThan(10)}, nil, []string{"big.txt", "dir/data.zip"}},
+		{"older than", []Func{OlderThan(24*time.Hour, time.Now)}, nil, []string{"old.txt"}},
+		{"magic bytes", []Func{magic}, nil, []string{"dir/data.zip"}},
+		{"text only", []Func{textOnly}, nil, []string{"dir/data.zip"}},
+		{"ignored directories are not visited", []Func{Empty()}, []string{"dir"}, nil},
+		{"first matching filter wins", []Func{LargerThan(10), magic}, nil, []string{"big.txt", "dir/data.zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				"small.txt":    "hi",
+				"big.txt":      strings.Repeat("x", 100),
+				"old.txt":      "old",
+				"dir/empty":    "",
+				"dir/data.zip": "PK\x03\x04 some zipped bytes",
+			})
+			if err := os.Chtimes(filepath.Join(root, "old.txt"), old, old); err != nil {
+				t.Fatal(err)
+			}
+			ign, err := ignore.Compile(root, tt.ignore, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Excludes(root, ign, tt.filters, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Excludes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludesSymlinks(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	writeTree(t, root, map[string]string{"real/empty": ""})
+	writeTree(t, outside, map[string]string{"empty": ""})
+	symlink(t, filepath.Join(root, "real"), filepath.Join(root, "inner"))
+	symlink(t, outside, filepath.Join(root, "outer"))
+	symlink(t, filepath.Join(root, "missing"), filepath.Join(root, "dangling"))
+
+	// symlinked directories are not descended into, so their files are never filtered
+	got, err := Excludes(root, nil, []Func{Empty()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"real/empty"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excludes = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		policy  string
+		want    []string
+		wantErr bool
+		warned  bool
+	}{
+		{BrokenSkip, []string{"dangling"}, false, false},
+		{"", []string{"dangling"}, false, false},
+		{BrokenWarn, []string{"dangling"}, false, true},
+		{BrokenError, nil, true, false},
+	}
+	for _, tt := range tests {
+		t.Run("broken "+tt.policy, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			broken, err := BrokenLinks(tt.policy, logrus.NewEntry(logger))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Excludes(root, nil, nil, broken)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Excludes error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Excludes = %q, want %q", got, tt.want)
+			}
+			if warned := len(hook.AllEntries()) > 0; warned != tt.warned {
+				t.Errorf("warned = %v, want %v", warned, tt.warned)
+			}
+		})
+	}
+	if _, err := BrokenLinks("ignore", nil); err == nil {
+		t.Error("BrokenLinks accepted an unknown policy")
+	}
+}
+
+func TestMagicBytes(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"app":       "\x7fELF\x02\x01",
+		"doc.pdf":   "%PDF-1.7",
+		"short":     "\x7f",
+		"notes.txt": "plain text",
+		"empty":     "",
+	})
+	fn, err := MagicBytes([]string{" Executable ", "pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"app": true, "doc.pdf": true, "short": false, "notes.txt": false, "empty": false} {
+		p := filepath.Join(root, name)
+		info, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := fn(File{Path: p, Rel: name, Info: info}); err != nil || got != want {
+			t.Errorf("MagicBytes(%s) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := MagicBytes([]string{"exe"}); err == nil {
+		t.Error("MagicBytes accepted an unknown type")
+	}
+}
+
+func TestRegex(t *testing.T) {
+	if Regex(nil) != nil {
+		t.Error("Regex(nil) != nil")
+	}
+	re := Regex([]string{"a.txt", "dir/b (1).txt"})
+	for path, want := range map[string]bool{"a.txt": true, "dir/b (1).txt": true, "aXtxt": false, "x/a.txt": false} {
+		if got := re.MatchString(path); got != want {
+			t.Errorf("Regex matches %q = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.jpg": "", "dir/b.jpg": "", "dir/c.txt": "", "tmp/d.txt": ""})
+	include, err := ignore.Compile(root, []string{"*.jpg", "dir/*.jpg"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ign, err := ignore.Compile(root, []string{"tmp"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		strict  bool
+		ignore  []ignore.Pattern
+		want    []string
+		wantErr string
+	}{
+		{"excludes files outside the allowlist", false, ign, []string{"dir/c.txt"}, ""},
+		{"strict fails on them", true, ign, nil, "dir/c.txt is not covered"},
+		// ignored files are never visited, so strict mode does not trip over them
+		{"strict with only ignored strays", true, append(ign, mustCompile(t, root, "dir/*.txt")...), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Excludes(root, tt.ignore, []Func{Include(include, tt.strict)}, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Excludes = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Excludes = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func mustCompile(t *testing.T, root string, patterns ...string) []ignore.Pattern {
+	t.Helper()
+	p, err := ignore.Compile(root, patterns, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOlderThan(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	fn := OlderThan(24*time.Hour, func() time.Time { return now })
+	p := filepath.Join(t.TempDir(), "f")
+	writeTree(t, filepath.Dir(p), map[string]string{"f": "x"})
+	tests := []struct {
+		mtime time.Time
+		want  bool
+	}{
+		{now, false},
+		{now.Add(time.Hour), false}, // in the future
+		{now.Add(-24 * time.Hour), false},
+		{now.Add(-24*time.Hour - time.Second), true},
+		{now.AddDate(-1, 0, 0), true},
+	}
+	for _, tt := range tests {
+		if err := os.Chtimes(p, tt.mtime, tt.mtime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := fn(File{Path: p, Rel: "f", Info: info}); err != nil || got != tt.want {
+			t.Errorf("OlderThan(24h) at mtime %s = %v, %v, want %v", tt.mtime, got, err, tt.want)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir()) // open files are reported by their resolved path
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, map[string]string{"open.log": "", "closed.log": ""})
+	symlink(t, "open.log", filepath.Join(root, "link.log"))
+	fn := Open(map[string]bool{filepath.Join(root, "open.log"): true})
+	for name, want := range map[string]bool{"open.log": true, "closed.log": false, "link.log": true} {
+		p := filepath.Join(root, name)
+		info, err := os.Lstat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := fn(File{Path: p, Rel: name, Info: info}); err != nil || got != want {
+			t.Errorf("Open(%s) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+}
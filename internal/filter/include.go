@@ -0,0 +1,26 @@
+package filter
+
+import (
+	"fmt"
+	"gcs_sync/internal/ignore"
+)
+
+// Include returns a filter that only keeps files matching one of the include expressions.
+//
+// Parameters:
+//   - include: Compiled include patterns (see ignore.Compile).
+//   - strict: Fail instead of excluding when a file outside the allowlist is found.
+//
+// Returns:
+//   - Func: The allowlist filter.
+func Include(include []ignore.Pattern, strict bool) Func {
+	return func(f File) (bool, error) {
+		if ignore.Match(f.Rel, include) {
+			return false, nil
+		}
+		if strict {
+			return false, fmt.Errorf("strict_allowlist: %s is not covered by any include pattern", f.Rel)
+		}
+		return true, nil
+	}
+}
@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// signature is a byte sequence a file of some type carries at a fixed offset.
+type signature struct {
+	offset int
+	magic  []byte
+}
+
+// magicTypes is the built-in registry of file types recognised by their magic bytes.
+var magicTypes = map[string][]signature{
+	"elf":    {{0, []byte("\x7fELF")}},
+	"pe":     {{0, []byte("MZ")}},
+	"macho":  {{0, []byte("\xfe\xed\xfa\xce")}, {0, []byte("\xfe\xed\xfa\xcf")}, {0, []byte("\xce\xfa\xed\xfe")}, {0, []byte("\xcf\xfa\xed\xfe")}, {0, []byte("\xca\xfe\xba\xbe")}},
+	"zip":    {{0, []byte("PK\x03\x04")}, {0, []byte("PK\x05\x06")}},
+	"gzip":   {{0, []byte("\x1f\x8b")}},
+	"bzip2":  {{0, []byte("BZh")}},
+	"xz":     {{0, []byte("\xfd7zXZ\x00")}},
+	"zstd":   {{0, []byte("\x28\xb5\x2f\xfd")}},
+	"7z":     {{0, []byte("7z\xbc\xaf\x27\x1c")}},
+	"rar":    {{0, []byte("Rar!\x1a\x07")}},
+	"tar":    {{257, []byte("ustar")}},
+	"pdf":    {{0, []byte("%PDF-")}},
+	"png":    {{0, []byte("\x89PNG\r\n\x1a\n")}},
+	"jpeg":   {{0, []byte("\xff\xd8\xff")}},
+	"gif":    {{0, []byte("GIF87a")}, {0, []byte("GIF89a")}},
+	"sqlite": {{0, []byte("SQLite format 3\x00")}},
+}
+
+// Magic type groups, expanding to several registry entries.
+var magicGroups = map[string][]string{
+	"executable": {"elf", "pe", "macho"},
+	"archive":    {"zip", "gzip", "bzip2", "xz", "zstd", "7z", "rar", "tar"},
+}
+
+// MagicTypes returns the names accepted by MagicBytes, sorted.
+func MagicTypes() []string {
+	names := make([]string, 0, len(magicTypes)+len(magicGroups))
+	for name := range magicTypes {
+		names = append(names, name)
+	}
+	for name := range magicGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MagicBytes returns a filter excluding files whose leading bytes identify them as one of
+// the given types, whatever their name or extension.
+//
+// Types come from a small built-in registry (elf, zip, pdf, ...), plus the groups
+// "executable" and "archive"; see MagicTypes.
+//
+// Parameters:
+//   - types: The names of the types to exclude.
+//
+// Returns:
+//   - Func: The magic-bytes filter.
+//   - error: An error if a type is not in the registry.
+func MagicBytes(types []string) (Func, error) {
+	var sigs []signature
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		names := []string{t}
+		if group, ok := magicGroups[t]; ok {
+			names = group
+		}
+		for _, name := range names {
+			s, ok := magicTypes[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown file type %q (want one of %s)", t, strings.Join(MagicTypes(), ", "))
+			}
+			sigs = append(sigs, s...)
+		}
+	}
+	return func(f File) (bool, error) {
+		if f.Info.Size() == 0 {
+			return false, nil
+		}
+		return hasSignature(f.Path, sigs)
+	}, nil
+}
+
+// hasSignature reports whether the file at path starts with any of the signatures.
+func hasSignature(path string, sigs []signature) (bool, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer fh.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(fh, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	buf = buf[:n]
+	for _, s := range sigs {
+		if end := s.offset + len(s.magic); end <= len(buf) && bytes.Equal(buf[s.offset:end], s.magic) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,34 @@
+package filter
+
+import "path/filepath"
+
+// OpenDetector finds files that other processes currently hold open for writing.
+type OpenDetector interface {
+	// OpenForWriting returns the resolved absolute paths of files below root that
+	// another process has open for writing.
+	OpenForWriting(root string) (map[string]bool, error)
+}
+
+// Open returns a filter excluding files contained in open, as reported by an
+// OpenDetector for the current walk. Files are compared by their resolved path.
+//
+// Parameters:
+//   - open: The resolved paths of files open for writing.
+//
+// Returns:
+//   - Func: The open-file filter.
+func Open(open map[string]bool) Func {
+	return func(f File) (bool, error) {
+		if len(open) == 0 {
+			return false, nil
+		}
+		p, err := filepath.EvalSymlinks(f.Path)
+		if err != nil {
+			return false, nil
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		return open[p], nil
+	}
+}
@@ -0,0 +1,83 @@
+//go:build linux
+
+package filter
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot is the proc file system mount point; replaceable for tests.
+var procRoot = "/proc"
+
+// ProcDetector is the Linux OpenDetector. It scans /proc/<pid>/fd of every process it
+// may inspect, so files held open by other users' processes are only seen when running
+// as root.
+type ProcDetector struct{}
+
+// NewOpenDetector returns the platform's default OpenDetector.
+func NewOpenDetector() (OpenDetector, error) {
+	return ProcDetector{}, nil
+}
+
+// OpenForWriting implements OpenDetector.
+func (ProcDetector) OpenForWriting(root string) (map[string]bool, error) {
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+
+	procs, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	self := strconv.Itoa(os.Getpid())
+	open := make(map[string]bool)
+	for _, p := range procs {
+		pid := p.Name()
+		if _, err := strconv.Atoi(pid); err != nil || pid == self {
+			continue
+		}
+		fdDir := filepath.Join(procRoot, pid, "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue // exited, or not ours to inspect
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, prefix) {
+				continue
+			}
+			if writable(filepath.Join(procRoot, pid, "fdinfo", fd.Name())) {
+				open[target] = true
+			}
+		}
+	}
+	return open, nil
+}
+
+// writable reports whether the fdinfo file describes a descriptor opened with
+// O_WRONLY or O_RDWR.
+func writable(fdinfo string) bool {
+	f, err := os.Open(fdinfo)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		v, ok := strings.CutPrefix(sc.Text(), "flags:")
+		if !ok {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimSpace(v), 8, 64)
+		return err == nil && flags&uint64(os.O_WRONLY|os.O_RDWR) != 0
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// TestProcDetector scans a fake /proc holding descriptors of several processes.
+func TestProcDetector(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"writing.log": "", "reading.txt": "", "both.db": ""})
+	outside := filepath.Join(t.TempDir(), "elsewhere.log")
+	proc := t.TempDir()
+	old := procRoot
+	procRoot = proc
+	t.Cleanup(func() { procRoot = old })
+
+	// pid → fd → target and octal open flags
+	type fd struct{ target, flags string }
+	fds := map[string]map[string]fd{
+		"100": {"3": {filepath.Join(root, "writing.log"), "0100001"}, "4": {filepath.Join(root, "reading.txt"), "0100000"}},
+		"200": {"5": {filepath.Join(root, "both.db"), "02100002"}, "6": {outside, "0100001"}},
+		// our own descriptors never count
+		strconv.Itoa(os.Getpid()): {"7": {filepath.Join(root, "reading.txt"), "0100001"}},
+		// not a process
+		"self": {"8": {filepath.Join(root, "reading.txt"), "0100001"}},
+	}
+	for pid, byFD := range fds {
+		for n, d := range byFD {
+			if err := os.MkdirAll(filepath.Join(proc, pid, "fd"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(filepath.Join(proc, pid, "fdinfo"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(d.target, filepath.Join(proc, pid, "fd", n)); err != nil {
+				t.Fatal(err)
+			}
+			info := "pos:\t0\nflags:\t" + d.flags + "\nmnt_id:\t25\n"
+			if err := os.WriteFile(filepath.Join(proc, pid, "fdinfo", n), []byte(info), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// a process that exited between listing /proc and reading its descriptors
+	if err := os.Mkdir(filepath.Join(proc, "300"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ProcDetector{}.OpenForWriting(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{filepath.Join(root, "writing.log"): true, filepath.Join(root, "both.db"): true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OpenForWriting = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package filter
+
+import "errors"
+
+// NewOpenDetector is not available on this platform; the default detector reads /proc.
+func NewOpenDetector() (OpenDetector, error) {
+	return nil, errors.New("detecting open files is only supported on Linux")
+}
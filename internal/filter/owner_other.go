@@ -0,0 +1,10 @@
+//go:build !unix
+
+package filter
+
+import "errors"
+
+// Owner is not available on this platform; file ownership is a Unix concept.
+func Owner(spec string) (Func, error) {
+	return nil, errors.New("the owner filter is only supported on Unix")
+}
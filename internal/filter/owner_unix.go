@@ -0,0 +1,48 @@
+//go:build unix
+
+package filter
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Owner returns a filter excluding files not owned by the given user.
+//
+// Parameters:
+//   - spec: A numeric uid or a user name.
+//
+// Returns:
+//   - Func: A filter excluding files whose owner uid differs.
+//   - error: An error if the user cannot be resolved.
+func Owner(spec string) (Func, error) {
+	uid, err := resolveUID(spec)
+	if err != nil {
+		return nil, err
+	}
+	return func(f File) (bool, error) {
+		st, ok := f.Info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return false, fmt.Errorf("no ownership information for %s", f.Rel)
+		}
+		return st.Uid != uid, nil
+	}, nil
+}
+
+// resolveUID converts a uid or user name into a numeric uid.
+func resolveUID(spec string) (uint32, error) {
+	if n, err := strconv.ParseUint(spec, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	u, err := user.Lookup(spec)
+	if err != nil {
+		return 0, fmt.Errorf("owner %q: %w", spec, err)
+	}
+	n, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("owner %q: non-numeric uid %q", spec, u.Uid)
+	}
+	return uint32(n), nil
+}
@@ -0,0 +1,69 @@
+//go:build unix
+
+package filter
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestOwner(t *testing.T) {
+	me, err := user.Current()
+	if err != nil {
+		t.Skipf("current user: %v", err)
+	}
+	uid := os.Getuid()
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"mine.txt": "a", "dir/mine.txt": "b", "theirs.txt": "c"})
+	if uid == 0 {
+		// only root can hand a file to someone else
+		if err := os.Chown(filepath.Join(root, "theirs.txt"), 4242, -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		want []string
+	}{
+		{"own uid", strconv.Itoa(uid), []string{"theirs.txt"}},
+		{"own user name", me.Username, []string{"theirs.txt"}},
+		{"other uid", "4242", []string{"dir/mine.txt", "mine.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, err := Owner(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Excludes(root, nil, []Func{owner}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if uid != 0 {
+				// theirs.txt belongs to the current user as well
+				switch tt.spec {
+				case "4242":
+					want = []string{"dir/mine.txt", "mine.txt", "theirs.txt"}
+				default:
+					want = nil
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Excludes = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestOwnerUnknownUser(t *testing.T) {
+	if _, err := Owner("no-such-user-gcs-sync"); err == nil {
+		t.Error("Owner accepted an unknown user")
+	}
+}
@@ -0,0 +1,15 @@
+package filter
+
+// LargerThan returns a filter excluding files of more than limit bytes, e.g. video
+// scratch files that are not worth uploading.
+//
+// Parameters:
+//   - limit: The size of the largest synced file, in bytes.
+//
+// Returns:
+//   - Func: The size filter.
+func LargerThan(limit int64) Func {
+	return func(f File) (bool, error) {
+		return f.Info.Size() > limit, nil
+	}
+}
@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// Policies for broken symlinks accepted by BrokenLinks.
+const (
+	BrokenSkip  = "skip"
+	BrokenWarn  = "warn"
+	BrokenError = "error"
+)
+
+// BrokenLinkFunc decides what happens to a symlink whose target does not exist, met
+// during a pre-sync walk. Returning true excludes it from the transfer; a non-nil error
+// aborts the walk and with it the sync.
+type BrokenLinkFunc func(rel string) (bool, error)
+
+// BrokenLinks returns the handler for the given broken symlink policy:
+//   - skip: exclude the link silently.
+//   - warn: exclude the link and log a warning, once per link.
+//   - error: fail the sync, naming the link.
+//
+// Parameters:
+//   - policy: One of BrokenSkip, BrokenWarn or BrokenError; "" means skip.
+//   - log: The rule's logrus.Entry, for warnings.
+//
+// Returns:
+//   - BrokenLinkFunc: The handler.
+//   - error: An error if policy is not recognised.
+func BrokenLinks(policy string, log *logrus.Entry) (BrokenLinkFunc, error) {
+	switch policy {
+	case "", BrokenSkip:
+		return func(string) (bool, error) { return true, nil }, nil
+	case BrokenWarn:
+		var warned sync.Map
+		return func(rel string) (bool, error) {
+			if _, seen := warned.LoadOrStore(rel, true); !seen {
+				log.Warnf("skipping broken symlink %s", rel)
+			}
+			return true, nil
+		}, nil
+	case BrokenError:
+		return func(rel string) (bool, error) {
+			return false, fmt.Errorf("broken symlink %s", rel)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown broken symlink policy %q (want %s|%s|%s)", policy, BrokenSkip, BrokenWarn, BrokenError)
+}
+
+// isBroken reports whether the symlink at p points to a missing target.
+func isBroken(p string) bool {
+	_, err := os.Stat(p)
+	return errors.Is(err, fs.ErrNotExist)
+}
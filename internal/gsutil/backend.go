@@ -0,0 +1,134 @@
+package gsutil
+
+import (
+	"context"
+	"fmt"
+	"gcs_sync/internal/ignore"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+// Backend names accepted by NewBackend.
+const (
+	BackendGsutil = "gsutil"
+	BackendGcloud = "gcloud"
+)
+
+// Backend performs the recursive synchronizations of a rule.
+//
+// Only rsync goes through the backend: per-file copies, deletions, listings and dry-run
+// diffs keep using gsutil, so it must stay installed with either backend.
+type Backend interface {
+	// RSync synchronizes dst with src; see the package-level RSync for the semantics
+	// every backend follows.
+	RSync(ctx context.Context, src, dst string, opts Options, log *logrus.Entry) (SyncResult, error)
+}
+
+// NewBackend returns the backend with the given name; "" selects gsutil.
+//
+// Parameters:
+//   - name: "gsutil", "gcloud" or "".
+//
+// Returns:
+//   - Backend: The selected backend.
+//   - error: An error if name is not recognised.
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case "", BackendGsutil:
+		return gsutilBackend{}, nil
+	case BackendGcloud:
+		return gcloudBackend{}, nil
+	}
+	return nil, fmt.Errorf("unknown backend %q (want %s|%s)", name, BackendGsutil, BackendGcloud)
+}
+
+// gsutilBackend runs `gsutil -m rsync`.
+type gsutilBackend struct{}
+
+// RSync implements Backend.
+func (gsutilBackend) RSync(ctx context.Context, src, dst string, opts Options, log *logrus.Entry) (SyncResult, error) {
+	return RSync(ctx, src, dst, opts, log)
+}
+
+// gcloudBackend runs `gcloud storage rsync`, the successor of gsutil.
+type gcloudBackend struct{}
+
+// RSync implements Backend.
+func (gcloudBackend) RSync(ctx context.Context, src, dst string, opts Options, log *logrus.Entry) (SyncResult, error) {
+	var res SyncResult
+	err := runTool(ctx, "gcloud", gcloudRSyncArgs(src, dst, opts), gcloudEnv(opts), opts, &res, log)
+	return res, err
+}
+
+// gcloudRSyncArgs returns the `gcloud storage rsync` arguments equivalent to the
+// `gsutil rsync` ones rsyncArgs builds for opts.
+func gcloudRSyncArgs(src, dst string, opts Options) []string {
+	args := []string{"storage", "rsync", "--recursive"}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+	}
+	if !opts.FollowSymlinks {
+		args = append(args, "--ignore-symlinks")
+	}
+	if opts.Checksum {
+		args = append(args, "--checksums-only")
+	}
+	if opts.SkipNewer {
+		args = append(args, "--skip-if-dest-has-newer-mtime")
+	}
+	if opts.Delete {
+		args = append(args, "--delete-unmatched-destination-objects")
+	}
+	for _, expr := range ignore.Expressions(opts.Ignore) {
+		args = append(args, "--exclude="+gcloudList(expr))
+	}
+	return append(args, src, dst)
+}
+
+// gcloudEnv returns the storage properties equivalent to the `-o GSUtil:...` options
+// globalArgs builds for opts. gcloud always transfers in parallel, so there is no `-m`.
+func gcloudEnv(opts Options) []string {
+	processes, sliced := opts.ParallelProcesses, opts.SlicedThreshold
+	if processes <= 0 {
+		processes = DefaultParallelProcesses
+	}
+	if sliced == "" {
+		sliced = DefaultSlicedThreshold
+	}
+	env := []string{
+		"CLOUDSDK_STORAGE_PROCESS_COUNT=" + strconv.Itoa(processes),
+		"CLOUDSDK_STORAGE_SLICED_OBJECT_DOWNLOAD_THRESHOLD=" + sliced,
+	}
+	if opts.ParallelThreads > 0 {
+		env = append(env, "CLOUDSDK_STORAGE_THREAD_COUNT="+strconv.Itoa(opts.ParallelThreads))
+	}
+	if opts.StateDir != "" {
+		env = append(env, "CLOUDSDK_STORAGE_TRACKER_FILES_DIRECTORY="+opts.StateDir)
+	}
+	switch opts.CompositeThreshold {
+	case "":
+	case "0":
+		env = append(env, "CLOUDSDK_STORAGE_PARALLEL_COMPOSITE_UPLOAD_ENABLED=False")
+	default:
+		env = append(env,
+			"CLOUDSDK_STORAGE_PARALLEL_COMPOSITE_UPLOAD_ENABLED=True",
+			"CLOUDSDK_STORAGE_PARALLEL_COMPOSITE_UPLOAD_THRESHOLD="+opts.CompositeThreshold)
+	}
+	return env
+}
+
+// gcloudList protects a single value of a list flag. gcloud splits list values at
+// commas, which regular expressions may contain (e.g. `a{1,3}`); its `^DELIM^` prefix
+// switches to a delimiter that does not occur in the value.
+func gcloudList(value string) string {
+	if !strings.Contains(value, ",") {
+		return value
+	}
+	for _, d := range []string{";", "@", "#", "~", "%"} {
+		if !strings.Contains(value, d) {
+			return "^" + d + "^" + value
+		}
+	}
+	return "^\x1f^" + value
+}
@@ -0,0 +1,140 @@
+package gsutil
+
+import (
+	"context"
+	"gcs_sync/internal/ignore"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Backend
+		wantErr bool
+	}{
+		{"", gsutilBackend{}, false},
+		{BackendGsutil, gsutilBackend{}, false},
+		{BackendGcloud, gcloudBackend{}, false},
+		{"rclone", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := NewBackend(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("NewBackend(%q) = %T, %v, want %T, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGcloudRSyncArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"defaults skip symlinks", Options{}, []string{"storage", "rsync", "--recursive", "--ignore-symlinks", "src", "dst"}},
+		{"follow symlinks", Options{FollowSymlinks: true}, []string{"storage", "rsync", "--recursive", "src", "dst"}},
+		{"every flag", Options{DryRun: true, Checksum: true, SkipNewer: true, Delete: true}, []string{
+			"storage", "rsync", "--recursive", "--dry-run", "--ignore-symlinks", "--checksums-only",
+			"--skip-if-dest-has-newer-mtime", "--delete-unmatched-destination-objects", "src", "dst"}},
+		{"exclusions", Options{FollowSymlinks: true, Ignore: []ignore.Pattern{
+			{Regexp: regexp.MustCompile(`^a$`)}, {Regexp: regexp.MustCompile(`^b{1,3}$`)}}}, []string{
+			"storage", "rsync", "--recursive", "--exclude=^a$", "--exclude=^;^^b{1,3}$", "src", "dst"}},
+		// global gsutil flags and -m mean nothing to gcloud
+		{"gsutil options are not passed", Options{FollowSymlinks: true, GlobalFlags: []string{"-q"}, Binary: "/opt/gsutil"},
+			[]string{"storage", "rsync", "--recursive", "src", "dst"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gcloudRSyncArgs("src", "dst", tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gcloudRSyncArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGcloudEnv(t *testing.T) {
+	defaults := []string{
+		"CLOUDSDK_STORAGE_PROCESS_COUNT=1",
+		"CLOUDSDK_STORAGE_SLICED_OBJECT_DOWNLOAD_THRESHOLD=0",
+	}
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"defaults", Options{}, defaults},
+		{"parallelism", Options{ParallelProcesses: 4, ParallelThreads: 8, SlicedThreshold: "150M"}, []string{
+			"CLOUDSDK_STORAGE_PROCESS_COUNT=4",
+			"CLOUDSDK_STORAGE_SLICED_OBJECT_DOWNLOAD_THRESHOLD=150M",
+			"CLOUDSDK_STORAGE_THREAD_COUNT=8"}},
+		{"state dir", Options{StateDir: "/var/lib/gcs-sync"}, append(append([]string(nil), defaults...),
+			"CLOUDSDK_STORAGE_TRACKER_FILES_DIRECTORY=/var/lib/gcs-sync")},
+		{"composite uploads off", Options{CompositeThreshold: "0"}, append(append([]string(nil), defaults...),
+			"CLOUDSDK_STORAGE_PARALLEL_COMPOSITE_UPLOAD_ENABLED=False")},
+		{"composite uploads on", Options{CompositeThreshold: "150M"}, append(append([]string(nil), defaults...),
+			"CLOUDSDK_STORAGE_PARALLEL_COMPOSITE_UPLOAD_ENABLED=True",
+			"CLOUDSDK_STORAGE_PARALLEL_COMPOSITE_UPLOAD_THRESHOLD=150M")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gcloudEnv(tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gcloudEnv = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGcloudList(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`^a\.log$`, `^a\.log$`},
+		{`^a{1,3}$`, `^;^^a{1,3}$`},
+		{`^a{1,3};$`, `^@^^a{1,3};$`},
+		{"^[,;@#~%]$", "^\x1f^^[,;@#~%]$"},
+	}
+	for _, tt := range tests {
+		if got := gcloudList(tt.in); got != tt.want {
+			t.Errorf("gcloudList(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestGcloudRSyncRuns starts a fake gcloud and checks its argument vector and the storage
+// properties it receives through the environment.
+func TestGcloudRSyncRuns(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + out + "\nenv | grep '^CLOUDSDK_STORAGE_' | sort >> " + out + "\n"
+	fakeGsutil(t, "exit 1\n") // gsutil must not run
+	if err := os.WriteFile(filepath.Join(dir, "gcloud"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	log, hook := ruleLog()
+	opts := Options{Delete: true, ParallelProcesses: 2, StateDir: "/state"}
+	if _, err := (gcloudBackend{}).RSync(context.Background(), "/data", "gs://bucket/data", opts, log); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"storage", "rsync", "--recursive", "--ignore-symlinks", "--delete-unmatched-destination-objects", "/data", "gs://bucket/data",
+		"CLOUDSDK_STORAGE_PROCESS_COUNT=2",
+		"CLOUDSDK_STORAGE_SLICED_OBJECT_DOWNLOAD_THRESHOLD=0",
+		"CLOUDSDK_STORAGE_TRACKER_FILES_DIRECTORY=/state",
+	}
+	if got := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("gcloud started with\n%q\nwant\n%q", got, want)
+	}
+	if logged(hook, logrus.InfoLevel, "gcloud storage rsync") == nil {
+		t.Error("the gcloud command line was not logged")
+	}
+}
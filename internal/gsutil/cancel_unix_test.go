@@ -0,0 +1,82 @@
+//go:build unix
+
+package gsutil
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCancelKillsGsutil(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, log *logrus.Entry) error
+	}{
+		{"rsync", func(ctx context.Context, log *logrus.Entry) error {
+			_, err := RSync(ctx, "/data", "gs://bucket", Options{}, log)
+			return err
+		}},
+		{"dry-run diff", func(ctx context.Context, log *logrus.Entry) error {
+			_, err := DryRunDiff(ctx, "/data", "gs://bucket", Options{}, log)
+			return err
+		}},
+		{"listing", func(ctx context.Context, log *logrus.Entry) error {
+			_, err := Objects(ctx, "gs://bucket", Options{}, log)
+			return err
+		}},
+		{"rm", func(ctx context.Context, log *logrus.Entry) error {
+			return Remove(ctx, []string{"gs://bucket/a"}, Options{}, log)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "pid")
+			t.Setenv("FAKE_GSUTIL_PID", pidFile)
+			fakeGsutil(t, "echo $$ > \"$FAKE_GSUTIL_PID\"\nexec sleep 30\n")
+			log, _ := ruleLog()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- tt.run(ctx, log) }()
+			pid := waitForPid(t, pidFile)
+			cancel()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("error = %v, want it to wrap context.Canceled", err)
+				}
+			case <-time.After(waitDelay):
+				t.Fatal("gsutil was not killed on cancel")
+			}
+			if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+				t.Errorf("gsutil (pid %d) still exists after cancel: %v", pid, err)
+			}
+		})
+	}
+}
+
+// waitForPid waits for the fake gsutil to write its pid to file.
+func waitForPid(t *testing.T, file string) int {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+			return pid
+		}
+	}
+	t.Fatal("the fake gsutil did not start")
+	return 0
+}
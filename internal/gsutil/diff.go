@@ -0,0 +1,135 @@
+package gsutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Transfer is a single copy reported by a dry-run.
+type Transfer struct {
+	Src string
+	Dst string
+}
+
+// Diff is the set of changes `gsutil rsync -n` reports between a source and a destination.
+type Diff struct {
+	Copies   []Transfer // files missing or different at the destination
+	Removals []string   // destination URLs absent from the source (only reported with Delete)
+}
+
+// Empty reports whether the diff contains no changes.
+func (d Diff) Empty() bool {
+	return len(d.Copies) == 0 && len(d.Removals) == 0
+}
+
+// ParseDiff reads `gsutil rsync -n` output and collects the reported changes.
+//
+// gsutil prints one line per change, e.g.
+//
+//	Would copy file:///data/a.txt to gs://bucket/a.txt
+//	Would remove gs://bucket/old.txt
+//
+// All other lines (progress, notices) are ignored.
+//
+// Parameters:
+//   - r: The combined stdout/stderr of the dry-run.
+//
+// Returns:
+//   - Diff: The parsed changes.
+//   - error: An error if reading r failed.
+func ParseDiff(r io.Reader) (Diff, error) {
+	var d Diff
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "Would copy "):
+			rest := strings.TrimPrefix(line, "Would copy ")
+			if i := strings.LastIndex(rest, " to "); i > 0 {
+				d.Copies = append(d.Copies, Transfer{Src: rest[:i], Dst: rest[i+len(" to "):]})
+			}
+		case strings.HasPrefix(line, "Would remove "):
+			d.Removals = append(d.Removals, strings.TrimPrefix(line, "Would remove "))
+		}
+	}
+	return d, sc.Err()
+}
+
+// DryRunDiff runs `gsutil rsync -n` and returns what a real run would change.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
+//   - src: The source path or URL.
+//   - dst: The destination path or URL.
+//   - opts: Options for the rsync; DryRun is forced on.
+//   - log: A logrus.Entry for logging the operation.
+//
+// Returns:
+//   - Diff: The changes a real run would make.
+//   - error: An error if gsutil failed or its output could not be read.
+func DryRunDiff(ctx context.Context, src, dst string, opts Options, log *logrus.Entry) (Diff, error) {
+	opts.DryRun = true
+	args := append(globalArgs(opts), rsyncArgs(src, dst, opts)...)
+	log.Debugf("gsutil %s", strings.Join(args, " "))
+
+	pr, pw := io.Pipe()
+	cmd := command(ctx, binary(opts), args...)
+	cmd.Stdout, cmd.Stderr = pw, pw
+	cmd.WaitDelay = waitDelay
+
+	var (
+		d       Diff
+		readErr error
+		wg      sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d, readErr = ParseDiff(pr)
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	err := cmd.Run()
+	_ = pw.Close()
+	wg.Wait()
+	if err != nil && ctx.Err() != nil {
+		return d, fmt.Errorf("gsutil cancelled: %w", ctx.Err())
+	}
+	if err != nil {
+		return d, err
+	}
+	return d, readErr
+}
+
+// Remove deletes the given objects with `gsutil rm`, passing the URLs on stdin
+// so that long lists do not hit command-line length limits. `gsutil rm` has no
+// dry-run mode, so with opts.DryRun the deletions are only logged.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
+//   - urls: The object URLs to delete.
+//   - opts: Options providing the global gsutil settings.
+//   - log: A logrus.Entry for logging the operation.
+//
+// Returns:
+//   - error: The error gsutil exited with, if any.
+func Remove(ctx context.Context, urls []string, opts Options, log *logrus.Entry) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	if opts.DryRun {
+		for _, u := range urls {
+			log.Infof("would remove %s", u)
+		}
+		return nil
+	}
+	args := append(globalArgs(opts), "rm", "-I")
+	log.Infof("gsutil %s (%d object(s))", strings.Join(args, " "), len(urls))
+
+	return execute(ctx, args, strings.NewReader(strings.Join(urls, "\n")+"\n"), opts, nil, log)
+}
@@ -1,50 +1,345 @@
 package gsutil
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"gcs_sync/internal/ignore"
 	"github.com/sirupsen/logrus"
+	"io"
 	"os"
 	"os/exec"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// Options controls how a single RSync invocation is built.
+type Options struct {
+	// Delete removes files in the destination that are not present in the source (`-d`).
+	Delete bool
+	// Ignore holds regular expressions used to exclude files from synchronization (`-x`).
+	Ignore []ignore.Pattern
+	// FollowSymlinks makes gsutil follow symlinks instead of skipping them (drops `-e`).
+	FollowSymlinks bool
+	// StateDir overrides gsutil's state directory, which holds resumable upload trackers.
+	StateDir string
+	// Checksum compares files by checksum instead of modification time and size (`-c`).
+	Checksum bool
+	// SkipNewer leaves destination files that are newer than their source untouched (`-u`).
+	SkipNewer bool
+	// DryRun only reports what would be transferred or deleted (`-n`).
+	DryRun bool
+	// ExitLevels overrides the log level used when gsutil exits with a given code.
+	// Unmapped codes are logged as errors.
+	ExitLevels map[int]logrus.Level
+	// CompositeThreshold enables parallel composite uploads for files of at least this size
+	// (e.g. "150M"), or disables them with "0". Empty keeps gsutil's boto configuration.
+	// Downloading composite objects needs a compiled crcmod to verify them.
+	CompositeThreshold string
+	// ParallelProcesses overrides the number of processes `-m` runs. Unlike ParallelThreads,
+	// 0 does not leave gsutil's default: DefaultParallelProcesses is always passed.
+	ParallelProcesses int
+	// ParallelThreads sets the number of threads per process `-m` runs; 0 keeps gsutil's default.
+	ParallelThreads int
+	// SlicedThreshold overrides the size from which downloads are split into slices (e.g. "150M");
+	// empty keeps DefaultSlicedThreshold.
+	SlicedThreshold string
+	// BandwidthLimit caps the upload and the download rate of every transfer, in bytes per
+	// second, by running it under trickle; 0 means unlimited.
+	BandwidthLimit int64
+	// Binary is the gsutil executable, a name looked up on PATH or a path; empty means "gsutil".
+	Binary string
+	// GlobalFlags replace the default top-level `-m` when non-nil. They follow the `-o`
+	// options gcs-sync sets, so an `-o` given here wins over them.
+	GlobalFlags []string
+	// Stderr classifies gsutil's stderr lines into log levels; nil uses the built-in patterns.
+	Stderr *Classifier
+	// OnRemove, when set, is called with the URL of every object or file gsutil reports
+	// as deleted, e.g. for the audit log.
+	OnRemove func(url string)
+	// OnCopy, when set, is called for every object or file gsutil reports as copied, with
+	// its source URL and, when the tool names it (gcloud does, gsutil does not), its
+	// destination URL. Dry runs report nothing.
+	OnCopy func(src, dst string)
+}
+
+// RSyncError reports a gsutil invocation that exited with a non-zero code.
+type RSyncError struct {
+	ExitCode int
+	Err      error
+	Stderr   string // the last significant stderr lines, e.g. the actual failure reason
+	Tool     string // the executable that failed; empty means gsutil
+}
+
+// Error implements error.
+func (e *RSyncError) Error() string {
+	tool := e.Tool
+	if tool == "" {
+		tool = "gsutil"
+	}
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s exited with code %d: %s", tool, e.ExitCode, e.Stderr)
+	}
+	return fmt.Sprintf("%s exited with code %d", tool, e.ExitCode)
+}
+
+// Unwrap returns the underlying *exec.ExitError.
+func (e *RSyncError) Unwrap() error { return e.Err }
+
 // RSync performs a recursive synchronization between a source and destination using gsutil.
 // It wraps the `gsutil rsync -r` command with additional options for parallel execution
 // and the ability to ignore specific patterns.
 //
 // Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
 //   - src: The source path or URL to synchronize from.
 //   - dst: The destination path or URL to synchronize to.
-//   - deleteRemote: If true, deletes files in the destination that are not present in the source.
-//   - ignoreRegex: A slice of regular expressions used to exclude files from synchronization.
+//   - opts: Options controlling deletion, exclusions and symlink handling.
 //   - log: A logrus.Entry for logging the operation's progress and any errors.
 //
-// The function does not return any value, but logs the operation's progress and any errors encountered.
-func RSync(src, dst string, deleteRemote bool, ignoreRegex []*regexp.Regexp, log *logrus.Entry) {
-	args := []string{
-		"-m", // parallel
-		"-o", "GSUtil:parallel_process_count=1",
-		"-o", "GSUtil:sliced_object_download_threshold=0",
-		"rsync", "-r",
-		"-e", // ⇐  skip symlinks that point outside the tree / are broken
+// Returns:
+//   - SyncResult: What gsutil reported to have transferred, also when it failed halfway.
+//   - error: The error gsutil exited with, if any. It is also logged.
+func RSync(ctx context.Context, src, dst string, opts Options, log *logrus.Entry) (SyncResult, error) {
+	args := append(globalArgs(opts), rsyncArgs(src, dst, opts)...)
+	var res SyncResult
+	err := run(ctx, args, opts, &res, log)
+	return res, err
+}
+
+// rsyncArgs returns the `rsync` sub-command and its arguments for opts.
+func rsyncArgs(src, dst string, opts Options) []string {
+	args := []string{"rsync", "-r"}
+	if opts.DryRun {
+		args = append(args, "-n")
+	}
+	if !opts.FollowSymlinks {
+		args = append(args, "-e") // ⇐  skip symlinks that point outside the tree / are broken
+	}
+	if opts.Checksum {
+		args = append(args, "-c")
+	}
+	if opts.SkipNewer {
+		args = append(args, "-u")
 	}
-	if deleteRemote {
+	if opts.Delete {
 		args = append(args, "-d")
 	}
-	for _, re := range ignoreRegex {
-		args = append(args, "-x", re.String())
+	for _, expr := range ignore.Expressions(opts.Ignore) {
+		args = append(args, "-x", expr)
 	}
-	args = append(args, src, dst)
+	return append(args, src, dst)
+}
 
-	log.Infof("gsutil %s", strings.Join(args, " "))
+// Copy transfers a single file or object with `gsutil cp`.
+//
+// It is used for explicit per-file transfers, where every file gets its own destination.
+// Only the global options of opts apply; deletion and exclusions are ignored.
+// `gsutil cp` has no dry-run mode, so with opts.DryRun the copy is only logged.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
+//   - src: The source path or URL of the file.
+//   - dst: The full destination path or URL of the file.
+//   - opts: Options providing the global gsutil settings.
+//   - log: A logrus.Entry for logging the operation's progress and any errors.
+//
+// Returns:
+//   - error: The error gsutil exited with, if any. It is also logged.
+func Copy(ctx context.Context, src, dst string, opts Options, log *logrus.Entry) error {
+	if opts.DryRun {
+		log.Infof("would copy %s to %s", src, dst)
+		return nil
+	}
+	args := append(globalArgs(opts), "cp", src, dst)
+	return run(ctx, args, opts, nil, log)
+}
 
-	cmd := exec.Command("gsutil", args...)
-	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+// CopyMany transfers several files into one destination folder with a single `gsutil cp`.
+//
+// Every file keeps its base name, so callers group files by destination folder and keep
+// each group small enough for the command line (see util.Batches). Like Copy, only the
+// global options of opts apply and opts.DryRun only logs the copies.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
+//   - srcs: The source paths or URLs of the files.
+//   - dstDir: The destination folder path or URL; a trailing slash is added if missing.
+//   - opts: Options providing the global gsutil settings.
+//   - log: A logrus.Entry for logging the operation's progress and any errors.
+//
+// Returns:
+//   - error: The error gsutil exited with, if any. It is also logged.
+func CopyMany(ctx context.Context, srcs []string, dstDir string, opts Options, log *logrus.Entry) error {
+	if len(srcs) == 0 {
+		return nil
+	}
+	if !strings.HasSuffix(dstDir, "/") {
+		dstDir += "/"
+	}
+	if opts.DryRun {
+		for _, src := range srcs {
+			log.Infof("would copy %s to %s", src, dstDir)
+		}
+		return nil
+	}
+	args := append(globalArgs(opts), "cp")
+	args = append(append(args, srcs...), dstDir)
+	return run(ctx, args, opts, nil, log)
+}
+
+// Defaults for the gsutil options a rule may override.
+//
+// gsutil -m forks worker processes by default, which hangs or crashes on macOS and other
+// Python builds without a working fork, and multiplies memory use with every rule syncing
+// at once; a single process with threads is safe everywhere, so it is passed even when a
+// rule leaves parallel_process_count unset (GlobalFlags may still override it). Sliced
+// downloads are off because verifying the reassembled object needs a compiled crcmod,
+// without which gsutil refuses the download.
+const (
+	DefaultParallelProcesses = 1
+	DefaultSlicedThreshold   = "0"
+)
+
+// binary returns the gsutil executable of opts.
+func binary(opts Options) string {
+	if opts.Binary == "" {
+		return "gsutil"
+	}
+	return opts.Binary
+}
+
+// globalArgs returns the top-level gsutil options shared by every sub-command.
+func globalArgs(opts Options) []string {
+	processes, sliced := opts.ParallelProcesses, opts.SlicedThreshold
+	if processes <= 0 {
+		processes = DefaultParallelProcesses
+	}
+	if sliced == "" {
+		sliced = DefaultSlicedThreshold
+	}
+	var args []string
+	if opts.GlobalFlags == nil {
+		args = append(args, "-m") // parallel
+	}
+	args = append(args,
+		"-o", "GSUtil:parallel_process_count="+strconv.Itoa(processes),
+		"-o", "GSUtil:sliced_object_download_threshold="+sliced,
+	)
+	if opts.ParallelThreads > 0 {
+		args = append(args, "-o", "GSUtil:parallel_thread_count="+strconv.Itoa(opts.ParallelThreads))
+	}
+	if opts.StateDir != "" {
+		args = append(args, "-o", "GSUtil:state_dir="+opts.StateDir)
+	}
+	if opts.CompositeThreshold != "" {
+		args = append(args, "-o", "GSUtil:parallel_composite_upload_threshold="+opts.CompositeThreshold)
+	}
+	return append(args, opts.GlobalFlags...)
+}
+
+// run executes gsutil with the given arguments, logging the command line and its duration.
+// Every stderr line is logged at the level opts.Stderr assigns to it, and counted into res
+// unless it is nil. A non-zero exit is returned as *RSyncError and logged at the level
+// opts maps its code to.
+func run(ctx context.Context, args []string, opts Options, res *SyncResult, log *logrus.Entry) error {
+	return runTool(ctx, binary(opts), args, nil, opts, res, log)
+}
+
+// runTool is run for any executable, started with env added to the inherited environment.
+func runTool(ctx context.Context, tool string, args, env []string, opts Options, res *SyncResult, log *logrus.Entry) error {
+	log.Infof("%s %s", tool, strings.Join(args, " "))
 
 	start := time.Now()
-	if err := cmd.Run(); err != nil {
-		log.WithError(err).Error("gsutil exited with error")
+	err := executeTool(ctx, tool, args, env, nil, opts, res, log)
+	if res != nil {
+		res.Duration = time.Since(start)
+	}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		log.WithError(err).Warnf("%s was killed", tool)
+	case err != nil:
+		log.WithError(err).Log(exitLevel(err, opts.ExitLevels), tool+" exited with error")
+	}
+	log.Infof("%s finished in %s", tool, time.Since(start).Round(time.Millisecond))
+	return err
+}
+
+// command creates the gsutil process; replaceable for tests.
+var command = exec.CommandContext
+
+// waitDelay bounds how long output is still read after gsutil was killed; its own
+// worker processes may keep the pipes open.
+const waitDelay = 5 * time.Second
+
+// execute runs gsutil, streaming its output line by line into log: stdout at debug level,
+// stderr classified by opts.Stderr. Deletions and copies reported on stderr are passed to
+// opts.OnRemove and opts.OnCopy and, like every transfer, counted into res unless it is nil. A non-zero
+// exit is returned as *RSyncError carrying the tail of stderr.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil.
+//   - args: The gsutil arguments.
+//   - stdin: The process input, or nil for none.
+//   - opts: Options providing the stderr classifier.
+//   - res: Receives the transfer counts; may be nil.
+//   - log: The rule's logrus.Entry; every output line carries its fields.
+//
+// Returns:
+//   - error: An error if gsutil could not be started or failed.
+func execute(ctx context.Context, args []string, stdin io.Reader, opts Options, res *SyncResult, log *logrus.Entry) error {
+	return executeTool(ctx, binary(opts), args, nil, stdin, opts, res, log)
+}
+
+// executeTool is execute for any executable, started with env added to the inherited
+// environment.
+func executeTool(ctx context.Context, tool string, args, env []string, stdin io.Reader, opts Options, res *SyncResult, log *logrus.Entry) error {
+	stdout, stderr := newStdoutLogger(log), newLineLogger(opts.Stderr, log)
+	stderr.removed, stderr.copied, stderr.result = opts.OnRemove, opts.OnCopy, res
+	name, argv := throttled(tool, args, opts.BandwidthLimit)
+	cmd := command(ctx, name, argv...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	cmd.WaitDelay = waitDelay
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	if ctx.Err() != nil && err != nil {
+		return fmt.Errorf("%s cancelled: %w", tool, ctx.Err())
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		err = &RSyncError{ExitCode: ee.ExitCode(), Err: ee, Stderr: stderr.Tail(), Tool: tool}
+	}
+	return err
+}
+
+// Trickle is the userspace bandwidth shaper transfers run under when throttled. gsutil and
+// gcloud have no rate limit of their own.
+const Trickle = "trickle"
+
+// throttled returns the command line running tool with args, wrapped in trickle when
+// limit (bytes per second) is set. trickle counts in KB/s and applies the limit to each
+// process, so with parallel_process_count above 1 the total rate is a multiple of it.
+func throttled(tool string, args []string, limit int64) (string, []string) {
+	if limit <= 0 {
+		return tool, args
+	}
+	kb := strconv.FormatInt(max(limit/1024, 1), 10)
+	return Trickle, append([]string{"-s", "-u", kb, "-d", kb, tool}, args...)
+}
+
+// exitLevel returns the log level for a failed invocation.
+func exitLevel(err error, levels map[int]logrus.Level) logrus.Level {
+	var re *RSyncError
+	if errors.As(err, &re) {
+		if lvl, ok := levels[re.ExitCode]; ok {
+			return lvl
+		}
 	}
-	log.Infof("gsutil finished in %s", time.Since(start).Round(time.Millisecond))
+	return logrus.ErrorLevel
 }
@@ -0,0 +1,423 @@
+package gsutil
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/ignore"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeGsutil puts an executable `gsutil` running the shell script body first on PATH.
+// The script sees the arguments gcs-sync passes as "$@".
+func fakeGsutil(t *testing.T, body string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gsutil is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gsutil"), []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// ruleLog returns a debug-level entry carrying a rule field, and the hook recording it.
+func ruleLog() (*logrus.Entry, *test.Hook) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	return logger.WithField("rule", "/data"), hook
+}
+
+// logged returns the first entry logged at lvl containing substr.
+func logged(hook *test.Hook, lvl logrus.Level, substr string) *logrus.Entry {
+	for _, e := range hook.AllEntries() {
+		if e.Level == lvl && strings.Contains(e.Message, substr) {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestGlobalArgs(t *testing.T) {
+	defaults := []string{
+		"-o", "GSUtil:parallel_process_count=1",
+		"-o", "GSUtil:sliced_object_download_threshold=0",
+	}
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"defaults", Options{}, append([]string{"-m"}, defaults...)},
+		{"composite uploads on", Options{CompositeThreshold: "150M"}, append(append([]string{"-m"}, defaults...),
+			"-o", "GSUtil:parallel_composite_upload_threshold=150M")},
+		{"composite uploads off", Options{CompositeThreshold: "0"}, append(append([]string{"-m"}, defaults...),
+			"-o", "GSUtil:parallel_composite_upload_threshold=0")},
+		{"process count", Options{ParallelProcesses: 4}, []string{"-m",
+			"-o", "GSUtil:parallel_process_count=4",
+			"-o", "GSUtil:sliced_object_download_threshold=0"}},
+		{"negative process count keeps the default", Options{ParallelProcesses: -1}, append([]string{"-m"}, defaults...)},
+		{"thread count", Options{ParallelThreads: 8}, append(append([]string{"-m"}, defaults...),
+			"-o", "GSUtil:parallel_thread_count=8")},
+		{"sliced threshold", Options{SlicedThreshold: "150M"}, []string{"-m",
+			"-o", "GSUtil:parallel_process_count=1",
+			"-o", "GSUtil:sliced_object_download_threshold=150M"}},
+		{"state dir", Options{StateDir: "/var/lib/gcs-sync"}, append(append([]string{"-m"}, defaults...),
+			"-o", "GSUtil:state_dir=/var/lib/gcs-sync")},
+		{"global flags replace -m", Options{GlobalFlags: []string{"-q", "-o", "Boto:num_retries=3"}}, append(defaults,
+			"-q", "-o", "Boto:num_retries=3")},
+		{"empty global flags drop -m", Options{GlobalFlags: []string{}}, defaults},
+		// the later -o wins in gsutil, so global flags can still restore a higher process count
+		{"global flags override the process count", Options{GlobalFlags: []string{"-m", "-o", "GSUtil:parallel_process_count=8"}},
+			append(append([]string{}, defaults...), "-m", "-o", "GSUtil:parallel_process_count=8")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := globalArgs(tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("globalArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRsyncArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"defaults skip symlinks", Options{}, []string{"rsync", "-r", "-e", "src", "dst"}},
+		{"follow symlinks", Options{FollowSymlinks: true}, []string{"rsync", "-r", "src", "dst"}},
+		{"every flag", Options{DryRun: true, Checksum: true, SkipNewer: true, Delete: true},
+			[]string{"rsync", "-r", "-n", "-e", "-c", "-u", "-d", "src", "dst"}},
+		{"exclusions", Options{Ignore: []ignore.Pattern{{Regexp: regexp.MustCompile(`^a$`)}, {Regexp: regexp.MustCompile(`^b$`)}}},
+			[]string{"rsync", "-r", "-e", "-x", `^a$`, "-x", `^b$`, "src", "dst"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rsyncArgs("src", "dst", tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rsyncArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRSyncArgVector checks the arguments gsutil is actually started with: -n only in
+// dry-run and -d only when deleting, both together for a preview of the deletions.
+func TestRSyncArgVector(t *testing.T) {
+	global := []string{"-m", "-o", "GSUtil:parallel_process_count=1", "-o", "GSUtil:sliced_object_download_threshold=0"}
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"default", Options{}, []string{"rsync", "-r", "-e", "/data", "gs://bucket"}},
+		{"dry run", Options{DryRun: true}, []string{"rsync", "-r", "-n", "-e", "/data", "gs://bucket"}},
+		{"delete", Options{Delete: true}, []string{"rsync", "-r", "-e", "-d", "/data", "gs://bucket"}},
+		{"dry run with delete", Options{DryRun: true, Delete: true}, []string{"rsync", "-r", "-n", "-e", "-d", "/data", "gs://bucket"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := filepath.Join(t.TempDir(), "args")
+			t.Setenv("FAKE_GSUTIL_ARGS", argsFile)
+			fakeGsutil(t, `printf "%s\n" "$@" > "$FAKE_GSUTIL_ARGS"`+"\n")
+			log, _ := ruleLog()
+			if _, err := RSync(context.Background(), "/data", "gs://bucket", tt.opts, log); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+			if want := append(append([]string(nil), global...), tt.want...); !reflect.DeepEqual(got, want) {
+				t.Errorf("gsutil started with %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRSyncLogsOutput(t *testing.T) {
+	fakeGsutil(t, `echo "stdout line"
+echo "Copying file:///data/a.txt [Content-Type=text/plain]..." >&2
+echo "Operation completed over 1 objects/2.0 KiB." >&2
+`)
+	log, hook := ruleLog()
+	res, err := RSync(context.Background(), "/data", "gs://bucket", Options{}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 1 || res.Bytes != 2048 {
+		t.Errorf("RSync = %+v, want 1 copied object of 2048 bytes", res)
+	}
+	tests := []struct {
+		lvl    logrus.Level
+		line   string
+		stream string
+	}{
+		{logrus.DebugLevel, "stdout line", "stdout"},
+		{logrus.DebugLevel, "Copying file:///data/a.txt", "stderr"},
+		{logrus.DebugLevel, "Operation completed", "stderr"},
+	}
+	for _, tt := range tests {
+		e := logged(hook, tt.lvl, tt.line)
+		if e == nil {
+			t.Errorf("%q was not logged at %s", tt.line, tt.lvl)
+			continue
+		}
+		if e.Data["rule"] != "/data" || e.Data["stream"] != tt.stream {
+			t.Errorf("%q logged with fields %v, want rule=/data stream=%s", tt.line, e.Data, tt.stream)
+		}
+	}
+}
+
+func TestRSyncError(t *testing.T) {
+	fakeGsutil(t, `echo "Building synchronization state..." >&2
+echo "AccessDeniedException: 403 caller does not have storage.objects.list access" >&2
+exit 1
+`)
+	log, hook := ruleLog()
+	_, err := RSync(context.Background(), "/data", "gs://bucket", Options{}, log)
+	var re *RSyncError
+	if !errors.As(err, &re) {
+		t.Fatalf("RSync error = %v, want *RSyncError", err)
+	}
+	if re.ExitCode != 1 || !strings.Contains(re.Stderr, "AccessDeniedException: 403") {
+		t.Errorf("RSyncError = %+v, want exit code 1 and the 403 in its stderr tail", re)
+	}
+	if e := logged(hook, logrus.ErrorLevel, "AccessDeniedException"); e == nil || e.Data["rule"] != "/data" {
+		t.Errorf("the 403 was not logged as an error with the rule field: %v", e)
+	}
+}
+
+func TestRSyncExitLevels(t *testing.T) {
+	levels := map[int]logrus.Level{3: logrus.WarnLevel, 4: logrus.DebugLevel}
+	tests := []struct {
+		code string
+		want logrus.Level
+	}{
+		{"3", logrus.WarnLevel},
+		{"4", logrus.DebugLevel},
+		{"1", logrus.ErrorLevel}, // unmapped
+	}
+	for _, tt := range tests {
+		t.Run("exit "+tt.code, func(t *testing.T) {
+			fakeGsutil(t, "exit "+tt.code+"\n")
+			log, hook := ruleLog()
+			_, err := RSync(context.Background(), "/data", "gs://bucket", Options{ExitLevels: levels}, log)
+			var re *RSyncError
+			if !errors.As(err, &re) || strconv.Itoa(re.ExitCode) != tt.code {
+				t.Fatalf("RSync = %v, want an RSyncError with exit code %s", err, tt.code)
+			}
+			if e := logged(hook, tt.want, "exited with error"); e == nil {
+				t.Errorf("exit code %s was not logged at %s", tt.code, tt.want)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    []string
+		wantErr string
+	}{
+		{"objects", `echo gs://bucket/a.txt; echo gs://bucket/dir/b.txt`, []string{"gs://bucket/a.txt", "gs://bucket/dir/b.txt"}, ""},
+		{"no objects", `echo "CommandException: One or more URLs matched no objects." >&2; exit 1`, nil, ""},
+		{"failure", `echo "AccessDeniedException: 403 Forbidden" >&2; exit 1`, nil, "AccessDeniedException: 403 Forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGsutil(t, tt.script+"\n")
+			log, hook := ruleLog()
+			got, err := Objects(context.Background(), "gs://bucket/", Options{}, log)
+			if tt.wantErr == "" {
+				if err != nil || !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Objects = %q, %v, want %q, nil", got, err, tt.want)
+				}
+				return
+			}
+			var re *RSyncError
+			if !errors.As(err, &re) || !strings.Contains(re.Stderr, tt.wantErr) {
+				t.Fatalf("Objects error = %v, want *RSyncError with %q", err, tt.wantErr)
+			}
+			if e := logged(hook, logrus.ErrorLevel, tt.wantErr); e == nil || e.Data["rule"] != "/data" {
+				t.Errorf("%q was not logged as an error with the rule field", tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListArgs(t *testing.T) {
+	fakeGsutil(t, "echo gs://bucket/a#7\n")
+	log, hook := ruleLog()
+	gens, err := Generations(context.Background(), "gs://bucket/dir/", Options{GlobalFlags: []string{}}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gens, map[string]int64{"gs://bucket/a": 7}) {
+		t.Errorf("Generations = %v", gens)
+	}
+	if logged(hook, logrus.DebugLevel, "ls -a gs://bucket/dir/**") == nil {
+		t.Error("Generations did not run `ls -a gs://bucket/dir/**`")
+	}
+}
+
+func TestOnRemove(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		run    func(opts Options, log *logrus.Entry) error
+		want   []string
+	}{
+		{"rsync -d", `echo "Removing gs://bucket/old.txt" >&2; echo "Would remove gs://bucket/kept.txt" >&2`,
+			func(opts Options, log *logrus.Entry) error {
+				_, err := RSync(context.Background(), "/data", "gs://bucket", opts, log)
+				return err
+			}, []string{"gs://bucket/old.txt"}},
+		{"rm", `while read -r url; do echo "Removing $url..." >&2; done`,
+			func(opts Options, log *logrus.Entry) error {
+				return Remove(context.Background(), []string{"gs://bucket/a", "gs://bucket/b"}, opts, log)
+			}, []string{"gs://bucket/a", "gs://bucket/b"}},
+		{"rm dry run", `echo "should not run" >&2; exit 1`,
+			func(opts Options, log *logrus.Entry) error {
+				opts.DryRun = true
+				return Remove(context.Background(), []string{"gs://bucket/a"}, opts, log)
+			}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGsutil(t, tt.script+"\n")
+			log, _ := ruleLog()
+			var got []string
+			opts := Options{OnRemove: func(url string) { got = append(got, url) }}
+			if err := tt.run(opts, log); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OnRemove got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGsutilPath runs a gsutil outside PATH, as gsutil_path does, and checks that it gets
+// the configured global flags instead of -m.
+func TestGsutilPath(t *testing.T) {
+	fakeGsutil(t, "exit 1\n") // the one on PATH must not run
+	dir := t.TempDir()
+	bin, out := filepath.Join(dir, "gsutil-custom"), filepath.Join(dir, "args")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" > "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"custom binary", Options{Binary: bin}, []string{"-m",
+			"-o", "GSUtil:parallel_process_count=1", "-o", "GSUtil:sliced_object_download_threshold=0",
+			"rsync", "-r", "-e", "/data", "gs://bucket"}},
+		{"custom binary and flags", Options{Binary: bin, GlobalFlags: []string{"-q", "-o", "Credentials:gs_json_host=localhost"}}, []string{
+			"-o", "GSUtil:parallel_process_count=1", "-o", "GSUtil:sliced_object_download_threshold=0",
+			"-q", "-o", "Credentials:gs_json_host=localhost",
+			"rsync", "-r", "-e", "/data", "gs://bucket"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, hook := ruleLog()
+			if _, err := RSync(context.Background(), "/data", "gs://bucket", tt.opts, log); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s started with %q, want %q", bin, got, tt.want)
+			}
+			if logged(hook, logrus.InfoLevel, bin+" ") == nil {
+				t.Errorf("the command line does not name %s", bin)
+			}
+		})
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int64
+		wantTool string
+		wantArgs []string
+	}{
+		{"unlimited", 0, "gsutil", []string{"rsync", "a", "b"}},
+		{"negative is unlimited", -1, "gsutil", []string{"rsync", "a", "b"}},
+		{"kilobytes per second", 512 * 1024, Trickle, []string{"-s", "-u", "512", "-d", "512", "gsutil", "rsync", "a", "b"}},
+		{"rounded down", 1536, Trickle, []string{"-s", "-u", "1", "-d", "1", "gsutil", "rsync", "a", "b"}},
+		{"at least 1 KB/s", 100, Trickle, []string{"-s", "-u", "1", "-d", "1", "gsutil", "rsync", "a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, args := throttled("gsutil", []string{"rsync", "a", "b"}, tt.limit)
+			if tool != tt.wantTool || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("throttled = %s %q, want %s %q", tool, args, tt.wantTool, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// TestRSyncThrottled checks that a bandwidth limit starts gsutil through trickle.
+func TestRSyncThrottled(t *testing.T) {
+	fakeGsutil(t, "exit 1\n") // must only run through trickle
+	dir := t.TempDir()
+	out := filepath.Join(dir, "args")
+	if err := os.WriteFile(filepath.Join(dir, Trickle), []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" > "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	log, _ := ruleLog()
+	if _, err := RSync(context.Background(), "/data", "gs://bucket", Options{BandwidthLimit: 2 << 20, GlobalFlags: []string{}}, log); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-s", "-u", "2048", "-d", "2048", "gsutil",
+		"-o", "GSUtil:parallel_process_count=1", "-o", "GSUtil:sliced_object_download_threshold=0",
+		"rsync", "-r", "-e", "/data", "gs://bucket"}
+	if got := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("trickle started with %q, want %q", got, want)
+	}
+}
+
+func TestCopyDryRun(t *testing.T) {
+	fakeGsutil(t, "exit 1\n") // must not run
+	log, hook := ruleLog()
+	opts := Options{DryRun: true}
+	if err := Copy(context.Background(), "/data/a.txt", "gs://bucket/a.txt", opts, log); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyMany(context.Background(), []string{"/data/b.txt", "/data/c.txt"}, "gs://bucket/dir", opts, log); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"would copy /data/a.txt to gs://bucket/a.txt",
+		"would copy /data/b.txt to gs://bucket/dir/",
+		"would copy /data/c.txt to gs://bucket/dir/",
+	} {
+		if logged(hook, logrus.InfoLevel, want) == nil {
+			t.Errorf("%q not logged", want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package gsutil
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generations lists every object below a cloud URL together with its generation number.
+//
+// A generation changes whenever an object is overwritten, so comparing two listings is a
+// cheap way to tell whether anything changed remotely without running a full rsync.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
+//   - url: The bucket or prefix, e.g. gs://bucket/path.
+//   - opts: Options providing the global gsutil settings.
+//   - log: A logrus.Entry for logging the operation.
+//
+// Returns:
+//   - map[string]int64: Object URL (without generation) to generation.
+//   - error: An error if gsutil failed.
+func Generations(ctx context.Context, url string, opts Options, log *logrus.Entry) (map[string]int64, error) {
+	var gens map[string]int64
+	err := list(ctx, url, []string{"-a"}, opts, log, func(r io.Reader) (err error) {
+		gens, err = ParseGenerations(r)
+		return err
+	})
+	return gens, err
+}
+
+// UpdateTimes lists every object below a cloud URL together with its last update time.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
+//   - url: The bucket or prefix, e.g. gs://bucket/path.
+//   - opts: Options providing the global gsutil settings.
+//   - log: A logrus.Entry for logging the operation.
+//
+// Returns:
+//   - map[string]time.Time: Object URL to update time.
+//   - error: An error if gsutil failed.
+func UpdateTimes(ctx context.Context, url string, opts Options, log *logrus.Entry) (map[string]time.Time, error) {
+	var times map[string]time.Time
+	err := list(ctx, url, []string{"-l"}, opts, log, func(r io.Reader) (err error) {
+		times, err = ParseLongListing(r)
+		return err
+	})
+	return times, err
+}
+
+// Objects lists the URLs of every object below a cloud URL.
+//
+// Parameters:
+//   - ctx: Cancelling it kills gsutil, e.g. on shutdown.
+//   - url: The bucket or prefix, e.g. gs://bucket/path.
+//   - opts: Options providing the global gsutil settings.
+//   - log: A logrus.Entry for logging the operation.
+//
+// Returns:
+//   - []string: The object URLs in listing order.
+//   - error: An error if gsutil failed.
+func Objects(ctx context.Context, url string, opts Options, log *logrus.Entry) ([]string, error) {
+	var urls []string
+	err := list(ctx, url, nil, opts, log, func(r io.Reader) (err error) {
+		urls, err = ParseObjects(r)
+		return err
+	})
+	return urls, err
+}
+
+// list runs `gsutil ls <flags> <url>/**` and hands its stdout to parse.
+// A prefix without objects is not an error. Otherwise, a non-zero exit logs gsutil's
+// stderr through log and is returned as *RSyncError carrying its tail.
+func list(ctx context.Context, url string, flags []string, opts Options, log *logrus.Entry, parse func(io.Reader) error) error {
+	args := append(globalArgs(opts), "ls")
+	args = append(args, flags...)
+	args = append(args, strings.TrimRight(url, "/")+"/**")
+	log.Debugf("gsutil %s", strings.Join(args, " "))
+
+	cmd := command(ctx, binary(opts), args...)
+	cmd.WaitDelay = waitDelay
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	parseErr := parse(out)
+	_, _ = io.Copy(io.Discard, out)
+	err = cmd.Wait()
+	if err == nil {
+		return parseErr
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("gsutil cancelled: %w", ctx.Err())
+	}
+	if strings.Contains(stderr.String(), "matched no objects") {
+		return nil
+	}
+	lines := newLineLogger(opts.Stderr, log)
+	_, _ = lines.Write([]byte(stderr.String()))
+	lines.Flush()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return &RSyncError{ExitCode: ee.ExitCode(), Err: ee, Stderr: lines.Tail()}
+	}
+	return err
+}
+
+// ParseObjects parses plain `gsutil ls` output, one object URL per line.
+// Lines that are not URLs are ignored.
+func ParseObjects(r io.Reader) ([]string, error) {
+	var urls []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.Contains(line, "://") && !strings.HasSuffix(line, "/") {
+			urls = append(urls, line)
+		}
+	}
+	return urls, sc.Err()
+}
+
+// ParseGenerations parses `gsutil ls -a` output, one `gs://bucket/object#generation` per line.
+// Lines without a generation suffix are ignored.
+func ParseGenerations(r io.Reader) (map[string]int64, error) {
+	gens := make(map[string]int64)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		i := strings.LastIndex(line, "#")
+		if i <= 0 {
+			continue
+		}
+		gen, err := strconv.ParseInt(line[i+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		gens[line[:i]] = gen
+	}
+	return gens, sc.Err()
+}
+
+// ParseLongListing parses `gsutil ls -l` output, whose object lines look like
+//
+//	1234  2024-05-01T10:00:00Z  gs://bucket/object
+//
+// The trailing TOTAL line and any other line is ignored.
+func ParseLongListing(r io.Reader) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) != 3 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, f[1])
+		if err != nil {
+			continue
+		}
+		times[f[2]] = t
+	}
+	return times, sc.Err()
+}
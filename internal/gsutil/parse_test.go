@@ -0,0 +1,248 @@
+package gsutil
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rsyncOutput is the stderr of a gsutil 5 `rsync -r -d` run.
+const rsyncOutput = `Building synchronization state...
+Starting synchronization...
+Copying file:///data/a.txt [Content-Type=text/plain]...
+Copying file:///data/dir/b.bin [Content-Type=application/octet-stream]...
+Skipping symlink /data/link
+Removing gs://bucket/old.txt
+/ [2/2 files][  1.5 KiB/  1.5 KiB] 100% Done
+Operation completed over 2 objects/1.5 KiB.
+`
+
+func TestSyncResultObserve(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   SyncResult
+	}{
+		{"full run", rsyncOutput, SyncResult{Copied: 2, Removed: 1, Skipped: 1, Bytes: 1536}},
+		{"dry run", "Would copy file:///a to gs://b/a\nWould remove gs://b/x\n", SyncResult{Copied: 1, Removed: 1}},
+		{"no summary line", "Copying gs://b/a...\n", SyncResult{Copied: 1}},
+		{"plain bytes", "Operation completed over 1 objects/512.0 B.\n", SyncResult{Bytes: 512}},
+		{"single object", "Operation completed over 1 object/2.0 MiB.\n", SyncResult{Bytes: 2 << 20}},
+		{"unknown lines", "==> NOTE: something new\nsome future line\n", SyncResult{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got SyncResult
+			for _, line := range strings.Split(tt.output, "\n") {
+				got.observe(line)
+			}
+			if got != tt.want {
+				t.Errorf("observe = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncResultAdd(t *testing.T) {
+	r := SyncResult{Copied: 1, Removed: 2, Skipped: 3, Bytes: 4, Duration: time.Second}
+	r.Add(SyncResult{Copied: 10, Removed: 20, Skipped: 30, Bytes: 40, Duration: time.Minute})
+	want := SyncResult{Copied: 11, Removed: 22, Skipped: 33, Bytes: 44, Duration: time.Minute + time.Second}
+	if r != want {
+		t.Errorf("Add = %+v, want %+v", r, want)
+	}
+}
+
+func TestParseRemoval(t *testing.T) {
+	tests := []struct {
+		line string
+		url  string
+		ok   bool
+	}{
+		{"Removing gs://bucket/old.txt", "gs://bucket/old.txt", true},
+		{"Removing gs://bucket/old.txt...", "gs://bucket/old.txt", true},
+		{"  Removing file:///data/x  ", "file:///data/x", true},
+		{"Would remove gs://bucket/old.txt", "", false},
+		{"Removing stale lock", "", false},
+		{"Copying gs://bucket/a", "", false},
+	}
+	for _, tt := range tests {
+		url, ok := ParseRemoval(tt.line)
+		if url != tt.url || ok != tt.ok {
+			t.Errorf("ParseRemoval(%q) = %q, %v, want %q, %v", tt.line, url, ok, tt.url, tt.ok)
+		}
+	}
+}
+
+func TestParseCopy(t *testing.T) {
+	tests := []struct {
+		line     string
+		src, dst string
+		ok       bool
+	}{
+		{"Copying gs://bucket/a.txt [Content-Type=text/plain]...", "gs://bucket/a.txt", "", true},
+		{"Copying gs://bucket/a.txt...", "gs://bucket/a.txt", "", true},
+		{"Copying gs://b/a.txt to file:///data/a.txt", "gs://b/a.txt", "file:///data/a.txt", true},
+		{"Copying gs://b/how to cook.txt [Content-Type=text/plain]...", "gs://b/how to cook.txt", "", true},
+		{"Copying gs://b/how to cook.txt to file:///d/how to cook.txt", "gs://b/how to cook.txt", "file:///d/how to cook.txt", true},
+		{"Would copy gs://b/a to file:///d/a", "", "", false},
+		{"Copying 3 files", "", "", false},
+	}
+	for _, tt := range tests {
+		src, dst, ok := ParseCopy(tt.line)
+		if src != tt.src || dst != tt.dst || ok != tt.ok {
+			t.Errorf("ParseCopy(%q) = %q, %q, %v, want %q, %q, %v", tt.line, src, dst, ok, tt.src, tt.dst, tt.ok)
+		}
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	out := `Building synchronization state...
+Would copy file:///data/a.txt to gs://bucket/a.txt
+Would remove gs://bucket/old.txt
+Starting synchronization...
+`
+	d, err := ParseDiff(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Diff{
+		Copies: []Transfer{
+			{Src: "file:///data/a.txt", Dst: "gs://bucket/a.txt"},
+		},
+		Removals: []string{"gs://bucket/old.txt"},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("ParseDiff = %+v, want %+v", d, want)
+	}
+	if d.Empty() {
+		t.Error("Empty() = true for a diff with changes")
+	}
+	if !(Diff{}).Empty() {
+		t.Error("Empty() = false for the zero diff")
+	}
+}
+
+func TestParseObjects(t *testing.T) {
+	out := "gs://bucket/a.txt\ngs://bucket/dir/\n\nCommandException: nothing\ngs://bucket/dir/b.txt\n"
+	got, err := ParseObjects(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"gs://bucket/a.txt", "gs://bucket/dir/b.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseObjects = %q, want %q", got, want)
+	}
+}
+
+func TestParseGenerations(t *testing.T) {
+	out := "gs://bucket/a.txt#1700000000000001\ngs://bucket/b#c.txt#42\ngs://bucket/no-generation\ngs://bucket/bad#x\n"
+	got, err := ParseGenerations(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"gs://bucket/a.txt": 1700000000000001, "gs://bucket/b#c.txt": 42}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGenerations = %v, want %v", got, want)
+	}
+}
+
+func TestParseLongListing(t *testing.T) {
+	out := `      1234  2024-05-01T10:00:00Z  gs://bucket/a.txt
+         0  2024-05-02T11:30:00Z  gs://bucket/dir/
+TOTAL: 2 objects, 1234 bytes (1.21 KiB)
+      5678  not-a-time  gs://bucket/b.txt
+`
+	got, err := ParseLongListing(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Time{
+		"gs://bucket/a.txt": time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		"gs://bucket/dir/":  time.Date(2024, 5, 2, 11, 30, 0, 0, time.UTC),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseLongListing = %v, want %v", got, want)
+	}
+	for url, ts := range want {
+		if !got[url].Equal(ts) {
+			t.Errorf("ParseLongListing[%q] = %s, want %s", url, got[url], ts)
+		}
+	}
+}
+
+func TestClassifierLevel(t *testing.T) {
+	c, err := NewClassifier([]string{`^custom failure`}, []string{`^ResumableUploadException: retrying`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"Copying file:///a [Content-Type=text/plain]...", "debug"},
+		{"AccessDeniedException: 403 Forbidden", "error"},
+		{"ResumableUploadException: retrying", "debug"},
+		{"custom failure here", "error"},
+		{"some notice", "info"},
+		// built-in patterns
+		{"Building synchronization state...", "debug"},
+		{"Starting synchronization...", "debug"},
+		{"[3/10 files][ 1.2 MiB/ 4.0 MiB]  30% Done", "debug"},
+		{"==> NOTE: You are performing a sequence of gsutil operations", "debug"},
+		{"ERROR: something went wrong", "error"},
+		{"Failed to copy a.txt", "error"},
+		{"open /data/x: permission denied", "error"},
+		{"hit an Error while listing", "error"},
+		{"0 errors", "info"}, // whole words only
+		{"terror", "info"},
+		// noise wins over error patterns
+		{"Copying file:///Exception.txt [Content-Type=text/plain]...", "debug"},
+	}
+	for _, tt := range tests {
+		if got := c.Level(tt.line).String(); got != tt.want {
+			t.Errorf("Level(%q) = %s, want %s", tt.line, got, tt.want)
+		}
+	}
+	for _, exprs := range [][2][]string{{{"("}, nil}, {nil, {"[a-"}}} {
+		if _, err := NewClassifier(exprs[0], exprs[1]); err == nil {
+			t.Errorf("NewClassifier(%q, %q) succeeded", exprs[0], exprs[1])
+		}
+	}
+}
+
+// TestLineLogger feeds stderr in arbitrary chunks and checks the lines logged, their
+// levels and the tail kept for error messages.
+func TestLineLogger(t *testing.T) {
+	log, hook := ruleLog()
+	w := newLineLogger(nil, log)
+	chunks := []string{"Building synch", "ronization state...\n", "notice 1\r[1/2 files]\r\n\n", "AccessDenied", "Exception: 403\n"}
+	for i := 2; i <= 6; i++ {
+		chunks = append(chunks, fmt.Sprintf("notice %d\n", i))
+	}
+	chunks = append(chunks, "trailing error")
+	for _, c := range chunks {
+		w.Write([]byte(c))
+	}
+	w.Flush()
+
+	var got []string
+	for _, e := range hook.AllEntries() {
+		if e.Data["stream"] != "stderr" {
+			t.Errorf("%q logged without the stderr stream field", e.Message)
+		}
+		got = append(got, e.Level.String()+" "+e.Message)
+	}
+	want := []string{
+		"debug Building synchronization state...", "info notice 1", "debug [1/2 files]",
+		"error AccessDeniedException: 403", "info notice 2", "info notice 3", "info notice 4",
+		"info notice 5", "info notice 6", "error trailing error",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("logged\n%q\nwant\n%q", got, want)
+	}
+	if tail := w.Tail(); tail != "notice 3; notice 4; notice 5; notice 6; trailing error" {
+		t.Errorf("Tail = %q", tail)
+	}
+}
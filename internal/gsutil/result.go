@@ -0,0 +1,66 @@
+package gsutil
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyncResult summarises what a single gsutil rsync did, as far as its output tells.
+//
+// The counts come from the per-object lines gsutil writes to stderr; dry runs count the
+// objects that would be copied or removed. Bytes comes from the closing
+// "Operation completed over N objects/SIZE" line, which not every gsutil version or
+// invocation prints, so it is 0 when missing. Likewise, unknown lines are simply not
+// counted.
+type SyncResult struct {
+	Copied   int           // objects or files copied (or that would be copied)
+	Removed  int           // objects or files deleted (or that would be deleted)
+	Skipped  int           // objects or files gsutil reported as skipped, e.g. symlinks
+	Bytes    int64         // bytes transferred, approximated from gsutil's rounded summary
+	Duration time.Duration // how long gsutil ran
+}
+
+// Add accumulates the counts and duration of another result, e.g. for a rule syncing
+// several scopes.
+func (r *SyncResult) Add(o SyncResult) {
+	r.Copied += o.Copied
+	r.Removed += o.Removed
+	r.Skipped += o.Skipped
+	r.Bytes += o.Bytes
+	r.Duration += o.Duration
+}
+
+// completed matches gsutil's summary line, e.g. "Operation completed over 12 objects/3.4 MiB."
+var completed = regexp.MustCompile(`^Operation completed over \d+ objects?/([\d.]+) ?([KMGTP]i)?B`)
+
+// units maps the binary prefixes gsutil prints to their factors.
+var units = map[string]float64{
+	"":   1,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+}
+
+// observe counts a single stderr line.
+func (r *SyncResult) observe(line string) {
+	switch {
+	case strings.HasPrefix(line, "Copying "), strings.HasPrefix(line, "Would copy "):
+		r.Copied++
+	case strings.HasPrefix(line, "Removing "), strings.HasPrefix(line, "Would remove "):
+		r.Removed++
+	case strings.HasPrefix(line, "Skipping "):
+		r.Skipped++
+	default:
+		m := completed.FindStringSubmatch(line)
+		if m == nil {
+			return
+		}
+		if n, err := strconv.ParseFloat(m[1], 64); err == nil {
+			r.Bytes = int64(n * units[m[2]])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package gsutil
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultRetryBackoff is the delay before the first retry when Retry.Backoff is not set.
+const DefaultRetryBackoff = time.Second
+
+// maxRetryBackoff caps the exponential growth of the delay between retries.
+const maxRetryBackoff = 5 * time.Minute
+
+// DefaultRetryJitter is the share of every delay that is randomised when a rule does not
+// set retry_jitter.
+const DefaultRetryJitter = 0.5
+
+// after waits like time.After; replaceable for tests.
+var after = time.After
+
+// Retry repeats gsutil invocations that exit non-zero, e.g. because of a network blip or
+// a 503 from GCS. Failures to start gsutil at all, and invocations killed by a cancelled
+// context, are not retried. The zero value runs every operation exactly once.
+type Retry struct {
+	// Max is the number of retries after the first attempt.
+	Max int
+	// Backoff is the delay before the first retry; it doubles with every further retry
+	// (up to five minutes). DefaultRetryBackoff is used when it is not positive.
+	Backoff time.Duration
+	// Jitter is the share of every delay that is randomised, between 0 and 1: a delay d is
+	// shortened by a random amount of up to Jitter·d. 0 waits exactly d.
+	Jitter float64
+	// Rand returns a random duration in [0, n); nil uses math/rand/v2. Tests pass a
+	// deterministic source.
+	Rand func(n time.Duration) time.Duration
+}
+
+// Do runs op until it succeeds, fails with an error that is not an *RSyncError, or the
+// retries are used up.
+//
+// The n-th retry waits between (1−Jitter) and all of Backoff·2ⁿ⁻¹, so that rules failing
+// at the same moment do not retry in lockstep.
+//
+// Parameters:
+//   - ctx: Cancelling it abandons pending retries, e.g. on shutdown.
+//   - op: The gsutil operation.
+//   - log: A logrus.Entry for reporting retries.
+//
+// Returns:
+//   - error: nil on success, otherwise the error of the last attempt.
+func (r Retry) Do(ctx context.Context, op func() error, log *logrus.Entry) error {
+	err := op()
+	for attempt := 1; attempt <= r.Max && err != nil; attempt++ {
+		var re *RSyncError
+		if !errors.As(err, &re) || ctx.Err() != nil {
+			return err
+		}
+		d := r.delay(attempt)
+		log.WithError(err).Warnf("retrying in %s (%d/%d)", d.Round(time.Millisecond), attempt, r.Max)
+		select {
+		case <-ctx.Done():
+			log.Info("shutting down, abandoning retries")
+			return err
+		case <-after(d):
+		}
+		err = op()
+	}
+	return err
+}
+
+// delay returns the randomised wait before the given retry (1-based).
+func (r Retry) delay(attempt int) time.Duration {
+	d := r.Backoff
+	if d <= 0 {
+		d = DefaultRetryBackoff
+	}
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxRetryBackoff)
+	band := time.Duration(float64(d) * min(max(r.Jitter, 0), 1))
+	if band <= 0 {
+		return d
+	}
+	random := r.Rand
+	if random == nil {
+		random = rand.N[time.Duration]
+	}
+	return d - band + random(band+1)
+}
@@ -0,0 +1,145 @@
+package gsutil
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordWaits replaces after with a wait that returns at once and records its duration.
+func recordWaits(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	orig := after
+	after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	t.Cleanup(func() { after = orig })
+	return &waits
+}
+
+// failing returns an operation failing with err the first n calls, and its call count.
+func failing(n int, err error) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= n {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+func TestRetryDo(t *testing.T) {
+	exitErr := &RSyncError{ExitCode: 1}
+	startErr := errors.New("exec: \"gsutil\": executable file not found in $PATH")
+	tests := []struct {
+		name      string
+		retry     Retry
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   error
+		wantWaits []time.Duration
+	}{
+		{"zero value runs once", Retry{}, 1, exitErr, 1, exitErr, nil},
+		{"success needs no retry", Retry{Max: 3}, 0, exitErr, 1, nil, nil},
+		{"retries until success", Retry{Max: 3, Backoff: time.Second}, 2, exitErr, 3, nil,
+			[]time.Duration{time.Second, 2 * time.Second}},
+		{"gives up after max", Retry{Max: 2, Backoff: time.Second}, 5, exitErr, 3, exitErr,
+			[]time.Duration{time.Second, 2 * time.Second}},
+		{"default backoff", Retry{Max: 1}, 1, exitErr, 2, nil, []time.Duration{DefaultRetryBackoff}},
+		{"backoff is capped", Retry{Max: 3, Backoff: 3 * time.Minute}, 3, exitErr, 4, nil,
+			[]time.Duration{3 * time.Minute, maxRetryBackoff, maxRetryBackoff}},
+		{"start failures are not retried", Retry{Max: 3}, 1, startErr, 1, startErr, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := recordWaits(t)
+			log, _ := ruleLog()
+			op, calls := failing(tt.failures, tt.err)
+			err := tt.retry.Do(context.Background(), op, log)
+			if err != tt.wantErr {
+				t.Errorf("Do = %v, want %v", err, tt.wantErr)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("op ran %d times, want %d", *calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(*waits, tt.wantWaits) {
+				t.Errorf("waited %v, want %v", *waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func TestRetryDoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log, _ := ruleLog()
+	calls := 0
+	err := Retry{Max: 5, Backoff: time.Hour}.Do(ctx, func() error {
+		calls++
+		cancel()
+		return &RSyncError{ExitCode: 1}
+	}, log)
+	if err == nil || calls != 1 {
+		t.Errorf("Do = %v after %d call(s), want the first error without retrying", err, calls)
+	}
+
+	// a cancel while waiting abandons the retry too
+	ctx, cancel = context.WithCancel(context.Background())
+	calls = 0
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err = Retry{Max: 5, Backoff: time.Hour}.Do(ctx, func() error {
+		calls++
+		return &RSyncError{ExitCode: 1}
+	}, log)
+	if err == nil || calls != 1 {
+		t.Errorf("Do = %v after %d call(s), want the first error without retrying", err, calls)
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		jitter float64
+		random time.Duration // what Rand returns, clamped to [0, n)
+		want   time.Duration
+	}{
+		{"no jitter", 0, 0, 8 * time.Second},
+		{"lowest with half jitter", 0.5, 0, 4 * time.Second},
+		{"highest with half jitter", 0.5, time.Hour, 8 * time.Second},
+		{"middle with half jitter", 0.5, 2 * time.Second, 6 * time.Second},
+		{"full jitter may not wait", 1, 0, 0},
+		{"negative jitter is none", -1, 0, 8 * time.Second},
+		{"jitter above one is one", 2, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Retry{Backoff: time.Second, Jitter: tt.jitter, Rand: func(n time.Duration) time.Duration {
+				return min(tt.random, n-1)
+			}}
+			if got := r.delay(4); got != tt.want {
+				t.Errorf("delay(4) = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryJitterBounds(t *testing.T) {
+	r := Retry{Backoff: time.Second, Jitter: DefaultRetryJitter}
+	for attempt := 1; attempt <= 12; attempt++ {
+		high := min(time.Second<<(attempt-1), maxRetryBackoff)
+		low := high - time.Duration(float64(high)*DefaultRetryJitter)
+		for range 100 {
+			if d := r.delay(attempt); d < low || d > high {
+				t.Fatalf("delay(%d) = %s, want between %s and %s", attempt, d, low, high)
+			}
+		}
+	}
+}
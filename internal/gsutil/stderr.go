@@ -0,0 +1,238 @@
+package gsutil
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Built-in stderr patterns. gsutil reports progress and notices on stderr, so only
+// lines that look like failures are treated as errors.
+var (
+	defaultErrors = []string{
+		`Exception`,
+		`(?i)\berror\b`,
+		`(?i)^failed`,
+		`(?i)\bpermission denied\b`,
+	}
+	defaultNoise = []string{
+		`^Building synchronization state`,
+		`^Starting synchronization`,
+		`^Copying `,
+		`^Removing `,
+		`^Skipping `,
+		`^\[\d+/\d+ files\]`,
+		`^Operation completed`,
+		`^[-/|\\] \[`,
+		`^==> NOTE:`,
+	}
+)
+
+// Classifier assigns a log level to each line gsutil writes to stderr.
+// Noise patterns win over error patterns; unmatched lines are informational.
+type Classifier struct {
+	errors []*regexp.Regexp
+	noise  []*regexp.Regexp
+}
+
+// NewClassifier compiles the built-in patterns together with user-supplied ones.
+//
+// Parameters:
+//   - errs: Extra regular expressions marking a line as an error.
+//   - noise: Extra regular expressions marking a line as noise, logged at debug level.
+//
+// Returns:
+//   - *Classifier: The classifier.
+//   - error: An error naming the first invalid expression.
+func NewClassifier(errs, noise []string) (*Classifier, error) {
+	c := &Classifier{}
+	var err error
+	if c.errors, err = compileAll("stderr_errors", append(append([]string(nil), defaultErrors...), errs...)); err != nil {
+		return nil, err
+	}
+	if c.noise, err = compileAll("stderr_noise", append(append([]string(nil), defaultNoise...), noise...)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// defaultClassifier is used when Options.Stderr is nil.
+var defaultClassifier, _ = NewClassifier(nil, nil)
+
+// compileAll compiles every expression, naming the option in errors.
+func compileAll(option string, exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, e := range exprs {
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", option, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Level returns the log level for a single stderr line.
+func (c *Classifier) Level(line string) logrus.Level {
+	for _, re := range c.noise {
+		if re.MatchString(line) {
+			return logrus.DebugLevel
+		}
+	}
+	for _, re := range c.errors {
+		if re.MatchString(line) {
+			return logrus.ErrorLevel
+		}
+	}
+	return logrus.InfoLevel
+}
+
+// tailLines is the number of significant stderr lines kept for error messages.
+const tailLines = 5
+
+// lineLogger is an io.Writer that logs complete lines of gsutil output as they arrive, so
+// that long transfers never buffer more than one line. Stderr lines are logged at their
+// classified level; stdout lines (c == nil) at debug level. Carriage returns used by
+// progress bars end a line as well.
+type lineLogger struct {
+	mu   sync.Mutex
+	buf  []byte
+	c    *Classifier
+	tail []string // last tailLines stderr lines above debug level
+	log  *logrus.Entry
+
+	removed func(url string)      // called for every deletion gsutil reports; may be nil
+	copied  func(src, dst string) // called for every copy gsutil reports; may be nil
+	result  *SyncResult           // counts the reported transfers; may be nil
+}
+
+// newLineLogger returns a stderr lineLogger using c, or the built-in classifier when c is nil.
+func newLineLogger(c *Classifier, log *logrus.Entry) *lineLogger {
+	if c == nil {
+		c = defaultClassifier
+	}
+	return &lineLogger{c: c, log: log.WithField("stream", "stderr")}
+}
+
+// newStdoutLogger returns a lineLogger logging every stdout line at debug level.
+func newStdoutLogger(log *logrus.Entry) *lineLogger {
+	return &lineLogger{log: log.WithField("stream", "stdout")}
+}
+
+// Write implements io.Writer.
+func (w *lineLogger) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs a trailing line without newline, if any.
+func (w *lineLogger) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.emit(string(w.buf))
+	w.buf = nil
+}
+
+// Tail returns the last significant stderr lines, joined by "; ", for error messages.
+func (w *lineLogger) Tail() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Join(w.tail, "; ")
+}
+
+// emit logs one line, skipping blank ones.
+func (w *lineLogger) emit(line string) {
+	if line = strings.TrimSpace(line); line == "" {
+		return
+	}
+	if w.c == nil {
+		w.log.Debug(line)
+		return
+	}
+	if url, ok := ParseRemoval(line); ok && w.removed != nil {
+		w.removed(url)
+	}
+	if src, dst, ok := ParseCopy(line); ok && w.copied != nil {
+		w.copied(src, dst)
+	}
+	if w.result != nil {
+		w.result.observe(line)
+	}
+	lvl := w.c.Level(line)
+	w.log.Log(lvl, line)
+	if lvl < logrus.DebugLevel {
+		if len(w.tail) == tailLines {
+			w.tail = w.tail[1:]
+		}
+		w.tail = append(w.tail, line)
+	}
+}
+
+// ParseRemoval recognises the line gsutil writes to stderr for every deleted object or
+// file, e.g. `Removing gs://bucket/a.txt` (rsync -d) or `Removing gs://bucket/a.txt...` (rm).
+// Dry runs report `Would remove` instead and are not matched.
+//
+// Returns:
+//   - string: The deleted URL.
+//   - bool: Whether line reports a deletion.
+func ParseRemoval(line string) (string, bool) {
+	url, ok := strings.CutPrefix(strings.TrimSpace(line), "Removing ")
+	url = strings.TrimSuffix(url, "...")
+	if !ok || !strings.Contains(url, "://") {
+		return "", false
+	}
+	return url, true
+}
+
+// ParseCopy recognises the line written to stderr for every copied object or file:
+// `Copying gs://bucket/a.txt [Content-Type=text/plain]...` from gsutil, which only names
+// the source, or `Copying gs://bucket/a.txt to file://dir/a.txt` from gcloud storage.
+// Dry runs report `Would copy` instead and are not matched.
+//
+// Returns:
+//   - string: The source URL.
+//   - string: The destination URL, or "" when the line does not name it.
+//   - bool: Whether line reports a copy.
+func ParseCopy(line string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "Copying ")
+	if !ok {
+		return "", "", false
+	}
+	rest = strings.TrimSuffix(rest, "...")
+	if i := strings.LastIndex(rest, " [Content-Type="); i >= 0 {
+		rest = rest[:i]
+	}
+	src, dst := rest, ""
+	// names may contain " to " themselves; the separator is the one followed by a URL
+	for i := 0; ; {
+		j := strings.Index(rest[i:], " to ")
+		if j < 0 {
+			break
+		}
+		i += j + len(" to ")
+		if urlStart.MatchString(rest[i:]) {
+			src, dst = rest[:i-len(" to ")], rest[i:]
+			break
+		}
+	}
+	if !strings.Contains(src, "://") {
+		return "", "", false
+	}
+	return src, dst, true
+}
+
+// urlStart matches text starting with a storage URL scheme, e.g. gs:// or file://.
+var urlStart = regexp.MustCompile(`^[a-z][a-z0-9]*://`)
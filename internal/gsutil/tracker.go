@@ -0,0 +1,58 @@
+package gsutil
+
+import (
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trackerDir is the sub-directory of gsutil's state_dir holding resumable upload trackers.
+const trackerDir = "tracker-files"
+
+// PruneTrackers prepares a gsutil state directory for resumable transfers.
+//
+// gsutil resumes an interrupted upload or download on the next run as long as its
+// tracker file survives, so keeping state_dir on persistent storage makes large
+// transfers restart-safe. Trackers that were not touched for longer than maxAge
+// belong to transfers that will never resume and are removed.
+//
+// Parameters:
+//   - stateDir: The gsutil state directory; it is created if missing.
+//   - maxAge: Trackers older than this are deleted. Zero disables pruning.
+//   - log: A logrus.Entry for reporting pruned trackers.
+//
+// Returns:
+//   - int: The number of tracker files removed.
+//   - error: An error if the directory could not be created or read.
+func PruneTrackers(stateDir string, maxAge time.Duration, log *logrus.Entry) (int, error) {
+	dir := filepath.Join(stateDir, trackerDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return 0, err
+	}
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	pruned := 0
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil || !fi.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			log.WithError(err).Warnf("failed to remove stale tracker %s", e.Name())
+			continue
+		}
+		log.Debugf("removed stale tracker %s", e.Name())
+		pruned++
+	}
+	return pruned, nil
+}
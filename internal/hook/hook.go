@@ -0,0 +1,41 @@
+package hook
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Run executes a user-supplied shell command and waits for it to finish.
+//
+// The command runs through the platform shell (`sh -c`, or `cmd /C` on Windows) with the
+// process environment extended by env. Its output goes to the process stdout/stderr.
+//
+// Parameters:
+//   - name: A label for logging, e.g. "pre_sync".
+//   - command: The shell command line.
+//   - env: Extra KEY=VALUE pairs describing the context the hook runs in.
+//   - log: A logrus.Entry for logging the execution.
+//
+// Returns:
+//   - error: An error if the command could not be started or exited non-zero.
+func Run(name, command string, env []string, log *logrus.Entry) error {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.Command(shell, flag, command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+	log.Debugf("running %s hook: %s", name, command)
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	log.Debugf("%s hook finished in %s", name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -1,9 +1,15 @@
 package ignore
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 // globToRegex converts a glob pattern to a regular expression string.
@@ -13,6 +19,11 @@ import (
 //   - '*': Matches any number of characters except '/'
 //   - '?': Matches any single character
 //
+// Every other character is quoted, so the result always compiles. Runs of more than two
+// '*' are read as '**', and repeated "**/" segments as one, since gsutil evaluates the
+// expressions with Python's backtracking re module, where adjacent wildcards multiply the
+// work per path. See checkGlob for the inputs Compile rejects outright.
+//
 // Parameters:
 //   - glob: A string representing the glob pattern to be converted.
 //
@@ -21,58 +32,292 @@ import (
 //	A string representing the equivalent regular expression pattern,
 //	with '^' at the start and '$' at the end to ensure full string matching.
 func globToRegex(glob string) string {
-	re := regexp.QuoteMeta(glob)
+	// invalid UTF-8 would not compile; checkGlob rejects it before, this keeps the promise
+	re := regexp.QuoteMeta(normalize(strings.ToValidUTF8(glob, "\uFFFD")))
 	re = strings.ReplaceAll(re, `\*\*`, `.*`)
 	re = strings.ReplaceAll(re, `\*`, `[^/]*`)
 	re = strings.ReplaceAll(re, `\?`, `.`)
 	return "^" + re + "$"
 }
 
+// normalize reduces runs of '*' and of "**/" segments to a single wildcard each; both
+// match the same paths.
+func normalize(glob string) string {
+	return deepRuns.ReplaceAllString(starRuns.ReplaceAllString(glob, "**"), "**/")
+}
+
+var (
+	starRuns = regexp.MustCompile(`\*{3,}`)        // "***" and longer
+	deepRuns = regexp.MustCompile(`(?:\*\*/){2,}`) // "**/**/" and longer
+)
+
+// maxDeepWildcards bounds the '**' wildcards of a single pattern. Each one is a ".*" for
+// gsutil's backtracking matcher, whose work grows with the path length to that power.
+const maxDeepWildcards = 4
+
+// checkGlob rejects patterns globToRegex cannot turn into a safe expression: invalid
+// UTF-8, control characters (a newline would end the pattern early in an ignore file, and
+// Python's '$' matches before a trailing newline) and more than maxDeepWildcards '**'
+// wildcards.
+func checkGlob(glob string) error {
+	if !utf8.ValidString(glob) {
+		return fmt.Errorf("ignore pattern %q is not valid UTF-8", glob)
+	}
+	for _, r := range glob {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("ignore pattern %q contains a control character", glob)
+		}
+	}
+	if n := strings.Count(normalize(glob), "**"); n > maxDeepWildcards {
+		return fmt.Errorf("ignore pattern %q has %d '**' wildcards, at most %d are allowed", glob, n, maxDeepWildcards)
+	}
+	return nil
+}
+
 // Compile converts a slice of glob patterns into a slice of compiled regular expressions.
 // It ensures that all patterns use unix-style path separators and are cleaned before compilation.
 //
+// A pattern starting with '!' is a negation: it re-includes paths an earlier pattern
+// ignored, e.g. "*.log" followed by "!keep.log". Patterns are evaluated in order and the
+// last matching one decides (see Match). A leading "\!" stands for a literal '!'.
+//
+// With foldCase the expressions match regardless of letter case, e.g. "*.txt" also
+// ignores "Data.TXT", which helps on case-insensitive volumes (macOS, Windows mounts).
+//
 // Parameters:
 //   - root: A string representing the root directory. This parameter is currently unused
 //     but may be intended for future use in path manipulation.
 //   - patterns: A slice of strings, each representing a glob pattern to be compiled.
+//   - foldCase: Whether the patterns match case-insensitively (`(?i)`).
 //
 // Returns:
-//   - A slice of *regexp.Regexp, each corresponding to a compiled pattern.
+//   - A slice of Pattern, each corresponding to a compiled pattern.
 //   - An error if any pattern fails to compile into a valid regular expression.
-func Compile(root string, patterns []string) ([]*regexp.Regexp, error) {
-	var res []*regexp.Regexp
+func Compile(root string, patterns []string, foldCase bool) ([]Pattern, error) {
+	key := cacheKey(patterns, foldCase)
+	if res, ok := compiled.get(key); ok {
+		return res, nil
+	}
+	var res []Pattern
 	for _, g := range patterns {
+		neg := strings.HasPrefix(g, "!")
+		if neg {
+			g = g[1:]
+		} else if strings.HasPrefix(g, `\!`) {
+			g = g[1:]
+		}
+		if err := checkGlob(g); err != nil {
+			return nil, err
+		}
 		// ensure unix-style path separators inside regex
 		p := filepath.ToSlash(filepath.Clean(g))
-		re, err := regexp.Compile(globToRegex(p))
+		expr := globToRegex(p)
+		if foldCase {
+			expr = caseFlag + expr
+		}
+		re, err := regexp.Compile(expr)
 		if err != nil {
 			return nil, err
 		}
-		res = append(res, re)
+		res = append(res, Pattern{Regexp: re, Negated: neg})
 	}
+	compiled.put(key, res)
 	return res, nil
 }
 
-// Match checks if a given relative path matches any of the provided regular expressions.
+// Pattern is a compiled glob pattern together with its polarity.
+type Pattern struct {
+	*regexp.Regexp
+	// Negated is set for '!' patterns, which re-include paths earlier patterns ignored.
+	Negated bool
+}
+
+// cacheSize bounds the number of pattern sets kept compiled. Every rule and every
+// configuration reload that edits patterns adds one.
+const cacheSize = 256
+
+// compiled caches Compile results, so that rules prepared again on a configuration reload
+// (or by several commands) skip recompiling hundreds of unchanged patterns.
+var compiled = cache{sets: make(map[string][]Pattern)}
+
+// cache maps pattern sets to their compiled expressions. It is safe for concurrent use.
+type cache struct {
+	mu   sync.Mutex
+	sets map[string][]Pattern
+}
+
+// cacheKey identifies a pattern set, including its order, which matters for negations.
+func cacheKey(patterns []string, foldCase bool) string {
+	return strconv.FormatBool(foldCase) + "\x00" + strings.Join(patterns, "\x00")
+}
+
+// get returns a copy of the cached expressions for key, so callers may append to it.
+func (c *cache) get(key string) ([]Pattern, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, ok := c.sets[key]
+	return append([]Pattern(nil), res...), ok
+}
+
+// put stores the expressions for key, dropping every entry once cacheSize is reached.
+func (c *cache) put(key string, res []Pattern) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sets) >= cacheSize {
+		clear(c.sets)
+	}
+	c.sets[key] = append([]Pattern(nil), res...)
+}
+
+// caseFlag makes an expression case-insensitive. Both Go's regexp and Python's re, which
+// gsutil uses, accept it at the start of the expression.
+const caseFlag = "(?i)"
+
+// Expressions renders compiled patterns as exclusion expressions for `gsutil -x`, which
+// has no notion of negation.
 //
-// This function iterates through the slice of regular expressions and returns true
-// if the relative path matches any of them. It's typically used to determine if a
-// file or directory should be ignored based on a set of patterns.
+// An ignoring pattern followed by negations becomes a single expression with a negative
+// lookahead, e.g. `^(?!(?:keep\.log)$)[^/]*\.log$`. gsutil evaluates exclusions with
+// Python's re module, which supports lookaheads; Go's regexp does not, hence strings.
+//
+// Parameters:
+//   - patterns: The patterns in evaluation order.
+//
+// Returns:
+//   - []string: One expression per ignoring pattern; negations only appear inside them.
+func Expressions(patterns []Pattern) []string {
+	var res []string
+	for i, re := range patterns {
+		if re.Negated {
+			continue
+		}
+		var later []string
+		for _, n := range patterns[i+1:] {
+			if n.Negated {
+				later = append(later, core(n.String()))
+			}
+		}
+		if len(later) == 0 {
+			res = append(res, re.String())
+			continue
+		}
+		flag := ""
+		if strings.HasPrefix(re.String(), caseFlag) {
+			flag = caseFlag
+		}
+		res = append(res, flag+"^(?!(?:"+strings.Join(later, "|")+")$)"+core(re.String())+"$")
+	}
+	return res
+}
+
+// core strips the anchors globToRegex adds, and the case flag Compile may add.
+func core(expr string) string {
+	expr = strings.TrimPrefix(expr, caseFlag)
+	return strings.TrimSuffix(strings.TrimPrefix(expr, "^"), "$")
+}
+
+// CompileFile compiles the inline patterns together with those read from an ignore file.
+//
+// The file holds one glob per line, with the same syntax as inline patterns (including
+// '!' negations) and relative to root as well. Blank lines and lines starting with '#' are skipped; a leading '/' is
+// allowed and dropped, since every pattern is anchored at root anyway. A pattern matching
+// a path ignores it, whether it comes from the file or inline.
+//
+// Parameters:
+//   - root: The rule's source root; a relative file path is resolved against it.
+//   - file: The ignore file; empty compiles the inline patterns only.
+//   - patterns: The inline glob patterns.
+//   - foldCase: Whether the patterns match case-insensitively, see Compile.
+//   - limit: The maximum number of patterns, inline and from the file together; 0 means
+//     no limit.
+//
+// Returns:
+//   - []Pattern: The inline patterns followed by the file's patterns, compiled.
+//   - error: An error if the file could not be read, there are more than limit patterns
+//     or a pattern is invalid.
+func CompileFile(root, file string, patterns []string, foldCase bool, limit int) ([]Pattern, error) {
+	all := patterns
+	if file != "" {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(root, file)
+		}
+		fromFile, err := ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		all = append(append([]string(nil), patterns...), fromFile...)
+	}
+	if limit > 0 && len(all) > limit {
+		return nil, fmt.Errorf("%d ignore patterns exceed max_ignore_patterns=%d", len(all), limit)
+	}
+	return Compile(root, all, foldCase)
+}
+
+// ReadFile reads the glob patterns of an ignore file, see CompileFile.
+//
+// Parameters:
+//   - path: The ignore file.
+//
+// Returns:
+//   - []string: The patterns in file order.
+//   - error: An error if the file could not be read.
+func ReadFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		neg := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(strings.TrimPrefix(line, "!"), "/")
+		if neg {
+			line = "!" + line
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// Match checks if a given relative path is ignored by the provided patterns.
+//
+// The patterns are evaluated in order and the last one matching decides: an ignoring
+// pattern ignores the path, a negation (see Compile) re-includes it. It's typically used
+// to determine if a file or directory should be ignored based on a set of patterns.
 //
 // Parameters:
 //   - rel: A string representing the relative path to check. This should be in unix-style
 //     format and relative to the rule root.
-//   - regexes: A slice of compiled regular expressions (*regexp.Regexp) to match against.
+//   - patterns: A slice of compiled patterns to match against.
 //
 // Returns:
 //
-//	A boolean value. True if the relative path matches any of the regular expressions,
-//	false otherwise.
-func Match(rel string, regexes []*regexp.Regexp) bool {
-	for _, re := range regexes {
-		if re.MatchString(rel) {
-			return true
+//	A boolean value. True if the last pattern matching the relative path ignores it,
+//	false if none matches or the last match is a negation.
+func Match(rel string, patterns []Pattern) bool {
+	ignored := false
+	for _, p := range patterns {
+		if p.MatchString(rel) {
+			ignored = !p.Negated
 		}
 	}
-	return false
+	return ignored
+}
+
+// MarkerPrefix starts the name of every file gcs-sync itself keeps at a destination
+// (state, manifests, reports). Such files are never pulled into a local tree.
+const MarkerPrefix = ".gcs-sync"
+
+// markers matches tool-managed marker files at any depth.
+var markers = []Pattern{
+	{Regexp: regexp.MustCompile(globToRegex(MarkerPrefix + "*"))},
+	{Regexp: regexp.MustCompile(globToRegex("**/" + MarkerPrefix + "*"))},
+}
+
+// Markers returns the patterns matching tool-managed marker files.
+func Markers() []Pattern {
+	return append([]Pattern(nil), markers...)
 }
@@ -0,0 +1,329 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"*.log", `^[^/]*\.log$`},
+		{"**/node_modules", `^.*/node_modules$`},
+		{"a?c", `^a.c$`},
+		{"***", `^.*$`},
+		{"**/**/**/x", `^.*/x$`},
+		{"a.b+(c)|[d]{e}^$", `^a\.b\+\(c\)\|\[d\]\{e\}\^\$$`},
+		{`back\slash`, `^back\\slash$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			if got := globToRegex(tt.glob); got != tt.want {
+				t.Errorf("globToRegex(%q) = %q, want %q", tt.glob, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		foldCase bool
+		rel      string
+		want     bool
+	}{
+		{"star stays in its directory", []string{"*.log"}, false, "a.log", true},
+		{"star does not cross slash", []string{"*.log"}, false, "dir/a.log", false},
+		{"double star crosses slash", []string{"**/*.log"}, false, "dir/sub/a.log", true},
+		{"question mark is one character", []string{"a?c"}, false, "abc", true},
+		{"question mark is not two", []string{"a?c"}, false, "abbc", false},
+		{"metacharacters are literal", []string{"a+b"}, false, "aab", false},
+		{"brackets are literal", []string{"[ab].txt"}, false, "[ab].txt", true},
+		{"negation re-includes", []string{"*.log", "!keep.log"}, false, "keep.log", false},
+		{"negation leaves others", []string{"*.log", "!keep.log"}, false, "drop.log", true},
+		{"escaped bang is literal", []string{`\!x`}, false, "!x", true},
+		{"case sensitive by default", []string{"*.txt"}, false, "A.TXT", false},
+		{"fold case", []string{"*.txt"}, true, "A.TXT", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Compile("", tt.patterns, tt.foldCase)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.patterns, err)
+			}
+			if got := Match(tt.rel, res); got != tt.want {
+				t.Errorf("Match(%q) with %q = %v, want %v", tt.rel, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		glob string
+	}{
+		{"newline", "a\nb"},
+		{"carriage return", "a\r"},
+		{"nul", "a\x00b"},
+		{"escape", "\x1b[31m"},
+		{"invalid utf-8", "a\xffb"},
+		{"too many double stars", "**/a/**/b/**/c/**/d/**/e"},
+		{"too many double stars in a negation", "!**/a/**/b/**/c/**/d/**/e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile("", []string{tt.glob}, false); err == nil {
+				t.Errorf("Compile(%q) succeeded, want an error", tt.glob)
+			}
+		})
+	}
+}
+
+func TestCompileAcceptsDeepWildcardLimit(t *testing.T) {
+	// runs collapse before counting, so these stay within maxDeepWildcards
+	for _, glob := range []string{"**/a/**/b/**/c/**/d", "**/**/**/**/**/x", "*****"} {
+		if _, err := Compile("", []string{glob}, false); err != nil {
+			t.Errorf("Compile(%q): %v", glob, err)
+		}
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		foldCase bool
+		want     []string
+	}{
+		{"plain", []string{"*.log", "tmp"}, false, []string{`^[^/]*\.log$`, `^tmp$`}},
+		{"negation", []string{"*.log", "!keep.log", "tmp"}, false, []string{`^(?!(?:keep\.log)$)[^/]*\.log$`, `^tmp$`}},
+		{"negations only apply to earlier patterns", []string{"!keep.log", "*.log"}, false, []string{`^[^/]*\.log$`}},
+		{"several negations", []string{"*.log", "!keep.log", "!logs/*.log"}, false,
+			[]string{`^(?!(?:keep\.log|logs/[^/]*\.log)$)[^/]*\.log$`}},
+		{"case-insensitive", []string{"*.log"}, true, []string{`(?i)^[^/]*\.log$`}},
+		// the flag must lead the whole expression, not sit inside the lookahead
+		{"case-insensitive negation", []string{"*.log", "!keep.log"}, true, []string{`(?i)^(?!(?:keep\.log)$)[^/]*\.log$`}},
+		{"escaped bang", []string{`\!important`}, false, []string{`^!important$`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Compile("", tt.patterns, tt.foldCase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Expressions(res)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Expressions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileKeepsPolarity(t *testing.T) {
+	patterns := []string{"*.log", "!keep.log"}
+	first, err := Compile("", patterns, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// overflowing the cache clears it; the polarity travels with the patterns regardless
+	for i := range cacheSize {
+		if _, err := Compile("", []string{strconv.Itoa(i)}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	again, err := Compile("", patterns, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range [][]Pattern{first, again} {
+		if len(res) != 2 || res[0].Negated || !res[1].Negated {
+			t.Errorf("Compile(%q) polarity = %+v, want ignoring then negated", patterns, res)
+		}
+	}
+	if !Match("a.log", first) || Match("keep.log", first) {
+		t.Error("the patterns compiled before the cache was cleared lost their polarity")
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	tests := []struct {
+		name      string
+		patterns  []string
+		rel       string
+		want      bool // with foldCase
+		wantExact bool // without it
+	}{
+		{"lower pattern, upper name", []string{"readme.md"}, "README.md", true, false},
+		{"upper pattern, lower name", []string{"*.TXT"}, "notes.txt", true, false},
+		{"in a directory", []string{"**/Build/*"}, "src/build/out.o", true, false},
+		{"negation folds too", []string{"*.log", "!KEEP.log"}, "keep.LOG", false, false},
+		{"negation of the exact case", []string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{"other names still differ", []string{"readme.md"}, "readme.txt", false, false},
+		{"same case", []string{"readme.md"}, "readme.md", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// both sets are compiled; the cache must keep them apart
+			for _, fold := range []bool{true, false} {
+				res, err := Compile("", tt.patterns, fold)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := tt.wantExact
+				if fold {
+					want = tt.want
+				}
+				if got := Match(tt.rel, res); got != want {
+					t.Errorf("Match(%q) with %q, foldCase %v = %v, want %v", tt.rel, tt.patterns, fold, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileFile(t *testing.T) {
+	dir := t.TempDir()
+	content := "# build output\n\n  /build  \n*.tmp\n!keep.tmp\n\\!bang\n\t# indented comment\n/docs/**/*.pdf\n"
+	if err := os.WriteFile(filepath.Join(dir, ".syncignore"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	abs := filepath.Join(t.TempDir(), "shared.ignore")
+	if err := os.WriteFile(abs, []byte("*.bak\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		inline  []string
+		limit   int
+		matches map[string]bool
+		wantErr string
+	}{
+		{"comments, blanks and a leading slash", ".syncignore", []string{"*.log"}, 0, map[string]bool{
+			"a.log": true, "build": true, "x.tmp": true, "docs/a/b.pdf": true,
+			"# build output": false, "src/main.go": false, "sub/build": false,
+		}, ""},
+		{"negation lines", ".syncignore", nil, 0, map[string]bool{"keep.tmp": false, "drop.tmp": true}, ""},
+		{"escaped bang", ".syncignore", nil, 0, map[string]bool{"!bang": true, "bang": false}, ""},
+		{"inline patterns come first", ".syncignore", []string{"!x.tmp"}, 0, map[string]bool{"x.tmp": true}, ""},
+		{"absolute path", abs, nil, 0, map[string]bool{"a.bak": true, "x.tmp": false}, ""},
+		{"no file", "", []string{"*.log"}, 0, map[string]bool{"a.log": true, "x.tmp": false}, ""},
+		{"limit reached", ".syncignore", []string{"*.log"}, 6, map[string]bool{"a.log": true}, ""},
+		{"limit exceeded", ".syncignore", []string{"*.log"}, 5, nil, "6 ignore patterns exceed max_ignore_patterns=5"},
+		{"missing file", "missing.ignore", nil, 0, nil, "missing.ignore"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := CompileFile(dir, tt.file, tt.inline, false, tt.limit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CompileFile = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rel, want := range tt.matches {
+				if got := Match(rel, res); got != want {
+					t.Errorf("Match(%q) = %v, want %v", rel, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(path, []byte("# c\r\n/a\r\n\r\n!/b\n  c/d  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "!b", "c/d"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ReadFile = %q, want %q", got, want)
+	}
+}
+
+// adjacentWildcards matches two wildcards in a row, which multiply the backtracking work
+// of gsutil's Python matcher.
+var adjacentWildcards = regexp.MustCompile(`(?:\.\*|\[\^/\]\*)(?:\.\*|\[\^/\]\*)`)
+
+func FuzzGlobToRegex(f *testing.F) {
+	for _, seed := range []string{
+		"*.log", "**/node_modules", "a?c", "***", "**/**/**/x", "a.b+(c)|[d]{e}^$", `\`, `\*`,
+		"a\nb", "a\x00b", "\x1b[31m", "**/a/**/b/**/c/**/d/**/e", "*?*?*?*", "**?**", "(?i)x",
+		"\xff\xfe", "!*.log", "/abs/path",
+	} {
+		f.Add(seed)
+	}
+	long := strings.Repeat("a/", 2048) + "b"
+	f.Fuzz(func(t *testing.T, glob string) {
+		expr := globToRegex(glob)
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			t.Fatalf("globToRegex(%q) = %q does not compile: %v", glob, expr, err)
+		}
+		if !strings.HasPrefix(expr, "^") || !strings.HasSuffix(expr, "$") {
+			t.Fatalf("globToRegex(%q) = %q is not anchored", glob, expr)
+		}
+		if adjacentWildcards.MatchString(expr) {
+			t.Fatalf("globToRegex(%q) = %q has adjacent wildcards", glob, expr)
+		}
+		if utf8.ValidString(glob) && !strings.ContainsAny(glob, "*?") && !re.MatchString(glob) {
+			t.Fatalf("globToRegex(%q) = %q does not match the literal glob", glob, expr)
+		}
+		if checkGlob(glob) == nil {
+			if n := strings.Count(expr, ".*"); n > maxDeepWildcards {
+				t.Fatalf("globToRegex(%q) = %q has %d '.*', more than checkGlob allows", glob, expr, n)
+			}
+			if strings.ContainsFunc(glob, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+				t.Fatalf("checkGlob accepted control characters in %q", glob)
+			}
+		}
+		start := time.Now()
+		re.MatchString(long)
+		re.MatchString(glob + long)
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("matching %q took %s", expr, d)
+		}
+	})
+}
+
+func TestMarkers(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{".gcs-sync", true},
+		{".gcs-sync-state.json", true},
+		{"dir/.gcs-sync.manifest", true},
+		{"a/b/.gcs-sync", true},
+		{"gcs-sync", false},
+		{"x.gcs-sync", false},
+		{"dir/.gcs", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.path, Markers()); got != tt.want {
+			t.Errorf("Markers match %q = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	m := Markers()
+	m[0] = Pattern{}
+	if Markers()[0].Regexp == nil {
+		t.Error("Markers returned its own slice")
+	}
+}
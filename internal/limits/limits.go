@@ -0,0 +1,2 @@
+// Package limits checks process resource limits before gcs-sync starts heavy work.
+package limits
@@ -0,0 +1,53 @@
+//go:build unix
+
+package limits
+
+import (
+	"github.com/sirupsen/logrus"
+	"syscall"
+)
+
+// getrlimit and setrlimit access RLIMIT_NOFILE; replaceable for tests.
+var (
+	getrlimit = func(l *syscall.Rlimit) error { return syscall.Getrlimit(syscall.RLIMIT_NOFILE, l) }
+	setrlimit = func(l *syscall.Rlimit) error { return syscall.Setrlimit(syscall.RLIMIT_NOFILE, l) }
+)
+
+// EnsureOpenFiles makes sure the soft limit on open file descriptors (`ulimit -n`) is at
+// least want, raising it up to the hard limit if needed.
+//
+// Watching large trees and transferring long file lists keep many descriptors open at
+// once (one per watched directory on macOS, plus gsutil's own). A limit that is still too
+// low after raising it is only logged: the operation may still succeed.
+//
+// Parameters:
+//   - want: The minimum soft limit; 0 skips the check.
+//   - log: A logrus.Entry for reporting changes and shortfalls.
+//
+// Returns:
+//   - uint64: The soft limit in effect afterwards.
+//   - error: An error if the limit could not be read.
+func EnsureOpenFiles(want uint64, log *logrus.Entry) (uint64, error) {
+	var lim syscall.Rlimit
+	if err := getrlimit(&lim); err != nil {
+		return 0, err
+	}
+	if want == 0 || lim.Cur >= want {
+		return lim.Cur, nil
+	}
+	raised := lim
+	raised.Cur = min(want, lim.Max)
+	if raised.Cur > lim.Cur {
+		if err := setrlimit(&raised); err != nil {
+			log.WithError(err).Warnf("could not raise the open file limit from %d to %d", lim.Cur, raised.Cur)
+		} else {
+			log.Infof("raised the open file limit from %d to %d", lim.Cur, raised.Cur)
+			lim = raised
+		}
+	}
+	if lim.Cur < want {
+		log.Warnf("open file limit is %d (hard limit %d), below the configured %d; large syncs may fail with \"too many open files\"",
+			lim.Cur, lim.Max, want)
+	}
+	return lim.Cur, nil
+}
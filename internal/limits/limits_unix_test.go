@@ -0,0 +1,72 @@
+//go:build unix
+
+package limits
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"syscall"
+	"testing"
+)
+
+func TestEnsureOpenFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		cur, max  uint64
+		want      uint64
+		setErr    error
+		wantLimit uint64
+		wantSet   bool
+		wantWarn  bool
+	}{
+		{"check disabled", 256, 1024, 0, nil, 256, false, false},
+		{"already enough", 4096, 8192, 1024, nil, 4096, false, false},
+		{"raised", 256, 8192, 4096, nil, 4096, true, false},
+		{"capped at the hard limit", 256, 1024, 4096, nil, 1024, true, true},
+		{"at the hard limit already", 1024, 1024, 4096, nil, 1024, false, true},
+		{"raising refused", 256, 8192, 4096, errors.New("operation not permitted"), 256, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get, set := getrlimit, setrlimit
+			t.Cleanup(func() { getrlimit, setrlimit = get, set })
+			var setTo *syscall.Rlimit
+			getrlimit = func(l *syscall.Rlimit) error {
+				l.Cur, l.Max = tt.cur, tt.max
+				return nil
+			}
+			setrlimit = func(l *syscall.Rlimit) error {
+				setTo = l
+				return tt.setErr
+			}
+			logger, hook := test.NewNullLogger()
+			got, err := EnsureOpenFiles(tt.want, logrus.NewEntry(logger))
+			if err != nil || got != tt.wantLimit {
+				t.Errorf("EnsureOpenFiles(%d) = %d, %v, want %d", tt.want, got, err, tt.wantLimit)
+			}
+			if (setTo != nil) != tt.wantSet {
+				t.Errorf("setrlimit called = %v, want %v", setTo != nil, tt.wantSet)
+			} else if setTo != nil && setTo.Max != tt.max {
+				t.Errorf("setrlimit changed the hard limit to %d", setTo.Max)
+			}
+			warned := false
+			for _, e := range hook.AllEntries() {
+				warned = warned || e.Level == logrus.WarnLevel
+			}
+			if warned != tt.wantWarn {
+				t.Errorf("warned = %v, want %v", warned, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestEnsureOpenFilesReadError(t *testing.T) {
+	get := getrlimit
+	t.Cleanup(func() { getrlimit = get })
+	getrlimit = func(*syscall.Rlimit) error { return syscall.EPERM }
+	logger, _ := test.NewNullLogger()
+	if _, err := EnsureOpenFiles(1024, logrus.NewEntry(logger)); !errors.Is(err, syscall.EPERM) {
+		t.Errorf("EnsureOpenFiles = %v, want EPERM", err)
+	}
+}
@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -8,27 +10,75 @@ import (
 
 var logger = logrus.New()
 
+// Log formats accepted by Init.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// timestampFormat is shared by both formats, so switching formats keeps timestamps comparable.
+const timestampFormat = "2006-01-02 15:04:05.000"
+
 // Init configures the global logrus instance with the specified log level and formatting.
 //
 // Parameters:
 //   - level: A string representing the desired log level (e.g., "debug", "info", "warn", "error").
 //     The level is case-insensitive. If an invalid level is provided, it defaults to "info".
+//   - format: "text" for human-readable lines, or "json" for one JSON object per line, e.g.
+//     for Loki or Cloud Logging. Fields such as rule and reason become top-level JSON keys.
+//   - noColor: Disables colored output. Colors are also disabled whenever the NO_COLOR
+//     environment variable is set to a non-empty value (https://no-color.org).
 //
 // The function sets up the logger with the following configurations:
 //   - Log level: Parsed from the input string, defaulting to Info if parsing fails.
-//   - Formatter: TextFormatter with full timestamp and custom timestamp format.
+//   - Formatter: TextFormatter with full timestamp and custom timestamp format, or
+//     JSONFormatter with the same timestamp format under the usual "time" key. Rule log
+//     files (see RuleFile) use the same format, never colored.
 //
-// This function does not return any value; it modifies the global logger in-place.
-func Init(level string) {
+// Returns:
+//   - error: An error if format is unknown; the logger is left unchanged in that case.
+func Init(level, format string, noColor bool) error {
+	var formatter logrus.Formatter
+	var fileFmt logrus.Formatter
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		formatter = &logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: timestampFormat,
+			DisableColors:   noColor || os.Getenv("NO_COLOR") != "",
+		}
+		fileFmt = &logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: timestampFormat,
+			DisableColors:   true,
+		}
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{TimestampFormat: timestampFormat}
+		fileFmt = formatter
+	default:
+		return fmt.Errorf("unknown log format %q (want %s|%s)", format, FormatText, FormatJSON)
+	}
 	lvl, err := logrus.ParseLevel(strings.ToLower(level))
 	if err != nil {
 		lvl = logrus.InfoLevel
 	}
 	logger.SetLevel(lvl)
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp:   true,
-		TimestampFormat: "2006-01-02 15:04:05.000",
-	})
+	logger.SetFormatter(formatter)
+	fileFormatter = fileFmt
+	return nil
+}
+
+// VerboseLevel maps the number of -v flags to a log level: none is info, one is debug and
+// two or more are trace.
+func VerboseLevel(count int) logrus.Level {
+	switch {
+	case count <= 0:
+		return logrus.InfoLevel
+	case count == 1:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
 }
 
 // L returns the configured logger (convenience).
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// keepLogger restores the global logger configuration Init changes once t ends.
+func keepLogger(t *testing.T) {
+	formatter, level, out, file := logger.Formatter, logger.GetLevel(), logger.Out, fileFormatter
+	t.Cleanup(func() {
+		logger.SetFormatter(formatter)
+		logger.SetLevel(level)
+		logger.SetOutput(out)
+		fileFormatter = file
+	})
+}
+
+func TestInitColors(t *testing.T) {
+	tests := []struct {
+		name    string
+		noColor bool
+		env     string
+		want    bool // colors disabled
+	}{
+		{"default", false, "", false},
+		{"--no-color", true, "", true},
+		{"NO_COLOR", false, "1", true},
+		{"NO_COLOR with any value", false, "false", true},
+		{"both", true, "1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keepLogger(t)
+			t.Setenv("NO_COLOR", tt.env)
+			if err := Init("info", FormatText, tt.noColor); err != nil {
+				t.Fatal(err)
+			}
+			f, ok := logger.Formatter.(*logrus.TextFormatter)
+			if !ok {
+				t.Fatalf("formatter is %T, want a TextFormatter", logger.Formatter)
+			}
+			if f.DisableColors != tt.want {
+				t.Errorf("DisableColors = %v, want %v", f.DisableColors, tt.want)
+			}
+			// rule log files are never colored
+			if ff, ok := fileFormatter.(*logrus.TextFormatter); !ok || !ff.DisableColors {
+				t.Errorf("rule file formatter %+v may color", fileFormatter)
+			}
+		})
+	}
+}
+
+func TestInitJSON(t *testing.T) {
+	keepLogger(t)
+	if err := Init("debug", "JSON", false); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	logger.SetOutput(&out)
+	rf := NewRuleFile(filepath.Join(t.TempDir(), "rule.log"), Rotation{})
+	if err := rf.Open(); err != nil {
+		t.Fatal(err)
+	}
+	rf.Entry(L().WithFields(logrus.Fields{"rule": "photos", "reason": "debounce"})).
+		WithError(errors.New("boom")).Warn("sync failed")
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.ReadFile(rf.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, line := range map[string][]byte{"stderr": out.Bytes(), "rule file": file} {
+		if n := bytes.Count(line, []byte("\n")); n != 1 {
+			t.Errorf("%s: %d lines, want one JSON object:\n%s", name, n, line)
+			continue
+		}
+		var got map[string]string
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Errorf("%s: %v in %s", name, err, line)
+			continue
+		}
+		want := map[string]string{"rule": "photos", "reason": "debounce", "error": "boom", "level": "warning", "msg": "sync failed"}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: %q = %q, want %q", name, k, got[k], v)
+			}
+		}
+		if _, err := time.Parse(timestampFormat, got["time"]); err != nil {
+			t.Errorf("%s: time %q: %v", name, got["time"], err)
+		}
+	}
+}
+
+func TestInitUnknownFormat(t *testing.T) {
+	keepLogger(t)
+	if err := Init("debug", FormatJSON, false); err != nil {
+		t.Fatal(err)
+	}
+	err := Init("info", "xml", false)
+	if err == nil || !strings.Contains(err.Error(), "xml") {
+		t.Errorf("Init(xml) = %v, want an unknown format error", err)
+	}
+	if _, ok := logger.Formatter.(*logrus.JSONFormatter); !ok || logger.GetLevel() != logrus.DebugLevel {
+		t.Errorf("a failed Init changed the logger to %T at %s", logger.Formatter, logger.GetLevel())
+	}
+}
+
+func TestVerboseLevel(t *testing.T) {
+	tests := []struct {
+		count int
+		want  logrus.Level
+	}{
+		{-1, logrus.InfoLevel},
+		{0, logrus.InfoLevel},
+		{1, logrus.DebugLevel},
+		{2, logrus.TraceLevel},
+		{5, logrus.TraceLevel},
+	}
+	for _, tt := range tests {
+		if got := VerboseLevel(tt.count); got != tt.want {
+			t.Errorf("VerboseLevel(%d) = %s, want %s", tt.count, got, tt.want)
+		}
+	}
+}
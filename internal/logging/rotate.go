@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rotation controls how a RuleFile is archived once it grows large (log_file_max_size,
+// log_file_max_total, log_file_max_age).
+type Rotation struct {
+	// MaxSize is the size in bytes past which the file is compressed into an archive and
+	// started afresh; 0 never rotates.
+	MaxSize int64
+	// MaxTotal caps the total size in bytes of the archives; the oldest are removed
+	// first. 0 keeps them regardless of size.
+	MaxTotal int64
+	// MaxAge removes archives older than this; 0 keeps them regardless of age.
+	MaxAge time.Duration
+}
+
+// archiveStamp names archives after the time they were rotated; it sorts chronologically.
+const archiveStamp = "20060102T150405.000"
+
+// rotate compresses the current file into `<path>.<stamp>.gz` next to it, reopens an empty
+// file and prunes the archives beyond the caps; rf.mu must be held. The file is reopened
+// even if compressing failed, so logging goes on.
+func (rf *RuleFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	rf.f = nil
+	archive := rf.path + "." + rf.now().UTC().Format(archiveStamp) + ".gz"
+	err := compress(rf.path, archive)
+	if err == nil {
+		err = os.Remove(rf.path)
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return rf.prune()
+}
+
+// compress writes a gzip copy of src to dst.
+func compress(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(dst)
+		}
+	}()
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(src)
+	if _, err := io.Copy(zw, in); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// prune removes the archives of the file older than MaxAge, then the oldest ones until
+// the rest fit into MaxTotal.
+func (rf *RuleFile) prune() error {
+	if rf.rot.MaxAge <= 0 && rf.rot.MaxTotal <= 0 {
+		return nil
+	}
+	archives, err := rf.archives()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	var total int64
+	cutoff := rf.now().Add(-rf.rot.MaxAge)
+	// newest first, so the total keeps the most recent archives
+	for i := len(archives) - 1; i >= 0; i-- {
+		a := archives[i]
+		total += a.Size()
+		if (rf.rot.MaxAge > 0 && a.ModTime().Before(cutoff)) || (rf.rot.MaxTotal > 0 && total > rf.rot.MaxTotal) {
+			if err := os.Remove(filepath.Join(filepath.Dir(rf.path), a.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// archives returns the archives of the file, oldest first.
+func (rf *RuleFile) archives() ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(filepath.Dir(rf.path))
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(rf.path) + "."
+	var infos []fs.FileInfo
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || !strings.HasSuffix(stamp, ".gz") || !e.Type().IsRegular() {
+			continue
+		}
+		if _, err := time.Parse(archiveStamp, strings.TrimSuffix(stamp, ".gz")); err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "rule.log")
+	rf := NewRuleFile(path, Rotation{MaxSize: 100})
+	clock := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	rf.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	if err := rf.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	var lines []string
+	for i := range 5 {
+		line := strings.Repeat(string(rune('a'+i)), 59) + "\n"
+		lines = append(lines, line)
+		if err := rf.write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every line but the first overflows 100 bytes, so each earlier line got its own archive
+	archives, err := rf.archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, contents []string
+	for _, a := range archives {
+		names = append(names, a.Name())
+		contents = append(contents, gunzip(t, filepath.Join(filepath.Dir(path), a.Name())))
+	}
+	wantNames := []string{
+		"rule.log.20240501T100001.000.gz",
+		"rule.log.20240501T100002.000.gz",
+		"rule.log.20240501T100003.000.gz",
+		"rule.log.20240501T100004.000.gz",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("archives = %q, want %q", names, wantNames)
+	}
+	if !reflect.DeepEqual(contents, lines[:4]) {
+		t.Errorf("archived %q, want %q", contents, lines[:4])
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != lines[4] {
+		t.Errorf("current file = %q, %v, want %q", b, err, lines[4])
+	}
+}
+
+func TestRotateReopensExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rule.log")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 90)+"\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	rf := NewRuleFile(path, Rotation{MaxSize: 100})
+	if err := rf.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	// the size left by an earlier run counts towards MaxSize
+	if err := rf.write([]byte("next line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if archives, err := rf.archives(); err != nil || len(archives) != 1 {
+		t.Errorf("archives = %d, %v, want 1", len(archives), err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		rot  Rotation
+		want []string
+	}{
+		{"no caps", Rotation{}, []string{"1", "2", "3"}},
+		{"total keeps the newest", Rotation{MaxTotal: 250}, []string{"2", "3"}},
+		{"total below one archive", Rotation{MaxTotal: 50}, nil},
+		{"age", Rotation{MaxAge: 90 * time.Minute}, []string{"3"}},
+		{"age and total", Rotation{MaxAge: 150 * time.Minute, MaxTotal: 150}, []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "rule.log")
+			// archive n is n hours old; the other files are not archives of rule.log
+			stamps := map[string]string{}
+			for n := 1; n <= 3; n++ {
+				at := now.Add(-time.Duration(4-n) * time.Hour)
+				name := "rule.log." + at.Format(archiveStamp) + ".gz"
+				stamps[name] = string(rune('0' + n))
+				writeAged(t, filepath.Join(dir, name), 100, at)
+			}
+			others := []string{"rule.log", "rule.log.old.gz", "other.log." + now.Format(archiveStamp) + ".gz"}
+			for _, name := range others {
+				writeAged(t, filepath.Join(dir, name), 1000, now.Add(-24*time.Hour))
+			}
+
+			rf := &RuleFile{path: path, rot: tt.rot, now: func() time.Time { return now }}
+			if err := rf.prune(); err != nil {
+				t.Fatal(err)
+			}
+			archives, err := rf.archives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range archives {
+				got = append(got, stamps[a.Name()])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept archives %q, want %q", got, tt.want)
+			}
+			for _, name := range others {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("prune touched %s: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+// writeAged writes size bytes to path and sets its modification time.
+func writeAged(t *testing.T, path string, size int, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, make([]byte, size), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// gunzip returns the decompressed content of the archive at path.
+func gunzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.Name != "rule.log" {
+		t.Errorf("%s names %q, want rule.log", path, zr.Name)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fileFormatter formats the lines written to rule log files: the format chosen by Init,
+// never colored.
+var fileFormatter logrus.Formatter = &logrus.TextFormatter{
+	FullTimestamp:   true,
+	TimestampFormat: timestampFormat,
+	DisableColors:   true,
+}
+
+// ruleFileKey is the context key tagging the entries of a rule with its RuleFile.
+type ruleFileKey struct{}
+
+// installHook adds ruleFileHook to the global logger, once.
+var installHook sync.Once
+
+// RuleFile is the log file of a single rule (log_file). Entries derived from the entry
+// returned by Entry keep going to the global logger's output, and are also appended to
+// the file. A nil *RuleFile logs nowhere else, so callers need no checks when the rule has
+// no log file. It is safe for concurrent use.
+type RuleFile struct {
+	path string
+	rot  Rotation
+	now  func() time.Time
+	mu   sync.Mutex
+	f    *os.File
+	size int64 // current size of f, for rotation
+}
+
+// NewRuleFile returns the log file at path; nothing is written before Open.
+//
+// Parameters:
+//   - path: The file lines are appended to; missing parent directories are created by Open.
+//     An empty path disables the rule's log file.
+//   - rot: When to compress the file into an archive and which archives to keep; the zero
+//     value lets the file grow forever.
+//
+// Returns:
+//   - *RuleFile: The log file, or nil when path is empty.
+func NewRuleFile(path string, rot Rotation) *RuleFile {
+	if path == "" {
+		return nil
+	}
+	installHook.Do(func() { logger.AddHook(ruleFileHook{}) })
+	return &RuleFile{path: path, rot: rot, now: time.Now}
+}
+
+// Entry tags e so that it and every entry derived from it (WithField, WithError, ...)
+// are also written to the file.
+//
+// Parameters:
+//   - e: The rule's entry, typically carrying the rule field.
+//
+// Returns:
+//   - *logrus.Entry: The tagged entry, or e itself for a nil *RuleFile.
+func (rf *RuleFile) Entry(e *logrus.Entry) *logrus.Entry {
+	if rf == nil {
+		return e
+	}
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return e.WithContext(context.WithValue(ctx, ruleFileKey{}, rf))
+}
+
+// Open opens the file for appending. Until then, and after Close, lines are dropped.
+//
+// Returns:
+//   - error: An error if the file could not be opened.
+func (rf *RuleFile) Open() error {
+	if rf == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(rf.path), 0o755); err != nil {
+		return err
+	}
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.open()
+}
+
+// open opens the file for appending; rf.mu must be held.
+func (rf *RuleFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+// Close closes the file.
+//
+// Returns:
+//   - error: An error if the file could not be closed.
+func (rf *RuleFile) Close() error {
+	if rf == nil {
+		return nil
+	}
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
+
+// write appends an already formatted line.
+func (rf *RuleFile) write(line []byte) error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	// a failed rotation still leaves a file to write to, unless it could not be reopened
+	var rotErr error
+	if rf.rot.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(line)) > rf.rot.MaxSize {
+		if rotErr = rf.rotate(); rf.f == nil {
+			return rotErr
+		}
+	}
+	n, err := rf.f.Write(line)
+	rf.size += int64(n)
+	return errors.Join(rotErr, err)
+}
+
+// ruleFileHook copies the entries tagged by RuleFile.Entry to their rule's file.
+type ruleFileHook struct{}
+
+// Levels implements logrus.Hook; the logger's level already filters entries.
+func (ruleFileHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+// Fire implements logrus.Hook.
+func (ruleFileHook) Fire(e *logrus.Entry) error {
+	if e.Context == nil {
+		return nil
+	}
+	rf, ok := e.Context.Value(ruleFileKey{}).(*RuleFile)
+	if !ok {
+		return nil
+	}
+	line, err := fileFormatter.Format(e)
+	if err != nil {
+		return err
+	}
+	return rf.write(line)
+}
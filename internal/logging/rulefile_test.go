@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRuleFile(t *testing.T) {
+	out := logger.Out
+	logger.SetOutput(io.Discard)
+	t.Cleanup(func() { logger.SetOutput(out) })
+
+	dir := t.TempDir()
+	one := NewRuleFile(filepath.Join(dir, "nested", "one.log"), Rotation{})
+	two := NewRuleFile(filepath.Join(dir, "two.log"), Rotation{})
+	logOne := one.Entry(L().WithField("rule", "one"))
+	logTwo := two.Entry(L().WithField("rule", "two"))
+
+	logOne.Info("before open")
+	for _, rf := range []*RuleFile{one, two} {
+		if err := rf.Open(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logOne.Info("first")
+	logOne.WithField("stream", "stderr").WithError(io.EOF).Warn("derived")
+	logTwo.Info("second")
+	L().Info("global")
+	L().WithField("rule", "one").Info("untagged")
+	logOne.Debug("below the level")
+	for _, rf := range []*RuleFile{one, two} {
+		if err := rf.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logOne.Info("after close")
+
+	tests := []struct {
+		file    string
+		want    []string
+		notWant []string
+	}{
+		{filepath.Join(dir, "nested", "one.log"), []string{"first", "derived", "stream=stderr", "rule=one"},
+			[]string{"before open", "second", "global", "untagged", "below the level", "after close"}},
+		{filepath.Join(dir, "two.log"), []string{"second", "rule=two"},
+			[]string{"first", "derived", "global", "untagged"}},
+	}
+	for _, tt := range tests {
+		b, err := os.ReadFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := string(b)
+		for _, s := range tt.want {
+			if !strings.Contains(content, s) {
+				t.Errorf("%s lacks %q:\n%s", tt.file, s, content)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(content, s) {
+				t.Errorf("%s contains %q:\n%s", tt.file, s, content)
+			}
+		}
+		if strings.Contains(content, "\x1b[") {
+			t.Errorf("%s contains color codes", tt.file)
+		}
+	}
+}
+
+func TestNilRuleFile(t *testing.T) {
+	rf := NewRuleFile("", Rotation{})
+	if rf != nil {
+		t.Fatal("NewRuleFile(\"\") != nil")
+	}
+	e := logrus.NewEntry(logrus.New())
+	if rf.Entry(e) != e {
+		t.Error("a nil RuleFile changed the entry")
+	}
+	if err := rf.Open(); err != nil {
+		t.Error(err)
+	}
+	if err := rf.Close(); err != nil {
+		t.Error(err)
+	}
+}
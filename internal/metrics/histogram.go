@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// histogramBase is the upper bound of the first histogram bucket; every following bucket
+// doubles it, so 24 buckets cover 10ms up to roughly 23 hours.
+const (
+	histogramBase    = 10 * time.Millisecond
+	histogramBuckets = 24
+)
+
+// Histogram is a lightweight, fixed-size latency histogram with exponential buckets.
+//
+// Quantiles are approximated by the upper bound of the bucket they fall into, capped at
+// the largest observed value, which is accurate to within a factor of two and needs no
+// allocation per observation. The zero value is ready to use and safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	counts [histogramBuckets + 1]uint64 // last bucket catches everything above the bounds
+	total  uint64
+	max    time.Duration
+}
+
+// Observe records one duration.
+func (h *Histogram) Observe(d time.Duration) {
+	i, bound := 0, histogramBase
+	for i < histogramBuckets && d > bound {
+		i++
+		bound *= 2
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Count returns the number of observed durations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// Quantile returns the approximate q-quantile (0 < q <= 1) of the observed durations.
+//
+// Parameters:
+//   - q: The quantile, e.g. 0.95 for p95. Values outside (0, 1] are clamped.
+//
+// Returns:
+//   - time.Duration: The quantile estimate, or 0 when nothing was observed.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > h.total {
+		rank = h.total
+	}
+	var seen uint64
+	bound := histogramBase
+	for _, c := range h.counts[:histogramBuckets] {
+		if seen += c; seen >= rank {
+			return min(bound, h.max)
+		}
+		bound *= 2
+	}
+	return h.max
+}
+
+// Percentiles holds the latency summary reported at shutdown and by the status API.
+type Percentiles struct {
+	Count         uint64
+	P50, P95, P99 time.Duration
+}
+
+// Percentiles returns the p50, p95 and p99 estimates of h.
+func (h *Histogram) Percentiles() Percentiles {
+	return Percentiles{
+		Count: h.Count(),
+		P50:   h.Quantile(0.50),
+		P95:   h.Quantile(0.95),
+		P99:   h.Quantile(0.99),
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	ms := time.Millisecond
+	oneToHundred := make([]time.Duration, 100)
+	for i := range oneToHundred {
+		oneToHundred[i] = time.Duration(i+1) * ms
+	}
+	tests := []struct {
+		name     string
+		observed []time.Duration
+		q        float64
+		want     time.Duration
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single value below the first bound", []time.Duration{5 * ms}, 0.99, 5 * ms},
+		{"p50 is the bucket bound", oneToHundred, 0.50, 80 * ms},
+		{"p95 is capped at the maximum", oneToHundred, 0.95, 100 * ms},
+		{"p99", oneToHundred, 0.99, 100 * ms},
+		{"low quantile", oneToHundred, 0.05, 10 * ms},
+		{"q below range is the minimum's bucket", oneToHundred, 0, 10 * ms},
+		{"q above range is the maximum", oneToHundred, 2, 100 * ms},
+		{"beyond the last bucket", []time.Duration{ms, 30 * time.Hour}, 1, 30 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h Histogram
+			for _, d := range tt.observed {
+				h.Observe(d)
+			}
+			if got := h.Quantile(tt.q); got != tt.want {
+				t.Errorf("Quantile(%v) = %s, want %s", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	var h Histogram
+	var wg sync.WaitGroup
+	for i := range 1000 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := 15 * time.Millisecond // 90% in (10ms, 20ms]
+			if i%10 == 0 {
+				d = 3 * time.Second // 10% in (2.56s, 5.12s]
+			}
+			h.Observe(d)
+		}()
+	}
+	wg.Wait()
+	want := Percentiles{Count: 1000, P50: 20 * time.Millisecond, P95: 3 * time.Second, P99: 3 * time.Second}
+	if got := h.Percentiles(); got != want {
+		t.Errorf("Percentiles = %+v, want %+v", got, want)
+	}
+}
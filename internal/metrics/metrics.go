@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"gcs_sync/internal/config"
+	"time"
+)
+
+// Recorder receives per-rule sync events and turns them into metrics.
+type Recorder interface {
+	// SyncStarted is called right before a rule starts transferring.
+	SyncStarted(rule string)
+	// SyncFinished is called once the transfer ended, with its duration, the bytes gsutil
+	// reported to have transferred (0 when unknown) and its outcome.
+	SyncFinished(rule string, d time.Duration, bytes int64, err error)
+	// Heartbeat is called periodically for every running rule, with the time since its
+	// last sync finished (or since it started, before the first sync).
+	Heartbeat(rule string, sinceLastSync time.Duration)
+}
+
+// Nop is a Recorder that discards everything.
+type Nop struct{}
+
+// SyncStarted implements Recorder.
+func (Nop) SyncStarted(string) {}
+
+// SyncFinished implements Recorder.
+func (Nop) SyncFinished(string, time.Duration, int64, error) {}
+
+// Heartbeat implements Recorder.
+func (Nop) Heartbeat(string, time.Duration) {}
+
+// Multi fans events out to several recorders.
+type Multi []Recorder
+
+// SyncStarted implements Recorder.
+func (m Multi) SyncStarted(rule string) {
+	for _, r := range m {
+		r.SyncStarted(rule)
+	}
+}
+
+// SyncFinished implements Recorder.
+func (m Multi) SyncFinished(rule string, d time.Duration, bytes int64, err error) {
+	for _, r := range m {
+		r.SyncFinished(rule, d, bytes, err)
+	}
+}
+
+// Heartbeat implements Recorder.
+func (m Multi) Heartbeat(rule string, sinceLastSync time.Duration) {
+	for _, r := range m {
+		r.Heartbeat(rule, sinceLastSync)
+	}
+}
+
+// New builds the recorder for the metric sinks enabled in cfg.
+//
+// Parameters:
+//   - cfg: The loaded configuration.
+//   - prom: The Prometheus exporter, or nil when --metrics-addr is not set.
+//
+// Returns:
+//   - Recorder: The combined recorder; Nop when no sink is enabled.
+//   - error: An error if a sink could not be set up.
+func New(cfg *config.Config, prom *Prometheus) (Recorder, error) {
+	var m Multi
+	if prom != nil {
+		m = append(m, prom)
+	}
+	if cfg.StatsD != nil && cfg.StatsD.Address != "" {
+		s, err := DialStatsD(cfg.StatsD.Address, cfg.StatsD.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, s)
+	}
+	if len(m) == 0 {
+		return Nop{}, nil
+	}
+	return m, nil
+}
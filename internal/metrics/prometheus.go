@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the sync duration histogram.
+var durationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+// Prometheus collects sync metrics per rule and serves them in the Prometheus text
+// exposition format:
+//
+//	gcs_sync_syncs_started_total{rule="..."}       counter
+//	gcs_sync_syncs_succeeded_total{rule="..."}     counter
+//	gcs_sync_syncs_failed_total{rule="..."}        counter
+//	gcs_sync_transferred_bytes_total{rule="..."}   counter, as reported by gsutil
+//	gcs_sync_sync_duration_seconds{rule="..."}     histogram
+//	gcs_sync_seconds_since_last_sync{rule="..."}   gauge, as of the last heartbeat
+//
+// It implements both Recorder and http.Handler; the zero value is not usable, see
+// NewPrometheus.
+type Prometheus struct {
+	mu    sync.Mutex
+	rules map[string]*promRule
+}
+
+// promRule holds the metrics of one rule.
+type promRule struct {
+	started, succeeded, failed uint64
+	bytes                      int64
+	buckets                    []uint64 // cumulative counts per durationBuckets bound
+	count                      uint64
+	sum                        float64 // seconds
+	sinceLastSync              float64 // seconds, as of the last heartbeat; -1 before the first one
+}
+
+// NewPrometheus creates an exporter without any samples.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{rules: make(map[string]*promRule)}
+}
+
+// rule returns the metrics of a rule, creating them on first use. p.mu must be held.
+func (p *Prometheus) rule(name string) *promRule {
+	r, ok := p.rules[name]
+	if !ok {
+		r = &promRule{buckets: make([]uint64, len(durationBuckets)), sinceLastSync: -1}
+		p.rules[name] = r
+	}
+	return r
+}
+
+// SyncStarted implements Recorder.
+func (p *Prometheus) SyncStarted(rule string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rule(rule).started++
+}
+
+// SyncFinished implements Recorder.
+func (p *Prometheus) SyncFinished(rule string, d time.Duration, bytes int64, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := p.rule(rule)
+	if err != nil {
+		r.failed++
+	} else {
+		r.succeeded++
+	}
+	r.bytes += bytes
+	secs := d.Seconds()
+	for i, bound := range durationBuckets {
+		if secs <= bound {
+			r.buckets[i]++
+		}
+	}
+	r.count++
+	r.sum += secs
+}
+
+// Heartbeat implements Recorder.
+func (p *Prometheus) Heartbeat(rule string, sinceLastSync time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rule(rule).sinceLastSync = sinceLastSync.Seconds()
+}
+
+// ServeHTTP implements http.Handler, writing every metric of every rule.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.write(w)
+}
+
+// write writes the metrics in the text exposition format, rules sorted by name.
+func (p *Prometheus) write(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.rules))
+	for name := range p.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sample := func(metric, kind, help string, value func(r *promRule) string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric, help, metric, kind)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{rule=%s} %s\n", metric, quoteLabel(name), value(p.rules[name]))
+		}
+	}
+	counter := func(metric, help string, value func(r *promRule) string) {
+		sample(metric, "counter", help, value)
+	}
+	counter("gcs_sync_syncs_started_total", "Syncs started.",
+		func(r *promRule) string { return strconv.FormatUint(r.started, 10) })
+	counter("gcs_sync_syncs_succeeded_total", "Syncs that finished without error.",
+		func(r *promRule) string { return strconv.FormatUint(r.succeeded, 10) })
+	counter("gcs_sync_syncs_failed_total", "Syncs that failed.",
+		func(r *promRule) string { return strconv.FormatUint(r.failed, 10) })
+	counter("gcs_sync_transferred_bytes_total", "Bytes transferred, as reported by gsutil.",
+		func(r *promRule) string { return strconv.FormatInt(r.bytes, 10) })
+
+	const hist = "gcs_sync_sync_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Duration of syncs.\n# TYPE %s histogram\n", hist, hist)
+	for _, name := range names {
+		r, label := p.rules[name], quoteLabel(name)
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "%s_bucket{rule=%s,le=\"%s\"} %d\n", hist, label, strconv.FormatFloat(bound, 'g', -1, 64), r.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{rule=%s,le=\"+Inf\"} %d\n", hist, label, r.count)
+		fmt.Fprintf(w, "%s_sum{rule=%s} %s\n", hist, label, strconv.FormatFloat(r.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{rule=%s} %d\n", hist, label, r.count)
+	}
+	sample("gcs_sync_seconds_since_last_sync", "gauge", "Seconds since the last sync finished, as of the last heartbeat; -1 before the first one.",
+		func(r *promRule) string { return strconv.FormatFloat(r.sinceLastSync, 'g', -1, 64) })
+}
+
+// labelEscaper escapes a label value as the exposition format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel returns a quoted label value.
+func quoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
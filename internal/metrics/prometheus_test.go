@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusScrape(t *testing.T) {
+	p := NewPrometheus()
+	p.SyncStarted("photos")
+	p.SyncFinished("photos", 750*time.Millisecond, 4096, nil)
+	p.SyncStarted("photos")
+	p.SyncFinished("photos", 3*time.Second, 0, errors.New("boom"))
+	p.Heartbeat("photos", 90*time.Second)
+	p.SyncStarted(`odd "name"`)
+
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(b)
+
+	for _, line := range []string{
+		"# TYPE gcs_sync_syncs_started_total counter",
+		`gcs_sync_syncs_started_total{rule="photos"} 2`,
+		`gcs_sync_syncs_succeeded_total{rule="photos"} 1`,
+		`gcs_sync_syncs_failed_total{rule="photos"} 1`,
+		`gcs_sync_transferred_bytes_total{rule="photos"} 4096`,
+		"# TYPE gcs_sync_sync_duration_seconds histogram",
+		`gcs_sync_sync_duration_seconds_bucket{rule="photos",le="0.5"} 0`,
+		`gcs_sync_sync_duration_seconds_bucket{rule="photos",le="1"} 1`,
+		`gcs_sync_sync_duration_seconds_bucket{rule="photos",le="5"} 2`,
+		`gcs_sync_sync_duration_seconds_bucket{rule="photos",le="+Inf"} 2`,
+		`gcs_sync_sync_duration_seconds_sum{rule="photos"} 3.75`,
+		`gcs_sync_sync_duration_seconds_count{rule="photos"} 2`,
+		"# TYPE gcs_sync_seconds_since_last_sync gauge",
+		`gcs_sync_seconds_since_last_sync{rule="photos"} 90`,
+		`gcs_sync_syncs_started_total{rule="odd \"name\""} 1`,
+		`gcs_sync_seconds_since_last_sync{rule="odd \"name\""} -1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("scrape lacks %q", line)
+		}
+	}
+	// rules are sorted by name within each metric
+	if strings.Index(body, `started_total{rule="odd`) > strings.Index(body, `started_total{rule="photos"`) {
+		t.Errorf("rules not sorted:\n%s", body)
+	}
+	if t.Failed() {
+		t.Logf("scrape:\n%s", body)
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unsafeName matches characters that would break a StatsD metric name.
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// StatsD emits sync metrics in the plain StatsD line protocol:
+//
+//	<prefix>.<rule>.syncs.started:1|c
+//	<prefix>.<rule>.syncs.succeeded:1|c   (or .syncs.failed)
+//	<prefix>.<rule>.sync.duration:1234|ms
+//	<prefix>.<rule>.sync.bytes:5678|c     (only when gsutil reported a size)
+//	<prefix>.<rule>.heartbeat.since_last_sync:60000|g   (every --heartbeat-interval)
+type StatsD struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+}
+
+// NewStatsD creates a StatsD recorder writing one datagram per metric to w.
+//
+// Parameters:
+//   - w: The destination, typically a UDP connection.
+//   - prefix: Prepended to every metric name; may be empty.
+//
+// Returns:
+//   - *StatsD: The recorder.
+func NewStatsD(w io.Writer, prefix string) *StatsD {
+	return &StatsD{w: w, prefix: strings.Trim(prefix, ".")}
+}
+
+// DialStatsD creates a StatsD recorder sending to a UDP address such as "localhost:8125".
+func DialStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	return NewStatsD(conn, prefix), nil
+}
+
+// SyncStarted implements Recorder.
+func (s *StatsD) SyncStarted(rule string) {
+	s.send(rule, "syncs.started", "1|c")
+}
+
+// SyncFinished implements Recorder.
+func (s *StatsD) SyncFinished(rule string, d time.Duration, bytes int64, err error) {
+	if err != nil {
+		s.send(rule, "syncs.failed", "1|c")
+	} else {
+		s.send(rule, "syncs.succeeded", "1|c")
+	}
+	s.send(rule, "sync.duration", fmt.Sprintf("%d|ms", d.Milliseconds()))
+	if bytes > 0 {
+		s.send(rule, "sync.bytes", fmt.Sprintf("%d|c", bytes))
+	}
+}
+
+// Heartbeat implements Recorder.
+func (s *StatsD) Heartbeat(rule string, sinceLastSync time.Duration) {
+	s.send(rule, "heartbeat.since_last_sync", fmt.Sprintf("%d|g", sinceLastSync.Milliseconds()))
+}
+
+// send writes a single metric line. Delivery is best effort, as usual for StatsD.
+func (s *StatsD) send(rule, name, value string) {
+	parts := []string{unsafeName.ReplaceAllString(rule, "_"), name}
+	if s.prefix != "" {
+		parts = append([]string{s.prefix}, parts...)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = fmt.Fprintf(s.w, "%s:%s", strings.Join(parts, "."), value)
+}
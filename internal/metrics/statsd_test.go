@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestStatsDUDP sends every kind of event to a local UDP listener and checks the
+// datagrams: one metric per packet, named <prefix>.<rule>.<metric>, with its type.
+func TestStatsDUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP loopback: %v", err)
+	}
+	defer pc.Close()
+	s, err := DialStatsD(pc.LocalAddr().String(), ".gcs.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.SyncStarted("photos/raw")
+	s.SyncFinished("photos/raw", 1500*time.Millisecond, 2048, nil)
+	s.SyncFinished("docs", 20*time.Millisecond, 0, errors.New("boom"))
+	s.Heartbeat("docs", time.Minute)
+	want := []string{
+		"gcs.photos_raw.syncs.started:1|c",
+		"gcs.photos_raw.syncs.succeeded:1|c",
+		"gcs.photos_raw.sync.duration:1500|ms",
+		"gcs.photos_raw.sync.bytes:2048|c",
+		"gcs.docs.syncs.failed:1|c",
+		"gcs.docs.sync.duration:20|ms",
+		"gcs.docs.heartbeat.since_last_sync:60000|g",
+	}
+
+	var got []string
+	buf := make([]byte, 1500)
+	for range want {
+		if err := pc.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("after %q: %v", got, err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("received\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestStatsDNoPrefix(t *testing.T) {
+	var w lines
+	NewStatsD(&w, "").SyncStarted("a b")
+	if want := []string{"a_b.syncs.started:1|c"}; !reflect.DeepEqual([]string(w), want) {
+		t.Errorf("wrote %q, want %q", w, want)
+	}
+}
+
+// lines records every Write as one line, like a datagram socket.
+type lines []string
+
+func (l *lines) Write(p []byte) (int, error) {
+	*l = append(*l, string(p))
+	return len(p), nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package pidfile
+
+import "os"
+
+// alive reports whether a process with the given ID exists; replaceable for tests.
+// On this platform os.FindProcess fails for processes that do not exist.
+var alive = func(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
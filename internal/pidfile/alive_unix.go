@@ -0,0 +1,16 @@
+//go:build unix
+
+package pidfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// alive reports whether a process with the given ID exists; replaceable for tests.
+// Signal 0 only performs the existence and permission checks. EPERM means the process
+// exists but belongs to another user.
+var alive = func(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
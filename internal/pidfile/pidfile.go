@@ -0,0 +1,87 @@
+// Package pidfile writes and removes the daemon's PID file for service managers.
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Acquire writes the current process ID to path.
+//
+// An existing file naming a process that is still running makes Acquire fail, so a second
+// daemon cannot start with the same PID file. A file left behind by a crashed process
+// (unparsable, or naming a dead process) is replaced with a warning.
+//
+// Parameters:
+//   - path: The PID file to write; missing parent directories are created.
+//   - log: A logrus.Entry for reporting stale files.
+//
+// Returns:
+//   - error: An error if another live process owns the file or it could not be written.
+func Acquire(path string, log *logrus.Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(path)
+			}
+			return err
+		}
+		if !errors.Is(err, fs.ErrExist) || attempt > 0 {
+			return err
+		}
+		pid, err := read(path)
+		if err == nil && pid != os.Getpid() && alive(pid) {
+			return fmt.Errorf("pid file %s: gcs-sync is already running as pid %d", path, pid)
+		}
+		log.Warnf("removing stale pid file %s", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+}
+
+// Release removes the PID file written by Acquire. A file that was replaced by another
+// process in the meantime is left alone.
+//
+// Parameters:
+//   - path: The PID file passed to Acquire.
+//
+// Returns:
+//   - error: An error if the file could not be removed.
+func Release(path string) error {
+	pid, err := read(path)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && pid != os.Getpid() {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// read parses the process ID stored in a PID file.
+func read(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("pid file %s: invalid content %q", path, data)
+	}
+	return pid, nil
+}
@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"fmt"
+	"gcs_sync/internal/config"
+	"strings"
+	"time"
+)
+
+// Window is a parsed config.ActiveWindow.
+type Window struct {
+	start, end int // minutes since midnight
+	loc        *time.Location
+	days       [7]bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse validates an active window definition and converts it into a Window.
+//
+// Parameters:
+//   - w: The window as read from the configuration.
+//
+// Returns:
+//   - *Window: The parsed window.
+//   - error: An error if a time, the timezone or a weekday name is invalid.
+func Parse(w config.ActiveWindow) (*Window, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("active_window.start: %w", err)
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return nil, fmt.Errorf("active_window.end: %w", err)
+	}
+	loc := time.Local
+	if w.Timezone != "" {
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return nil, fmt.Errorf("active_window.timezone: %w", err)
+		}
+	}
+	res := &Window{start: start, end: end, loc: loc}
+	if len(w.Weekdays) == 0 {
+		for i := range res.days {
+			res.days[i] = true
+		}
+	}
+	for _, d := range w.Weekdays {
+		wd, ok := weekdays[strings.ToLower(d)[:min(3, len(d))]]
+		if !ok {
+			return nil, fmt.Errorf("active_window.weekdays: unknown day %q", d)
+		}
+		res.days[wd] = true
+	}
+	return res, nil
+}
+
+// Active reports whether t falls inside the window.
+// Windows whose end is before their start span midnight; the weekday is taken
+// from t in the window's timezone.
+func (w *Window) Active(t time.Time) bool {
+	t = t.In(w.loc)
+	if !w.days[t.Weekday()] {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// parseClock converts "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
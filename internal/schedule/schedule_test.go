@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"gcs_sync/internal/config"
+	"testing"
+	"time"
+)
+
+func TestWindowActive(t *testing.T) {
+	// 2024-05-03 is a Friday
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC) }
+	tests := []struct {
+		name   string
+		window config.ActiveWindow
+		t      time.Time
+		want   bool
+	}{
+		{"inside", config.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "UTC"}, at(3, 12, 0), true},
+		{"start is inclusive", config.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "UTC"}, at(3, 9, 0), true},
+		{"end is exclusive", config.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "UTC"}, at(3, 17, 0), false},
+		{"before", config.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "UTC"}, at(3, 8, 59), false},
+		{"across midnight, late", config.ActiveWindow{Start: "22:00", End: "06:00", Timezone: "UTC"}, at(3, 23, 30), true},
+		{"across midnight, early", config.ActiveWindow{Start: "22:00", End: "06:00", Timezone: "UTC"}, at(3, 5, 59), true},
+		{"across midnight, daytime", config.ActiveWindow{Start: "22:00", End: "06:00", Timezone: "UTC"}, at(3, 12, 0), false},
+		{"weekday listed", config.ActiveWindow{Start: "00:00", End: "23:59", Timezone: "UTC", Weekdays: []string{"fri"}}, at(3, 12, 0), true},
+		{"weekday not listed", config.ActiveWindow{Start: "00:00", End: "23:59", Timezone: "UTC", Weekdays: []string{"Saturday", "SUN"}}, at(3, 12, 0), false},
+		{"full weekday names", config.ActiveWindow{Start: "00:00", End: "23:59", Timezone: "UTC", Weekdays: []string{"Saturday"}}, at(4, 12, 0), true},
+		// the weekday is that of t, so the early hours after a listed night do not count
+		{"weekday of the early hours", config.ActiveWindow{Start: "22:00", End: "06:00", Timezone: "UTC", Weekdays: []string{"fri"}}, at(4, 1, 0), false},
+		{"timezone of the window", config.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "UTC"},
+			time.Date(2024, 5, 3, 12, 0, 0, 0, time.FixedZone("UTC-8", -8*3600)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := Parse(tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Active(tt.t); got != tt.want {
+				t.Errorf("Active(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		window config.ActiveWindow
+	}{
+		{"bad start", config.ActiveWindow{Start: "9am", End: "17:00"}},
+		{"bad end", config.ActiveWindow{Start: "09:00", End: "24:00"}},
+		{"bad timezone", config.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "Mars/Olympus"}},
+		{"bad weekday", config.ActiveWindow{Start: "09:00", End: "17:00", Weekdays: []string{"funday"}}},
+		{"empty weekday", config.ActiveWindow{Start: "09:00", End: "17:00", Weekdays: []string{""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.window); err == nil {
+				t.Errorf("Parse(%+v) succeeded, want an error", tt.window)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/fx"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// readHeaderTimeout bounds how long a client may take to send request headers.
+const readHeaderTimeout = 10 * time.Second
+
+// Registry collects HTTP handlers per listen address and serves them.
+//
+// Features that expose endpoints (profiling, status, ...) register their handlers
+// on the address configured for them; features sharing an address share one server.
+type Registry struct {
+	log     *logrus.Logger
+	muxes   map[string]*http.ServeMux
+	order   []string
+	servers []*http.Server
+}
+
+// New creates an empty Registry.
+//
+// Parameters:
+//   - log: A pointer to a logrus.Logger for reporting server errors.
+//
+// Returns:
+//   - *Registry: A registry without any handlers.
+func New(log *logrus.Logger) *Registry {
+	return &Registry{log: log, muxes: make(map[string]*http.ServeMux)}
+}
+
+// Handle registers h for pattern on the server listening at addr.
+// An empty addr means the feature is disabled and the call is a no-op.
+func (r *Registry) Handle(addr, pattern string, h http.Handler) {
+	if addr == "" {
+		return
+	}
+	mux, ok := r.muxes[addr]
+	if !ok {
+		mux = http.NewServeMux()
+		r.muxes[addr] = mux
+		r.order = append(r.order, addr)
+	}
+	mux.Handle(pattern, h)
+}
+
+// Start binds every registered address and serves it in the background.
+//
+// Returns:
+//   - error: An error if an address could not be bound; servers started so far keep running until Stop.
+func (r *Registry) Start() error {
+	for _, addr := range r.order {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{Handler: r.muxes[addr], ReadHeaderTimeout: readHeaderTimeout}
+		r.servers = append(r.servers, srv)
+		r.log.Infof("http server listening on %s", ln.Addr())
+		go func() {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				r.log.WithError(err).Error("http server stopped with error")
+			}
+		}()
+	}
+	return nil
+}
+
+// Stop gracefully shuts down all servers.
+//
+// Parameters:
+//   - ctx: Bounds how long in-flight requests may take to finish.
+//
+// Returns:
+//   - error: The first shutdown error, if any.
+func (r *Registry) Stop(ctx context.Context) error {
+	var errs []error
+	for _, srv := range r.servers {
+		errs = append(errs, srv.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Register ties the registry's servers to the application lifecycle.
+func Register(lc fx.Lifecycle, r *Registry) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error { return r.Start() },
+		OnStop:  r.Stop,
+	})
+}
+
+// Pprof exposes the net/http/pprof handlers under /debug/pprof/ at addr.
+// The endpoints reveal internals of the process, so addr should not be publicly reachable.
+func Pprof(r *Registry, addr string) {
+	r.Handle(addr, "/debug/pprof/", http.HandlerFunc(pprof.Index))
+	r.Handle(addr, "/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+	r.Handle(addr, "/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+	r.Handle(addr, "/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	r.Handle(addr, "/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"github.com/sirupsen/logrus/hooks/test"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPprof(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	r := New(logger)
+	Pprof(r, "")
+	if len(r.muxes) != 0 {
+		t.Fatalf("an empty address registered %d servers", len(r.muxes))
+	}
+	Pprof(r, "localhost:6060")
+	r.Handle("localhost:6060", "/status", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "status") }))
+	if len(r.order) != 1 {
+		t.Fatalf("features on one address got %d servers, want 1", len(r.order))
+	}
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/debug/pprof/", http.StatusOK, "goroutine"},
+		{"/debug/pprof/goroutine?debug=1", http.StatusOK, "goroutine profile"},
+		{"/debug/pprof/cmdline", http.StatusOK, ""},
+		{"/debug/pprof/symbol", http.StatusOK, "num_symbols"},
+		{"/status", http.StatusOK, "status"},
+		{"/metrics", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.muxes["localhost:6060"].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("GET %s = %d, want %d with %q", tt.path, rec.Code, tt.wantCode, tt.wantBody)
+		}
+	}
+}
+
+// TestStartStop serves the profiling endpoints on a free port and shuts them down again.
+func TestStartStop(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	r := New(logger)
+	Pprof(r, "127.0.0.1:0")
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	msg := hook.LastEntry().Message
+	addr := strings.TrimPrefix(msg, "http server listening on ")
+	if addr == msg {
+		t.Fatalf("logged %q, want the listen address", msg)
+	}
+	resp, err := http.Get("http://" + addr + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /debug/pprof/ = %d, want 200", resp.StatusCode)
+	}
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get("http://" + addr + "/debug/pprof/"); err == nil {
+		t.Error("the server still answers after Stop")
+	}
+}
+
+func TestStartBusyAddress(t *testing.T) {
+	busy := httptest.NewServer(http.NotFoundHandler())
+	defer busy.Close()
+	logger, _ := test.NewNullLogger()
+	r := New(logger)
+	Pprof(r, strings.TrimPrefix(busy.URL, "http://"))
+	if err := r.Start(); err == nil {
+		r.Stop(context.Background())
+		t.Error("Start bound an address in use")
+	}
+}
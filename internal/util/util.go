@@ -1,8 +1,13 @@
 package util
 
 import (
+	"fmt"
+	"os"
 	"os/user"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -22,3 +27,135 @@ func Expand(path string) string {
 	}
 	return path
 }
+
+// ExpandEnv replaces $VAR and ${VAR} references in a sync location with the values of
+// the corresponding environment variables, e.g. gs://${BUCKET}/backups.
+//
+// Undefined variables expand to the empty string, as in a shell; they are reported so the
+// caller can warn about them. The tilde is left alone, see Expand.
+//
+// Parameters:
+//   - location: A local path or cloud URL.
+//
+// Returns:
+//   - string: The location with every variable reference replaced.
+//   - []string: The names of the referenced variables that are not set, in order of appearance.
+func ExpandEnv(location string) (string, []string) {
+	var missing []string
+	res := os.Expand(location, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	return res, missing
+}
+
+// IsRemote reports whether a sync location is a cloud URL (e.g. gs://bucket/path)
+// rather than a local path. file:// URLs are local.
+func IsRemote(location string) bool {
+	return strings.Contains(location, "://") && !strings.HasPrefix(location, "file://")
+}
+
+// IsNested reports whether one of the two local paths contains the other (or both are equal).
+// Paths are expanded and made absolute before comparison.
+func IsNested(a, b string) bool {
+	a, b = absLocal(a), absLocal(b)
+	return a == b || isUnder(a, b) || isUnder(b, a)
+}
+
+// absLocal expands a local path or file:// URL into a clean absolute path.
+func absLocal(p string) string {
+	p = Expand(strings.TrimPrefix(p, "file://"))
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// isUnder reports whether child lies strictly inside parent.
+func isUnder(child, parent string) bool {
+	rel, err := filepath.Rel(parent, child)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// JoinLocation appends a unix-style relative path to a sync location, which may be
+// a cloud URL (gs://, s3://, ...), a file:// URL or a local path.
+//
+// Each kind is normalised by its own conventions: cloud URLs always use "/" with exactly
+// one separator between base and rel and no duplicate slashes inside rel, file:// URLs
+// keep their scheme, and local paths use the platform separator. rel is cleaned first, so
+// ".." elements cannot climb above base; an empty rel (or ".") returns base unchanged.
+//
+// Parameters:
+//   - base: The location to extend.
+//   - rel: A unix-style path relative to base.
+//
+// Returns:
+//   - string: The joined location.
+func JoinLocation(base, rel string) string {
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return base
+	}
+	switch {
+	case IsRemote(base):
+		return strings.TrimRight(base, "/") + "/" + rel
+	case strings.HasPrefix(base, "file://"):
+		return "file://" + filepath.ToSlash(filepath.Join(filepath.FromSlash(strings.TrimPrefix(base, "file://")), filepath.FromSlash(rel)))
+	default:
+		return filepath.Join(base, filepath.FromSlash(rel))
+	}
+}
+
+// Batches splits items into consecutive chunks of at most size elements, keeping order.
+// A size below 1 yields a single chunk holding every item.
+//
+// Parameters:
+//   - items: The items to split.
+//   - size: The maximum number of items per chunk.
+//
+// Returns:
+//   - [][]T: The chunks; nil when items is empty.
+func Batches[T any](items []T, size int) [][]T {
+	if len(items) == 0 {
+		return nil
+	}
+	if size < 1 {
+		size = len(items)
+	}
+	out := make([][]T, 0, (len(items)+size-1)/size)
+	for len(items) > size {
+		out = append(out, items[:size:size])
+		items = items[size:]
+	}
+	return append(out, items)
+}
+
+// sizeFormat matches sizes like 512, 10k, 10M, 150MiB or 2GB.
+var sizeFormat = regexp.MustCompile(`(?i)^\s*(\d+)\s*([kmgt])?(?:i?b)?\s*$`)
+
+// ParseSize parses a size with an optional binary unit, as gsutil writes them: "10m" and
+// "10MiB" both mean 10 × 1024 × 1024 bytes.
+//
+// Parameters:
+//   - s: The size, e.g. "512", "10k" or "2GiB".
+//
+// Returns:
+//   - int64: The size in bytes.
+//   - error: An error if s is not a size.
+func ParseSize(s string) (int64, error) {
+	m := sizeFormat.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a size like 10M", s)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a size like 10M: %w", s, err)
+	}
+	if m[2] != "" {
+		n <<= 10 * (strings.Index("kmgt", strings.ToLower(m[2])) + 1)
+	}
+	return n, nil
+}
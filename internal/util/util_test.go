@@ -0,0 +1,119 @@
+package util
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"10k", 10 << 10, false},
+		{"10K", 10 << 10, false},
+		{"10M", 10 << 20, false},
+		{"10MiB", 10 << 20, false},
+		{"2GB", 2 << 30, false},
+		{"1t", 1 << 40, false},
+		{" 5 m ", 5 << 20, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"1.5M", 0, true},
+		{"-1M", 0, true},
+		{"10x", 0, true},
+		{"lots", 0, true},
+		{"99999999999999999999", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("BUCKET", "backups")
+	t.Setenv("EMPTY", "")
+	tests := []struct {
+		in          string
+		want        string
+		wantMissing []string
+	}{
+		{"gs://bucket/data", "gs://bucket/data", nil},
+		{"gs://${BUCKET}/data", "gs://backups/data", nil},
+		{"gs://$BUCKET/$BUCKET", "gs://backups/backups", nil},
+		{"/srv/$EMPTY/data", "/srv//data", nil},
+		{"gs://$UNSET_A/${UNSET_B}/x", "gs:////x", []string{"UNSET_A", "UNSET_B"}},
+		{"~/$BUCKET", "~/backups", nil},
+	}
+	for _, tt := range tests {
+		got, missing := ExpandEnv(tt.in)
+		if got != tt.want || strings.Join(missing, ",") != strings.Join(tt.wantMissing, ",") {
+			t.Errorf("ExpandEnv(%q) = %q, %q, want %q, %q", tt.in, got, missing, tt.want, tt.wantMissing)
+		}
+	}
+}
+
+func TestJoinLocation(t *testing.T) {
+	tests := []struct {
+		base, rel string
+		want      string
+	}{
+		{"gs://bucket/data", "a/b", "gs://bucket/data/a/b"},
+		{"gs://bucket/data/", "/a/", "gs://bucket/data/a"},
+		{"gs://bucket/data", ".", "gs://bucket/data"},
+		{"gs://bucket/data", "../../escape", "gs://bucket/data/escape"},
+		{"file:///mnt/backup", "a/b", "file:///mnt/backup/a/b"},
+		{"/mnt/backup", "a/b", filepath.Join("/mnt/backup", "a", "b")},
+		// per-kind normalisation
+		{"gs://bucket", "a//b/", "gs://bucket/a/b"},
+		{"gs://bucket//", "a", "gs://bucket/a"},
+		{"s3://bucket/data", "./a/./b", "s3://bucket/data/a/b"},
+		{"gs://bucket/data", "", "gs://bucket/data"},
+		{"file:///mnt/backup/", "/a//b", "file:///mnt/backup/a/b"},
+		{"file:///mnt/backup", "../x", "file:///mnt/backup/x"},
+		{"/mnt/backup/", "a//b/", filepath.Join("/mnt/backup", "a", "b")},
+		{"/mnt/backup", "", "/mnt/backup"},
+		{"relative", "a", filepath.Join("relative", "a")},
+	}
+	for _, tt := range tests {
+		if got := JoinLocation(tt.base, tt.rel); got != tt.want {
+			t.Errorf("JoinLocation(%q, %q) = %q, want %q", tt.base, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	tests := []struct {
+		items []int
+		size  int
+		want  [][]int
+	}{
+		{nil, 2, nil},
+		{[]int{1, 2, 3}, 5, [][]int{{1, 2, 3}}},
+		{[]int{1, 2, 3, 4}, 2, [][]int{{1, 2}, {3, 4}}},
+		{[]int{1, 2, 3, 4, 5}, 2, [][]int{{1, 2}, {3, 4}, {5}}},
+		{[]int{1, 2, 3}, 1, [][]int{{1}, {2}, {3}}},
+		{[]int{1, 2, 3}, 0, [][]int{{1, 2, 3}}},
+		{[]int{1, 2, 3}, -1, [][]int{{1, 2, 3}}},
+	}
+	for _, tt := range tests {
+		got := Batches(tt.items, tt.size)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Batches(%v, %d) = %v, want %v", tt.items, tt.size, got, tt.want)
+		}
+	}
+	// appending to a chunk must not overwrite the next one
+	items := []int{1, 2, 3, 4}
+	chunks := Batches(items, 2)
+	_ = append(chunks[0], 9)
+	if items[2] != 3 {
+		t.Error("appending to a chunk changed the following items")
+	}
+}
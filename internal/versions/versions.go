@@ -0,0 +1,113 @@
+// Package versions checks that the installed Cloud SDK tools are recent enough.
+package versions
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultMinGsutil is the oldest gsutil release gcs-sync is known to work with.
+const DefaultMinGsutil = "5.0"
+
+// Tool describes how to ask a command line tool for its version.
+type Tool struct {
+	Name    string         // executable name, e.g. "gsutil"
+	Args    []string       // arguments printing the version
+	Pattern *regexp.Regexp // first submatch is the version
+}
+
+var (
+	// Gsutil prints e.g. "gsutil version: 5.27".
+	Gsutil = Tool{Name: "gsutil", Args: []string{"version"}, Pattern: regexp.MustCompile(`gsutil version:?\s*([0-9][0-9.]*)`)}
+	// Gcloud prints e.g. "Google Cloud SDK 456.0.0".
+	Gcloud = Tool{Name: "gcloud", Args: []string{"version"}, Pattern: regexp.MustCompile(`Google Cloud SDK\s+([0-9][0-9.]*)`)}
+)
+
+// run executes a tool and returns its combined output; replaceable for tests.
+var run = func(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	return string(out), err
+}
+
+// Parse extracts the version from a tool's output.
+//
+// Returns:
+//   - string: The version, e.g. "5.27".
+//   - bool: false if the output contains no version.
+func (t Tool) Parse(out string) (string, bool) {
+	m := t.Pattern.FindStringSubmatch(out)
+	if m == nil {
+		return "", false
+	}
+	return strings.TrimRight(m[1], "."), true
+}
+
+// Installed runs the tool and returns its version.
+func (t Tool) Installed() (string, error) {
+	out, err := run(t.Name, t.Args...)
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", t.Name, strings.Join(t.Args, " "), err)
+	}
+	v, ok := t.Parse(out)
+	if !ok {
+		return "", fmt.Errorf("cannot find a version in the output of %s %s", t.Name, strings.Join(t.Args, " "))
+	}
+	return v, nil
+}
+
+// Compare compares two dotted numeric versions; missing components count as 0.
+//
+// Returns:
+//   - int: -1 if a < b, 0 if they are equal, +1 if a > b.
+func Compare(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Check verifies that a tool is at least version min. An empty min skips the check.
+//
+// Parameters:
+//   - t: The tool.
+//   - min: The minimum version.
+//   - strict: Return an error for a missing or outdated tool instead of only logging it.
+//   - log: A logrus.Entry for the warning.
+//
+// Returns:
+//   - error: An error for a missing or outdated tool, only when strict.
+func Check(t Tool, min string, strict bool, log *logrus.Entry) error {
+	if min == "" {
+		return nil
+	}
+	v, err := t.Installed()
+	if err == nil && Compare(v, min) < 0 {
+		err = fmt.Errorf("%s %s is older than the required %s", t.Name, v, min)
+	}
+	if err == nil {
+		log.Debugf("%s %s satisfies the minimum %s", t.Name, v, min)
+		return nil
+	}
+	if strict {
+		return err
+	}
+	log.WithError(err).Warn("tool version check failed")
+	return nil
+}
@@ -0,0 +1,102 @@
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/gsutil"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// absences counts, per pull scope, for how many consecutive pulls each local file has
+// been missing remotely (remote_delete_polls).
+//
+// A remote listing glitch that omits objects for a single poll must not wipe the
+// corresponding local files, so deletions are only honoured once a file was reported as
+// orphaned by enough pulls in a row.
+type absences struct {
+	polls int
+	mu    sync.Mutex
+	seen  map[string]map[string]int // scope dst → local file URL → consecutive absences
+}
+
+// observe records the orphans a pull of one scope reported. Files no longer reported
+// start counting from zero again.
+//
+// Parameters:
+//   - scope: The pull's destination, identifying the scope.
+//   - orphans: The local file URLs a deleting pull would remove.
+//
+// Returns:
+//   - []string: The orphans that have now been missing remotely for enough pulls.
+func (a *absences) observe(scope string, orphans []string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.seen == nil {
+		a.seen = make(map[string]map[string]int)
+	}
+	prev := a.seen[scope]
+	next := make(map[string]int, len(orphans))
+	var due []string
+	for _, o := range orphans {
+		if n := prev[o] + 1; n >= a.polls {
+			due = append(due, o)
+		} else {
+			next[o] = n
+		}
+	}
+	a.seen[scope] = next
+	return due
+}
+
+// pullDelayingDeletes pulls a scope without deletions and then removes only the local
+// files that have been missing remotely for remote_delete_polls consecutive pulls.
+//
+// Parameters:
+//   - src: The remote source of the pull.
+//   - dst: The local destination.
+//   - opts: The pull's options, with Delete set.
+//   - l: A logrus.Entry for logging.
+//
+// Returns:
+//   - gsutil.SyncResult: What the pull transferred, counting the local files removed here.
+//   - error: An error if the pull failed; deletions are skipped in that case.
+func (rr *ruleRunner) pullDelayingDeletes(src, dst string, opts gsutil.Options, l *logrus.Entry) (gsutil.SyncResult, error) {
+	diff, err := gsutil.DryRunDiff(rr.ctx, src, dst, opts, l)
+	var due []string
+	if err != nil {
+		l.WithError(err).Warn("failed to list orphaned local files, pulling without deletions")
+	} else {
+		due = rr.absent.observe(dst, diff.Removals)
+		if n := len(diff.Removals) - len(due); n > 0 {
+			l.Infof("keeping %d local file(s) missing remotely until remote_delete_polls=%d is reached", n, rr.absent.polls)
+		}
+	}
+
+	opts.Delete = false
+	var res gsutil.SyncResult
+	if err := rr.retry().Do(rr.ctx, func() (err error) {
+		res, err = rr.backend.RSync(rr.ctx, src, dst, opts, l)
+		return err
+	}, l); err != nil {
+		return res, err
+	}
+	for _, u := range due {
+		p := strings.TrimPrefix(u, "file://")
+		if opts.DryRun {
+			l.Infof("would remove %s", p)
+			res.Removed++
+			continue
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			l.WithError(err).Warnf("failed to remove %s", p)
+			continue
+		}
+		l.Infof("removed %s, missing remotely for %d pull(s)", p, rr.absent.polls)
+		rr.audited(u)
+		res.Removed++
+	}
+	return res, nil
+}
@@ -0,0 +1,85 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAbsences(t *testing.T) {
+	a := &absences{polls: 3}
+	steps := []struct {
+		scope   string
+		orphans []string
+		want    []string
+	}{
+		{"/a", []string{"x", "y"}, nil},
+		{"/a", []string{"x", "y"}, nil},
+		{"/b", []string{"x"}, nil}, // scopes count separately
+		{"/a", []string{"x"}, []string{"x"}},
+		// y reappeared remotely in between and starts over
+		{"/a", []string{"y"}, nil},
+		{"/a", []string{"y"}, nil},
+		{"/a", []string{"x", "y"}, []string{"y"}},
+		// x was deleted and counted from zero again
+		{"/a", []string{"x"}, nil},
+		{"/b", []string{"x"}, nil},
+		{"/b", []string{"x"}, []string{"x"}},
+	}
+	for i, s := range steps {
+		if got := a.observe(s.scope, s.orphans); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("step %d: observe(%s, %q) = %q, want %q", i, s.scope, s.orphans, got, s.want)
+		}
+	}
+}
+
+// TestRemoteDeletePolls pulls a destination missing one local file and checks that the
+// file survives until remote_delete_polls pulls in a row reported it.
+func TestRemoteDeletePolls(t *testing.T) {
+	src := t.TempDir()
+	orphan := filepath.Join(src, "orphan.txt")
+	if err := os.WriteFile(orphan, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fakeGsutil(t, `echo "Would remove file://`+orphan+`"`+"\n")
+	fail := false
+	b := &fakeBackend{rsync: func(_ context.Context, c fakeCall) error {
+		if c.opts.Delete {
+			t.Error("the pull itself deletes")
+		}
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}}
+	rr := testRunner(t, config.SyncRule{Src: src, Directions: []config.SyncDirection{config.RemoteToLocal},
+		RemotePollWindow: time.Minute, DeleteOrphans: true, RemoteDeletePolls: 3}, b)
+	rr.ctx = context.Background()
+
+	steps := []struct {
+		fail       bool
+		wantExists bool
+	}{
+		{false, true},
+		{false, true},
+		{true, true}, // the third report arrives with a failed pull
+		{false, true},
+		{false, true},
+		{false, false},
+	}
+	for i, s := range steps {
+		fail = s.fail
+		_, err := rr.doSync("test", true, rr.log)
+		if (err != nil) != s.fail {
+			t.Fatalf("pull %d: %v", i+1, err)
+		}
+		if _, err := os.Stat(orphan); (err == nil) != s.wantExists {
+			t.Fatalf("after pull %d: orphan exists = %v, want %v", i+1, err == nil, s.wantExists)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"gcs_sync/internal/gsutil"
+	"gcs_sync/internal/util"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// appendOnlySync transfers new and locally newer files of a scope without deleting or
+// overwriting anything that is newer at the destination.
+//
+// The candidate set comes from an rsync dry-run without `-d`; candidates whose destination
+// copy was updated after the local file was modified are dropped, the rest are copied in
+// batches per destination folder.
+//
+// Parameters:
+//   - sc: The source/destination pair to transfer.
+//   - opts: The gsutil options for the transfer; Delete must be off.
+//   - l: The logger entry carrying the sync reason.
+//
+// Returns:
+//   - error: An error if the diff, the destination listing or a copy failed.
+func (rr *ruleRunner) appendOnlySync(sc scope, opts gsutil.Options, l *logrus.Entry) error {
+	diff, err := gsutil.DryRunDiff(rr.ctx, sc.src, sc.dst, opts, l)
+	if err != nil {
+		return err
+	}
+	if len(diff.Copies) == 0 {
+		l.Debug("append-only: nothing to transfer")
+		return nil
+	}
+	dstTimes, err := rr.destinationTimes(rr.ctx, sc.dst, opts, l)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	groups := make(map[string][]string)
+	for _, c := range diff.Copies {
+		src := strings.TrimPrefix(c.Src, "file://")
+		fi, err := os.Stat(src)
+		if err != nil {
+			l.WithError(err).Warnf("append-only: cannot stat %s, skipping", src)
+			continue
+		}
+		dst := strings.TrimPrefix(c.Dst, "file://")
+		if t, ok := dstTimes(dst); ok && t.After(fi.ModTime()) {
+			l.Debugf("append-only: keeping newer %s", c.Dst)
+			kept++
+			continue
+		}
+		dir := dst[:strings.LastIndex(dst, "/")+1]
+		groups[dir] = append(groups[dir], src)
+	}
+	if kept > 0 {
+		l.Infof("append-only: preserved %d newer destination object(s)", kept)
+	}
+	return rr.copyGroups(groups, opts, l)
+}
+
+// batch is one `gsutil cp` of several files into the same destination folder.
+type batch struct {
+	dir  string
+	srcs []string
+}
+
+// copyGroups copies files into their destination folders, at most the rule's batch size
+// per gsutil invocation and up to transfer_workers invocations at a time.
+//
+// Parameters:
+//   - groups: Source files keyed by destination folder.
+//   - opts: The gsutil options for the transfers.
+//   - l: The logger entry carrying the sync reason.
+//
+// Returns:
+//   - error: An error if any batch failed; remaining batches are still attempted.
+func (rr *ruleRunner) copyGroups(groups map[string][]string, opts gsutil.Options, l *logrus.Entry) error {
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var batches []batch
+	failed := 0
+	for _, dir := range dirs {
+		if !util.IsRemote(dir) && !opts.DryRun {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				l.WithError(err).Errorf("cannot create %s", dir)
+				failed++
+				continue
+			}
+		}
+		for _, srcs := range util.Batches(groups[dir], rr.rule.Batch()) {
+			batches = append(batches, batch{dir: dir, srcs: srcs})
+		}
+	}
+	failed += parallel(rr.workers(), len(batches), func(i int) error {
+		return rr.retry().Do(rr.ctx, func() error { return gsutil.CopyMany(rr.ctx, batches[i].srcs, batches[i].dir, opts, l) }, l)
+	})
+	if failed > 0 {
+		return fmt.Errorf("%d batch transfer(s) failed", failed)
+	}
+	return nil
+}
+
+// destinationTimes returns a lookup of last-modified times for objects under dst.
+// Cloud destinations are listed once, until ctx is cancelled; local destinations are
+// stat'ed on demand.
+func (rr *ruleRunner) destinationTimes(ctx context.Context, dst string, opts gsutil.Options, l *logrus.Entry) (func(string) (time.Time, bool), error) {
+	if !util.IsRemote(dst) {
+		return func(p string) (time.Time, bool) {
+			fi, err := os.Stat(p)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return fi.ModTime(), true
+		}, nil
+	}
+	times, err := gsutil.UpdateTimes(ctx, dst, opts, l)
+	if err != nil {
+		return nil, err
+	}
+	return func(u string) (time.Time, bool) {
+		t, ok := times[u]
+		return t, ok
+	}, nil
+}
@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAppendOnly syncs a tree whose destination holds a newer copy of one file and checks
+// that append_only copies the rest, keeps the newer object and never deletes.
+func TestAppendOnly(t *testing.T) {
+	src := t.TempDir()
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, mtime := range map[string]time.Time{"a.txt": time.Now(), "b.txt": old, "dir/c.txt": old} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the dry-run proposes every file; b.txt was updated remotely after it was modified here
+	calls := fakeGsutil(t, `case "$*" in
+*" rsync "*)
+	for f in a.txt b.txt dir/c.txt; do echo "Would copy file://`+src+`/$f to gs://bucket/data/$f"; done
+	echo "Would remove gs://bucket/data/gone.txt" ;;
+*" ls "*)
+	echo "         1  2022-01-01T00:00:00Z  gs://bucket/data/a.txt"
+	echo "         1  2024-01-01T00:00:00Z  gs://bucket/data/b.txt"
+	echo "         1  2024-01-01T00:00:00Z  gs://bucket/data/gone.txt" ;;
+esac
+`)
+	b := &fakeBackend{}
+	rr := testRunner(t, config.SyncRule{Src: src, AppendOnly: true}, b)
+	rr.cfg.AllowRootDelete = false // nothing is deleted, so root may sync
+
+	if _, err := rr.doSync("test", false, rr.log); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.transfers()) != 0 {
+		t.Errorf("append_only ran %d rsyncs", len(b.transfers()))
+	}
+	var copies [][]string
+	for _, c := range calls() {
+		switch i := slices.Index(c, "rsync"); {
+		case i >= 0:
+			if slices.Contains(c, "-d") {
+				t.Errorf("the dry-run would delete: %q", c)
+			}
+		case slices.Contains(c, "cp"):
+			copies = append(copies, c[slices.Index(c, "cp")+1:])
+		case !slices.Contains(c, "ls"):
+			t.Errorf("unexpected gsutil %q", c)
+		}
+	}
+	// batches run in parallel
+	slices.SortFunc(copies, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	want := [][]string{
+		{filepath.Join(src, "a.txt"), "gs://bucket/data/"},
+		{filepath.Join(src, "dir", "c.txt"), "gs://bucket/data/dir/"},
+	}
+	if !reflect.DeepEqual(copies, want) {
+		t.Errorf("copied %q, want %q", copies, want)
+	}
+}
+
+func TestCopyGroupsBatches(t *testing.T) {
+	calls := fakeGsutil(t, "exit 0\n")
+	rr := testRunner(t, config.SyncRule{BatchSize: 2}, &fakeBackend{})
+	groups := map[string][]string{
+		"gs://bucket/data/":     {"/src/1", "/src/2", "/src/3", "/src/4", "/src/5"},
+		"gs://bucket/data/dir/": {"/src/dir/6"},
+	}
+	if err := rr.copyGroups(groups, rr.options(), rr.log); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range calls() {
+		got = append(got, strings.Join(c[slices.Index(c, "cp")+1:], " "))
+	}
+	slices.Sort(got) // batches run in parallel
+	want := []string{
+		"/src/1 /src/2 gs://bucket/data/",
+		"/src/3 /src/4 gs://bucket/data/",
+		"/src/5 gs://bucket/data/",
+		"/src/dir/6 gs://bucket/data/dir/",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("gsutil cp\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package watcher
+
+import (
+	"gcs_sync/internal/gsutil"
+	"time"
+)
+
+// SyncResult describes one completed sync of a rule.
+type SyncResult struct {
+	Rule     string        // rule ID (see config.SyncRule.ID)
+	Reason   string        // why the sync ran, e.g. "initial" or "debounce"
+	Start    time.Time     // when the transfer started
+	Duration time.Duration // how long it took
+	DryRun   bool          // whether gsutil only reported the changes
+	Err      error         // nil on success
+	// Transfer holds the objects and bytes gsutil reported, summed over the rule's scopes.
+	// Fields gsutil did not report stay zero.
+	Transfer gsutil.SyncResult
+}
+
+// Callbacks lets embedders react to rule activity without parsing logs.
+// Every hook is optional; hooks run synchronously on the rule's goroutine, so they
+// should return quickly.
+type Callbacks struct {
+	// OnSyncStart is called right before a rule starts transferring.
+	OnSyncStart func(rule, reason string)
+	// OnSyncEnd is called after every transfer, successful or not.
+	OnSyncEnd func(res SyncResult)
+	// OnError is called for failed syncs and for file watcher errors.
+	OnError func(rule string, err error)
+}
+
+// syncStart invokes OnSyncStart if set.
+func (c Callbacks) syncStart(rule, reason string) {
+	if c.OnSyncStart != nil {
+		c.OnSyncStart(rule, reason)
+	}
+}
+
+// syncEnd invokes OnSyncEnd, and OnError for a failed sync, if set.
+func (c Callbacks) syncEnd(res SyncResult) {
+	if c.OnSyncEnd != nil {
+		c.OnSyncEnd(res)
+	}
+	if res.Err != nil {
+		c.error(res.Rule, res.Err)
+	}
+}
+
+// error invokes OnError if set.
+func (c Callbacks) error(rule string, err error) {
+	if c.OnError != nil {
+		c.OnError(rule, err)
+	}
+}
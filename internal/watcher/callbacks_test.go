@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/config"
+	"reflect"
+	"testing"
+)
+
+// TestCallbacks syncs rules whose transfer succeeds or fails and records the hooks called.
+func TestCallbacks(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{"success", nil, []string{"start cb debounce", "end cb debounce <nil>"}},
+		{"failure", boom, []string{"start cb debounce", "end cb debounce boom", "error cb boom"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakeBackend{rsync: func(context.Context, fakeCall) error { return tt.err }}
+			rr := testRunner(t, config.SyncRule{Name: "cb"}, b)
+			var got []string
+			var res SyncResult
+			rr.callbacks = Callbacks{
+				OnSyncStart: func(rule, reason string) { got = append(got, "start "+rule+" "+reason) },
+				OnSyncEnd: func(r SyncResult) {
+					res = r
+					got = append(got, "end "+r.Rule+" "+r.Reason+" "+errString(r.Err))
+				},
+				OnError: func(rule string, err error) { got = append(got, "error "+rule+" "+err.Error()) },
+			}
+			rr.syncOnce("debounce")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("callbacks %q, want %q", got, tt.want)
+			}
+			if res.Start.IsZero() || res.Duration < 0 || !errors.Is(res.Err, tt.err) {
+				t.Errorf("OnSyncEnd got %+v", res)
+			}
+		})
+	}
+
+	// no hooks set: nothing to call, nothing to crash
+	rr := testRunner(t, config.SyncRule{}, &fakeBackend{rsync: func(context.Context, fakeCall) error { return boom }})
+	rr.syncOnce("debounce")
+}
+
+// TestCallbacksSkippedSync checks that a deferred sync calls no hooks.
+func TestCallbacksSkippedSync(t *testing.T) {
+	rr := testRunner(t, config.SyncRule{PauseWhenMetered: true}, &fakeBackend{})
+	g := &fakeGate{}
+	g.metered.Store(true)
+	rr.gate = g
+	called := false
+	rr.callbacks = Callbacks{
+		OnSyncStart: func(string, string) { called = true },
+		OnSyncEnd:   func(SyncResult) { called = true },
+	}
+	rr.syncOnce("debounce")
+	if called {
+		t.Error("a deferred sync called the sync hooks")
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
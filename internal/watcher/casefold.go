@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"fmt"
+	"gcs_sync/internal/filter"
+	"gcs_sync/internal/gsutil"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Policies for objects whose names differ only in case (case_collisions).
+const (
+	collisionsWarn       = "warn"       // log the collision, pull as usual (the last copy wins)
+	collisionsSkip       = "skip"       // log and leave every colliding object out of the pull
+	collisionsQuarantine = "quarantine" // like skip, and download each variant into quarantine_dir
+)
+
+// parseCollisionPolicy validates a rule's case_collisions setting.
+// An empty policy disables collision detection.
+func parseCollisionPolicy(rule string, policy, quarantineDir string) error {
+	switch policy {
+	case "", collisionsWarn, collisionsSkip:
+		return nil
+	case collisionsQuarantine:
+		if quarantineDir == "" {
+			return fmt.Errorf("rule %q: case_collisions: quarantine needs quarantine_dir", rule)
+		}
+		return nil
+	default:
+		return fmt.Errorf("rule %q: unknown case_collisions policy %q", rule, policy)
+	}
+}
+
+// caseCollisions groups object paths that would land on the same file of a
+// case-insensitive file system.
+//
+// Parameters:
+//   - base: The prefix the object URLs are listed under.
+//   - urls: The object URLs.
+//
+// Returns:
+//   - [][]string: Each group of two or more colliding paths, relative to base and sorted.
+//     Groups are ordered by their first path.
+func caseCollisions(base string, urls []string) [][]string {
+	prefix := strings.TrimRight(base, "/") + "/"
+	byFold := make(map[string][]string)
+	for _, u := range urls {
+		rel, ok := strings.CutPrefix(u, prefix)
+		if !ok || rel == "" {
+			continue
+		}
+		k := strings.ToLower(rel)
+		byFold[k] = append(byFold[k], rel)
+	}
+	var groups [][]string
+	for _, g := range byFold {
+		if len(g) > 1 {
+			sort.Strings(g)
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// collisionExcludes detects case collisions below the remote src of a pull and applies
+// the rule's case_collisions policy.
+//
+// Parameters:
+//   - src: The remote source of the pull.
+//   - opts: The gsutil options for the pull.
+//   - l: The logger entry carrying the sync reason.
+//
+// Returns:
+//   - *regexp.Regexp: An exclusion matching the colliding objects, or nil when they are pulled.
+//   - error: An error if the listing failed or an object could not be quarantined.
+func (rr *ruleRunner) collisionExcludes(src string, opts gsutil.Options, l *logrus.Entry) (*regexp.Regexp, error) {
+	urls, err := gsutil.Objects(rr.ctx, src, opts, l)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s for case collisions: %w", src, err)
+	}
+	groups := caseCollisions(src, urls)
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	var excluded []string
+	for _, g := range groups {
+		l.Warnf("objects differing only in case collide on case-insensitive file systems: %s", strings.Join(g, ", "))
+		excluded = append(excluded, g...)
+	}
+	switch rr.rule.CaseCollisions {
+	case collisionsWarn:
+		return nil, nil
+	case collisionsQuarantine:
+		if err := rr.quarantine(src, groups, opts, l); err != nil {
+			return nil, err
+		}
+	}
+	l.Warnf("skipping %d colliding object(s)", len(excluded))
+	return filter.Regex(excluded), nil
+}
+
+// quarantine downloads every variant of each collision group into its own numbered
+// folder below quarantine_dir, e.g. <quarantine_dir>/1/File.txt and <quarantine_dir>/2/file.txt.
+func (rr *ruleRunner) quarantine(src string, groups [][]string, opts gsutil.Options, l *logrus.Entry) error {
+	dir := rr.quarantineDir
+	failed := 0
+	for _, g := range groups {
+		for i, rel := range g {
+			dst := filepath.Join(dir, strconv.Itoa(i+1), filepath.FromSlash(rel))
+			if !opts.DryRun {
+				if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+					return err
+				}
+			}
+			if err := gsutil.Copy(rr.ctx, strings.TrimRight(src, "/")+"/"+rel, dst, opts, l); err != nil {
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d object(s) could not be quarantined", failed)
+	}
+	l.Infof("quarantined colliding objects in %s", dir)
+	return nil
+}
@@ -0,0 +1,104 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/ignore"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCollisionPolicy(t *testing.T) {
+	tests := []struct {
+		policy, quarantineDir string
+		wantErr               bool
+	}{
+		{"", "", false},
+		{"warn", "", false},
+		{"skip", "", false},
+		{"quarantine", "/var/quarantine", false},
+		{"quarantine", "", true},
+		{"rename", "", true},
+	}
+	for _, tt := range tests {
+		if err := parseCollisionPolicy("r", tt.policy, tt.quarantineDir); (err != nil) != tt.wantErr {
+			t.Errorf("parseCollisionPolicy(%q, %q) = %v, want error %v", tt.policy, tt.quarantineDir, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCaseCollisions(t *testing.T) {
+	urls := []string{
+		"gs://b/data/README", "gs://b/data/readme", "gs://b/data/ReadMe",
+		"gs://b/data/dir/a.txt", "gs://b/data/Dir/a.txt",
+		"gs://b/data/unique.txt", "gs://b/other/readme", "gs://b/data/",
+	}
+	want := [][]string{{"Dir/a.txt", "dir/a.txt"}, {"README", "ReadMe", "readme"}}
+	for _, base := range []string{"gs://b/data", "gs://b/data/"} {
+		if got := caseCollisions(base, urls); !reflect.DeepEqual(got, want) {
+			t.Errorf("caseCollisions(%q) = %q, want %q", base, got, want)
+		}
+	}
+	if got := caseCollisions("gs://b/data", []string{"gs://b/data/a", "gs://b/data/b"}); got != nil {
+		t.Errorf("caseCollisions without collisions = %q", got)
+	}
+}
+
+// TestCollisionPolicies pulls a destination holding README and readme under each policy.
+func TestCollisionPolicies(t *testing.T) {
+	tests := []struct {
+		policy         string
+		wantExcluded   bool
+		wantQuarantine bool
+	}{
+		{"warn", false, false},
+		{"skip", true, false},
+		{"quarantine", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			calls := fakeGsutil(t, `case "$*" in *" ls "*)
+	printf '%s\n' gs://bucket/data/README gs://bucket/data/readme gs://bucket/data/other ;;
+esac
+`)
+			quarantine := t.TempDir()
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{Directions: []config.SyncDirection{config.RemoteToLocal},
+				RemotePollWindow: time.Minute, CaseCollisions: tt.policy, QuarantineDir: quarantine}, b)
+			rr.ctx = context.Background()
+			if _, err := rr.doSync("test", true, rr.log); err != nil {
+				t.Fatal(err)
+			}
+			pulls := b.transfers()
+			if len(pulls) != 1 {
+				t.Fatalf("%d transfers, want one pull", len(pulls))
+			}
+			for path, want := range map[string]bool{"README": tt.wantExcluded, "readme": tt.wantExcluded, "other": false} {
+				if got := ignore.Match(path, pulls[0].opts.Ignore); got != want {
+					t.Errorf("pull excludes %s = %v, want %v", path, got, want)
+				}
+			}
+
+			var copies []string
+			for _, c := range calls() {
+				if i := slices.Index(c, "cp"); i >= 0 {
+					copies = append(copies, strings.Join(c[i+1:], " "))
+				}
+			}
+			var want []string
+			if tt.wantQuarantine {
+				want = []string{
+					"gs://bucket/data/README " + filepath.Join(quarantine, "1", "README"),
+					"gs://bucket/data/readme " + filepath.Join(quarantine, "2", "readme"),
+				}
+			}
+			if !reflect.DeepEqual(copies, want) {
+				t.Errorf("quarantined %q, want %q", copies, want)
+			}
+		})
+	}
+}
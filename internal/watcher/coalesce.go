@@ -0,0 +1,68 @@
+package watcher
+
+import "sync"
+
+// syncGuard makes sure only one sync of a rule runs at a time.
+//
+// The debounce timer, the polling ticker and the window ticker all trigger syncs from
+// their own goroutines. A request arriving while a sync runs is not started in parallel,
+// which would run two gsutil processes on the same src/dst; it marks the rule dirty
+// instead, and exactly one more sync runs once the current one finishes.
+type syncGuard struct {
+	mu       sync.Mutex
+	running  bool
+	dirty    bool
+	pullOnly bool // the pending sync only needs to pull (every coalesced request was a pull)
+}
+
+// begin claims the guard for a sync.
+//
+// Parameters:
+//   - pullOnly: Whether the requested sync only pulls.
+//
+// Returns:
+//   - bool: true if the caller may sync now; false if the request was coalesced into
+//     the sync already running.
+func (g *syncGuard) begin(pullOnly bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.running {
+		g.running = true
+		return true
+	}
+	if !g.dirty {
+		g.dirty, g.pullOnly = true, pullOnly
+	} else {
+		g.pullOnly = g.pullOnly && pullOnly
+	}
+	return false
+}
+
+// tryBegin claims the guard only if no sync is running. Unlike begin, a busy guard is
+// left untouched, for work the running sync covers anyway.
+func (g *syncGuard) tryBegin() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running {
+		return false
+	}
+	g.running = true
+	return true
+}
+
+// next is called when a sync finished. It releases the guard unless requests were
+// coalesced in the meantime, in which case the caller keeps it and syncs once more.
+//
+// Returns:
+//   - again: Whether one more sync must run.
+//   - pullOnly: Whether that sync only pulls.
+func (g *syncGuard) next() (again, pullOnly bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.dirty {
+		g.running = false
+		return false, false
+	}
+	g.dirty = false
+	return true, g.pullOnly
+}
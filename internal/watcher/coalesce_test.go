@@ -0,0 +1,81 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSyncGuard(t *testing.T) {
+	var g syncGuard
+	steps := []struct {
+		name         string
+		call         func() (bool, bool)
+		want, wantPO bool
+	}{
+		{"first sync runs", func() (bool, bool) { return g.begin(true), false }, true, false},
+		{"a pull is coalesced", func() (bool, bool) { return g.begin(true), false }, false, false},
+		{"tryBegin leaves a busy guard alone", func() (bool, bool) { return g.tryBegin(), false }, false, false},
+		{"one more pull runs", g.next, true, true},
+		{"a push is coalesced", func() (bool, bool) { return g.begin(false), false }, false, false},
+		{"and a pull on top", func() (bool, bool) { return g.begin(true), false }, false, false},
+		{"one more full sync runs", g.next, true, false},
+		{"released", g.next, false, false},
+		{"tryBegin claims a free guard", func() (bool, bool) { return g.tryBegin(), false }, true, false},
+	}
+	for _, s := range steps {
+		got, gotPO := s.call()
+		if got != s.want || gotPO != s.wantPO {
+			t.Fatalf("%s: got %v, %v, want %v, %v", s.name, got, gotPO, s.want, s.wantPO)
+		}
+	}
+}
+
+// TestSyncsNeverOverlap fires many sync requests at a rule while one runs, and checks
+// that no two transfers overlap and that the requests collapse into a single extra sync.
+func TestSyncsNeverOverlap(t *testing.T) {
+	var active, peak atomic.Int32
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	b := &fakeBackend{rsync: func(ctx context.Context, c fakeCall) error {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	}}
+	rr := testRunner(t, config.SyncRule{}, b)
+
+	var wg sync.WaitGroup
+	run := func() {
+		defer wg.Done()
+		rr.syncOnce("debounce")
+	}
+	wg.Add(1)
+	go run()
+	<-started
+	for range 20 {
+		wg.Add(1)
+		go run()
+	}
+	eventually(t, "the requests to be coalesced", func() bool {
+		rr.guard.mu.Lock()
+		defer rr.guard.mu.Unlock()
+		return rr.guard.dirty
+	})
+	close(release)
+	wg.Wait()
+
+	if p := peak.Load(); p != 1 {
+		t.Errorf("%d transfers overlapped, want 1 at a time", p)
+	}
+	if n := len(b.transfers()); n != 2 {
+		t.Errorf("%d transfers ran, want 2: the running sync and one for every request coalesced into it", n)
+	}
+}
@@ -0,0 +1,125 @@
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/gsutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// immediateDeleteDelay batches the removals of one burst, e.g. `rm *.tmp`, into a single
+// `gsutil rm`.
+const immediateDeleteDelay = time.Second
+
+// deletes collects local removals for immediate_deletes, which mirrors them with a
+// targeted `gsutil rm` instead of waiting for the next debounced rsync.
+type deletes struct {
+	delay time.Duration
+	limit int
+	mu    sync.Mutex
+	urls  map[string]string // local path → object URL
+	timer *time.Timer
+}
+
+// newDeletes returns the removal batch of a rule with immediate_deletes, or nil.
+// Removals wait at least recreate_grace, so that a file saved by delete-and-recreate
+// is never deleted remotely.
+func newDeletes(rule config.SyncRule) *deletes {
+	if !rule.ImmediateDeletes {
+		return nil
+	}
+	limit := rule.ImmediateDeleteLimit
+	if limit <= 0 {
+		limit = config.DefaultDeleteThreshold
+	}
+	return &deletes{
+		delay: max(immediateDeleteDelay, rule.RecreateGrace),
+		limit: limit,
+		urls:  make(map[string]string),
+	}
+}
+
+// add queues the removal of path, whose object is url, and arms flush after the delay.
+func (d *deletes) add(path, url string, flush func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.urls[path] = url
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.delay, flush)
+	} else {
+		d.timer.Reset(d.delay)
+	}
+}
+
+// stop discards the queued removals when the watcher exits. It is a no-op on nil.
+func (d *deletes) stop() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	clear(d.urls)
+}
+
+// take returns the queued removals whose files are still gone, and empties the queue.
+func (d *deletes) take() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var urls []string
+	for path, url := range d.urls {
+		if _, err := os.Lstat(path); err != nil {
+			urls = append(urls, url)
+		}
+	}
+	clear(d.urls)
+	sort.Strings(urls)
+	return urls
+}
+
+// flushDeletes deletes the objects of the queued removals with one `gsutil rm`.
+//
+// Nothing is deleted, and the removals are left to the next debounced sync, when a sync
+// of the rule is running (its -d covers them), syncing is not allowed right now (active
+// window, metered connection, root without allow_root_delete) or more files than
+// immediate_delete_limit were removed at once, which is more likely an accident or an
+// unmounted volume than a cleanup. A failed `gsutil rm`, e.g. for a file that was never
+// uploaded, is logged and left to the next sync as well.
+func (rr *ruleRunner) flushDeletes() {
+	urls := rr.deletes.take()
+	if len(urls) == 0 {
+		return
+	}
+	l := rr.log.WithField("reason", "immediate delete")
+	if len(urls) > rr.deletes.limit {
+		l.Warnf("%d files removed at once, more than immediate_delete_limit=%d; leaving the deletions to the next sync", len(urls), rr.deletes.limit)
+		return
+	}
+	if !rr.guard.tryBegin() {
+		l.Debug("sync running, leaving the deletions to it")
+		return
+	}
+	opts := rr.options()
+	switch blocked := rr.blocked(l); {
+	case blocked != "":
+		l.Debugf("%s, leaving the deletions to the next sync", blocked)
+	case rr.checkRoot(opts) != nil:
+		l.Debug("running as root without allow_root_delete, leaving the deletions to the next sync")
+	default:
+		release, ok := rr.acquireSlot()
+		if !ok {
+			break
+		}
+		if err := gsutil.Remove(rr.ctx, urls, opts, l); err != nil {
+			l.WithError(err).Warn("immediate delete failed, leaving it to the next sync")
+		}
+		release()
+	}
+	if again, pullOnly := rr.guard.next(); again {
+		rr.syncHeld("coalesced", pullOnly)
+	}
+}
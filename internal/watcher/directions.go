@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"fmt"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/gsutil"
+	"gcs_sync/internal/logging"
+)
+
+// half is one rsync of a scope in a single direction.
+type half struct {
+	src, dst string
+	pull     bool // remote → local (the rule's dst to its src)
+}
+
+// pulls reports whether the rule transfers remote changes to the local tree.
+func (rr *ruleRunner) pulls() bool {
+	return containsDir(rr.rule.Directions, config.RemoteToLocal) || containsDir(rr.rule.Directions, config.Full)
+}
+
+// pushes reports whether the rule transfers local changes to the destination.
+// Rules without directions push, as they always did.
+func (rr *ruleRunner) pushes() bool {
+	return len(rr.rule.Directions) == 0 || containsDir(rr.rule.Directions, config.LocalToRemote) || containsDir(rr.rule.Directions, config.Full)
+}
+
+// twoWay reports whether the rule both pulls and pushes.
+func (rr *ruleRunner) twoWay() bool {
+	return rr.pulls() && rr.pushes()
+}
+
+// halves returns the transfers of a scope in the order they must run: the pull first,
+// so that a two-way push never deletes remote objects that were not pulled yet.
+func (rr *ruleRunner) halves(sc scope) []half {
+	var hs []half
+	if rr.pulls() {
+		hs = append(hs, half{src: sc.dst, dst: sc.src, pull: true})
+	}
+	if rr.pushes() {
+		hs = append(hs, half{src: sc.src, dst: sc.dst})
+	}
+	return hs
+}
+
+// halfOptions adapts the rule's gsutil options to one direction.
+//
+// Deletion (`-d`) belongs to the side that owns the data: pushes delete remotely, pulls
+// only delete local files when the rule sets delete_orphans (never for two-way rules,
+// which would lose local files that were not pushed yet). Pulls of two-way and
+// append_only rules skip files that are newer locally (`-u`), so local edits waiting for
+// the debounce window are never overwritten. Pushes apply the rule's source filters; pulls
+// only use the ignore patterns, since the filters describe local files.
+//
+// Parameters:
+//   - h: The transfer.
+//   - opts: The rule's options, as returned by options.
+//
+// Returns:
+//   - gsutil.Options: The options for h.
+//   - error: An error if the push exclusion list could not be built.
+func (rr *ruleRunner) halfOptions(h half, opts gsutil.Options) (gsutil.Options, error) {
+	if h.pull {
+		opts.Delete = opts.Delete && rr.rule.DeleteOrphans
+		opts.SkipNewer = rr.twoWay() || rr.rule.AppendOnly
+		opts.Ignore = rr.ign
+		return opts, nil
+	}
+	ign, err := rr.excludes(h.src)
+	if err != nil {
+		return opts, err
+	}
+	opts.Ignore = ign
+	return opts, nil
+}
+
+// expandMirror turns mirror: true into the options it stands for: the target becomes an
+// exact, checksum-verified copy of the source, including deletions.
+//
+// Pushes already delete; for a remote_to_local rule, mirror implies delete_orphans.
+// Mirroring is one-way by nature, so it is rejected for full rules and append_only.
+//
+// Parameters:
+//   - rule: The rule to expand in place.
+//
+// Returns:
+//   - error: An error if mirror conflicts with the rule's other settings.
+func expandMirror(rule *config.SyncRule) error {
+	switch {
+	case rule.AppendOnly:
+		return fmt.Errorf("rule %q: mirror and append_only contradict each other", rule.ID())
+	case containsDir(rule.Directions, config.Full),
+		containsDir(rule.Directions, config.LocalToRemote) && containsDir(rule.Directions, config.RemoteToLocal):
+		return fmt.Errorf("rule %q: mirror needs a single one-way direction", rule.ID())
+	}
+	target := rule.Dst
+	if containsDir(rule.Directions, config.RemoteToLocal) {
+		rule.DeleteOrphans = true
+		target = rule.Src
+	}
+	logging.L().WithField("rule", rule.ID()).
+		Warnf("mirror: anything in %s that is missing from the other side will be deleted", target)
+	return nil
+}
@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestHalves(t *testing.T) {
+	type want struct {
+		pull, delete, skipNewer bool
+	}
+	push := want{false, true, false}
+	tests := []struct {
+		name string
+		rule config.SyncRule
+		want []want
+	}{
+		{"no directions push", config.SyncRule{}, []want{push}},
+		{"local_to_remote", config.SyncRule{Directions: []config.SyncDirection{config.LocalToRemote}}, []want{push}},
+		{"remote_to_local keeps local files", config.SyncRule{Directions: []config.SyncDirection{config.RemoteToLocal}},
+			[]want{{true, false, false}}},
+		{"remote_to_local with delete_orphans", config.SyncRule{Directions: []config.SyncDirection{config.RemoteToLocal}, DeleteOrphans: true},
+			[]want{{true, true, false}}},
+		// the pull runs first, never deletes and keeps newer local files for the push
+		{"full", config.SyncRule{Directions: []config.SyncDirection{config.Full}},
+			[]want{{true, false, true}, push}},
+		{"both one-way directions", config.SyncRule{Directions: []config.SyncDirection{config.LocalToRemote, config.RemoteToLocal}},
+			[]want{{true, false, true}, push}},
+		{"append_only never deletes", config.SyncRule{Directions: []config.SyncDirection{config.RemoteToLocal}, AppendOnly: true, DeleteOrphans: true},
+			[]want{{true, false, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := testRunner(t, tt.rule, &fakeBackend{})
+			src, dst := rr.srcRoot, rr.rule.Dst
+			opts := rr.options()
+			var got []want
+			for _, h := range rr.halves(scope{src: src, dst: dst}) {
+				// pulls run dst → src, pushes src → dst
+				wantSrc, wantDst := src, dst
+				if h.pull {
+					wantSrc, wantDst = dst, src
+				}
+				if h.src != wantSrc || h.dst != wantDst {
+					t.Errorf("half pull=%v runs %s → %s, want %s → %s", h.pull, h.src, h.dst, wantSrc, wantDst)
+				}
+				hopts, err := rr.halfOptions(h, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, want{h.pull, hopts.Delete, hopts.SkipNewer})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("halves = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
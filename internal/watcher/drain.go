@@ -0,0 +1,17 @@
+package watcher
+
+// drain runs the final sync of a rule with drain_on_shutdown, once stop was received with
+// changes not synced yet: still waiting for the debounce window, or deferred by a blocker
+// (active_window, metered connection, maintenance_file, missing source root).
+//
+// A deferred sync only runs if its blocker has cleared since; otherwise it would wait for
+// the next window tick, which never comes, and the changes are reported as left behind.
+// The sync is bounded by the shutdown deadline, which kills it when it expires.
+func (rr *ruleRunner) drain() {
+	if blocked := rr.blocked(rr.log); blocked != "" {
+		rr.log.Warnf("%s, leaving unsynced changes behind on shutdown", blocked)
+		return
+	}
+	rr.log.Info("draining unsynced changes before shutdown")
+	rr.syncOnce("shutdown drain")
+}
@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDrainOnShutdown stops rules with work still queued and checks which of them sync
+// it before returning.
+func TestDrainOnShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		drain       bool
+		change      bool // a change waits for the debounce window
+		deferred    bool // the initial sync was deferred by the maintenance file
+		clear       bool // the maintenance file is gone again before stopping
+		wantOnClose int  // transfers by the shutdown
+	}{
+		{"pending change is drained", true, true, false, false, 1},
+		{"pending change is discarded without drain", false, true, false, false, 0},
+		{"nothing queued", true, false, false, false, 0},
+		{"deferred sync whose blocker cleared", true, false, true, true, 1},
+		{"deferred sync still blocked", true, false, true, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maintenance := filepath.Join(t.TempDir(), "maintenance")
+			if tt.deferred {
+				if err := os.WriteFile(maintenance, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b := &fakeBackend{}
+			// changes stay queued: the debounce window never passes during the test
+			rr := testRunner(t, config.SyncRule{DebounceWindow: time.Hour, DrainOnShutdown: tt.drain}, b)
+			rr.maintenanceFile = maintenance
+			stop := make(chan struct{})
+			done := startRunner(context.Background(), rr, stop)
+			eventually(t, "the initial sync", rr.initialDone.Load)
+			if rr.deferred.Load() != tt.deferred {
+				t.Fatalf("initial sync deferred = %v, want %v", rr.deferred.Load(), tt.deferred)
+			}
+			before := len(b.transfers())
+
+			if tt.change {
+				if err := os.WriteFile(filepath.Join(rr.srcRoot, "new.txt"), []byte("new"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(100 * time.Millisecond) // for the event to arm the debounce timer
+			}
+			if tt.clear {
+				if err := os.Remove(maintenance); err != nil {
+					t.Fatal(err)
+				}
+			}
+			close(stop)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			if got := len(b.transfers()) - before; got != tt.wantOnClose {
+				t.Errorf("%d transfers on shutdown, want %d", got, tt.wantOnClose)
+			}
+		})
+	}
+}
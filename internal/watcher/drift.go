@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"gcs_sync/internal/gsutil"
+	"github.com/sirupsen/logrus"
+)
+
+// checkDrift compares every pushed destination with its source by checksum and repairs it
+// with a sync if they differ (drift_check).
+//
+// Event-driven syncs only notice local changes; objects overwritten or deleted at the
+// destination by another writer stay wrong until the next local change touches them.
+// The repair sync compares checksums as well, so that drifted objects whose size and
+// modification time still match are re-uploaded too.
+//
+// The check and its repair hold the rule's syncGuard, so no other sync runs in between or
+// compares checksums by accident; while a sync runs, the check waits for the next tick.
+func (rr *ruleRunner) checkDrift() {
+	l := rr.log.WithField("reason", "drift check")
+	if !rr.guard.tryBegin() {
+		l.Debug("sync running, skipping drift check")
+		return
+	}
+	if drift := rr.drift(l); drift > 0 {
+		l.Warnf("destination drifted from the source in %d object(s), repairing", drift)
+		rr.repairing.Store(true)
+		rr.syncGuarded("drift repair", false)
+		rr.repairing.Store(false)
+	}
+	if again, pullOnly := rr.guard.next(); again {
+		rr.syncHeld("coalesced", pullOnly)
+	}
+}
+
+// drift counts the objects in which the pushed destinations differ from their sources.
+// A blocked rule, or a failed diff, counts as no drift; failures are logged.
+func (rr *ruleRunner) drift(l *logrus.Entry) int {
+	if blocked := rr.blocked(l); blocked != "" {
+		l.Debugf("%s, skipping drift check", blocked)
+		return 0
+	}
+	opts := rr.options()
+	opts.Checksum = true
+	drift := 0
+	for _, sc := range rr.scopes() {
+		for _, h := range rr.halves(sc) {
+			if h.pull {
+				continue
+			}
+			hopts, err := rr.halfOptions(h, opts)
+			if err != nil {
+				l.WithError(err).Warnf("failed to build exclusion list for %s, skipping drift check", h.src)
+				return 0
+			}
+			diff, err := gsutil.DryRunDiff(rr.ctx, h.src, h.dst, hopts, l)
+			if err != nil {
+				l.WithError(err).Warnf("drift check of %s failed", h.dst)
+				return 0
+			}
+			for _, c := range diff.Copies {
+				l.Debugf("drifted: %s", c.Dst)
+			}
+			for _, r := range diff.Removals {
+				l.Debugf("drifted: %s (not in source)", r)
+			}
+			drift += len(diff.Copies) + len(diff.Removals)
+		}
+	}
+	if drift == 0 {
+		l.Debug("destination matches the source")
+	}
+	return drift
+}
@@ -0,0 +1,81 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestDriftRepairHoldsTheGuard checks, under -race as well, that a drift repair never
+// overlaps another sync of the rule: only the repair compares checksums, a sync requested
+// meanwhile runs after it, and no check starts while another sync runs.
+func TestDriftRepairHoldsTheGuard(t *testing.T) {
+	tests := []struct {
+		name      string
+		busy      bool // a sync is running when the check is due
+		wantDiffs int
+		want      []bool // Checksum of every transfer, in order
+	}{
+		{"repair, then the sync requested meanwhile", false, 1, []bool{true, false}},
+		{"skipped while a sync runs", true, 0, []bool{false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := fakeGsutil(t, `echo "Would copy file:///src/a.txt to gs://bucket/data/a.txt" >&2`+"\n")
+			started, release := make(chan struct{}, 2), make(chan struct{})
+			b := &fakeBackend{rsync: func(ctx context.Context, c fakeCall) error {
+				started <- struct{}{}
+				<-release
+				return nil
+			}}
+			rr := testRunner(t, config.SyncRule{DriftCheck: time.Hour}, b)
+
+			var wg sync.WaitGroup
+			run := func(f func()) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					f()
+				}()
+			}
+			if tt.busy {
+				run(func() { rr.syncOnce("debounce") })
+				<-started
+				rr.checkDrift()
+			} else {
+				run(rr.checkDrift)
+				<-started
+				run(func() { rr.syncOnce("debounce") })
+				eventually(t, "the sync to be coalesced", func() bool {
+					rr.guard.mu.Lock()
+					defer rr.guard.mu.Unlock()
+					return rr.guard.dirty
+				})
+			}
+			close(release)
+			wg.Wait()
+
+			if got := len(diffs()); got != tt.wantDiffs {
+				t.Errorf("ran %d drift diff(s), want %d", got, tt.wantDiffs)
+			}
+			var got []bool
+			for _, c := range b.transfers() {
+				got = append(got, c.opts.Checksum)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("transfers compared checksums %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("transfers compared checksums %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if rr.repairing.Load() {
+				t.Error("repairing is still set")
+			}
+		})
+	}
+}
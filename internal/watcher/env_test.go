@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleEnvExpansion(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SYNC_ROOT", root)
+	t.Setenv("SYNC_BUCKET", "backups")
+	if err := os.Mkdir(filepath.Join(root, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	l := logging.L()
+	out, hooks := l.Out, l.Hooks
+	l.SetOutput(io.Discard)
+	hook := test.NewLocal(l)
+	t.Cleanup(func() {
+		l.SetOutput(out)
+		l.ReplaceHooks(hooks)
+	})
+
+	tests := []struct {
+		name        string
+		src, dst    string
+		wantSrc     string
+		wantDst     string
+		wantMissing []string
+	}{
+		{"set variables", "$SYNC_ROOT/data", "gs://${SYNC_BUCKET}/data", filepath.Join(root, "data"), "gs://backups/data", nil},
+		{"unset variables warn", "$SYNC_ROOT/$SYNC_UNSET_DIR", "gs://${SYNC_BUCKET}/${SYNC_UNSET_PREFIX}data",
+			root, "gs://backups/data", []string{"SYNC_UNSET_DIR", "SYNC_UNSET_PREFIX"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook.Reset()
+			rule := config.SyncRule{Enabled: true, Src: tt.src, Dst: tt.dst, DebounceWindow: time.Second}
+			rr, err := newRuleRunner(&config.Config{Sync: []config.SyncRule{rule}}, rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Clean(rr.srcRoot) != filepath.Clean(tt.wantSrc) || rr.rule.Dst != tt.wantDst {
+				t.Errorf("expanded to %s → %s, want %s → %s", rr.srcRoot, rr.rule.Dst, tt.wantSrc, tt.wantDst)
+			}
+			var warned []string
+			for _, e := range hook.AllEntries() {
+				if e.Level == logrus.WarnLevel && strings.Contains(e.Message, "is not set") {
+					warned = append(warned, strings.Fields(e.Message)[2])
+				}
+			}
+			if strings.Join(warned, ",") != strings.Join(tt.wantMissing, ",") {
+				t.Errorf("warned about %q, want %q", warned, tt.wantMissing)
+			}
+		})
+	}
+}
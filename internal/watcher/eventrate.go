@@ -0,0 +1,36 @@
+package watcher
+
+import "time"
+
+// eventRate detects file system event storms for max_event_rate.
+//
+// Events are counted per one-second bucket; the rate counts as high as soon as a bucket
+// exceeds the limit, and stays high until a whole bucket passes below it.
+type eventRate struct {
+	limit int // events per second; 0 disables the detection
+	start time.Time
+	count int
+	high  bool
+}
+
+// observe records one event at now.
+//
+// Returns:
+//   - high: Whether the event rate is currently above the limit.
+//   - changed: Whether this event switched between normal and high rate.
+func (r *eventRate) observe(now time.Time) (high, changed bool) {
+	if r.limit <= 0 {
+		return false, false
+	}
+	was := r.high
+	if now.Sub(r.start) >= time.Second {
+		// the finished bucket decides, unless it is stale (no events for a while)
+		r.high = r.count > r.limit && now.Sub(r.start) < 2*time.Second
+		r.start, r.count = now, 0
+	}
+	r.count++
+	if r.count > r.limit {
+		r.high = true
+	}
+	return r.high, r.high != was
+}
@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventRate(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+	// burst returns n events spread evenly over the second starting at offset
+	burst := func(offset time.Duration, n int) []time.Duration {
+		res := make([]time.Duration, n)
+		for i := range res {
+			res[i] = offset + time.Duration(i)*time.Second/time.Duration(n)
+		}
+		return res
+	}
+	type step struct {
+		events      []time.Duration
+		high        bool // after the last event of the step
+		changedLast bool // whether the last event switched the rate
+	}
+	tests := []struct {
+		name  string
+		limit int
+		steps []step
+	}{
+		{"disabled", 0, []step{{burst(0, 100), false, false}}},
+		{"below the limit", 10, []step{{burst(0, 10), false, false}, {burst(time.Second, 10), false, false}}},
+		{"goes high on the first event over the limit", 10, []step{
+			{burst(0, 10), false, false},
+			{[]time.Duration{990 * ms}, true, true},
+		}},
+		{"stays high while the storm lasts", 10, []step{
+			{burst(0, 11), true, true}, // the 11th event switched
+			{burst(time.Second, 50), true, false},
+		}},
+		{"back to normal after a quiet bucket", 10, []step{
+			{burst(0, 20), true, false},
+			{burst(time.Second, 5), true, false},    // decided by the stormy bucket
+			{burst(2*time.Second, 5), false, false}, // the first event of this bucket switched
+		}},
+		{"a long pause resets a stale bucket", 10, []step{
+			{burst(0, 20), true, false},
+			{[]time.Duration{time.Minute}, false, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := eventRate{limit: tt.limit}
+			for i, s := range tt.steps {
+				var high, changed bool
+				for _, at := range s.events {
+					high, changed = r.observe(t0.Add(at))
+				}
+				if high != s.high || changed != s.changedLast {
+					t.Errorf("step %d: observe = %v, %v, want %v, %v", i, high, changed, s.high, s.changedLast)
+				}
+			}
+		})
+	}
+}
+
+// TestEventStormSyncs keeps writing to a rule's source faster than max_event_rate allows
+// and checks that it syncs during the storm rather than waiting for it to calm down.
+func TestEventStormSyncs(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		wantSyncs bool
+	}{
+		{"capped rule syncs every window", 20, true},
+		{"uncapped rule waits for quiet", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{DebounceWindow: 100 * time.Millisecond, MaxEventRate: tt.limit}, b)
+			stop := make(chan struct{})
+			done := startRunner(context.Background(), rr, stop)
+			defer func() {
+				close(stop)
+				<-done
+			}()
+			eventually(t, "the initial sync", func() bool { return len(b.transfers()) == 1 })
+
+			// about 200 events/s for 800ms, never quiet for a debounce window
+			file := filepath.Join(rr.srcRoot, "busy.log")
+			for i := range 160 {
+				if err := os.WriteFile(file, []byte(strconv.Itoa(i)), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			synced := len(b.transfers()) - 1
+			if tt.wantSyncs && synced < 2 {
+				t.Errorf("%d syncs during the storm, want one every debounce window", synced)
+			}
+			if !tt.wantSyncs && synced != 0 {
+				t.Errorf("%d syncs during the storm, want none before it calms down", synced)
+			}
+			// and it resumes debouncing normally afterwards
+			eventually(t, "a sync after the storm", func() bool { return len(b.transfers()) > synced+1 })
+		})
+	}
+}
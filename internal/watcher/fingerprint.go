@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"encoding/binary"
+	"gcs_sync/internal/ignore"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+)
+
+// fingerprint computes a cheap digest of a source tree from the path, size, mode and
+// modification time of every entry that is not ignored. File contents are not read,
+// so the digest changes whenever something an rsync would look at changes.
+//
+// Parameters:
+//   - root: The tree to fingerprint.
+//   - ign: Ignore expressions; matching entries do not contribute.
+//
+// Returns:
+//   - uint64: The digest.
+//   - error: An error if the tree could not be walked.
+func fingerprint(root string, ign []ignore.Pattern) (uint64, error) {
+	h := fnv.New64a()
+	var buf [8]byte
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && ignore.Match(rel, ign) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(rel))
+		h.Write([]byte{0})
+		for _, v := range []uint64{uint64(info.Size()), uint64(info.Mode()), uint64(info.ModTime().UnixNano())} {
+			binary.LittleEndian.PutUint64(buf[:], v)
+			h.Write(buf[:])
+		}
+		return nil
+	})
+	return h.Sum64(), err
+}
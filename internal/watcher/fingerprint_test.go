@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/ignore"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "a")
+	write("dir/b.txt", "b")
+	write("tmp/old", "") // a new entry changes its directory's mtime
+	ign, err := ignore.Compile(root, []string{"tmp"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := fingerprint(root, ign)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func()
+		changed bool
+	}{
+		{"nothing", func() {}, false},
+		{"ignored file", func() { write("tmp/new", "x") }, false},
+		{"new file", func() { write("dir/c.txt", "c") }, true},
+		{"content size", func() { write("a.txt", "aa") }, true},
+		{"mtime", func() {
+			if err := os.Chtimes(filepath.Join(root, "a.txt"), time.Now(), time.Now().Add(-time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{"mode", func() {
+			if err := os.Chmod(filepath.Join(root, "a.txt"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{"removal", func() {
+			if err := os.Remove(filepath.Join(root, "dir", "c.txt")); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+	}
+	for _, tt := range tests {
+		tt.change()
+		fp, err := fingerprint(root, ign)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (fp != last) != tt.changed {
+			t.Errorf("%s: fingerprint changed = %v, want %v", tt.name, fp != last, tt.changed)
+		}
+		last = fp
+	}
+	if _, err := fingerprint(filepath.Join(root, "missing"), nil); err == nil {
+		t.Error("fingerprint of a missing tree succeeded")
+	}
+}
+
+// TestSkipUnchanged checks that an unchanged source is only synced again once something
+// changed or the previous sync failed.
+func TestSkipUnchanged(t *testing.T) {
+	fail := false
+	b := &fakeBackend{rsync: func(context.Context, fakeCall) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}}
+	rr := testRunner(t, config.SyncRule{SkipUnchanged: true}, b)
+	rr.ctx = context.Background()
+	steps := []struct {
+		name   string
+		before func()
+		want   int // transfers so far
+	}{
+		{"first sync", nil, 1},
+		{"unchanged", nil, 1},
+		{"changed", func() {
+			if err := os.WriteFile(filepath.Join(rr.srcRoot, "a.txt"), []byte("a"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, 2},
+		{"unchanged again", nil, 2},
+		{"failed sync", func() {
+			fail = true
+			if err := os.WriteFile(filepath.Join(rr.srcRoot, "b.txt"), []byte("b"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, 3},
+		{"retried after the failure", func() { fail = false }, 4},
+		{"unchanged after the retry", nil, 4},
+	}
+	for _, s := range steps {
+		if s.before != nil {
+			s.before()
+		}
+		rr.syncOnce("test")
+		if got := len(b.transfers()); got != s.want {
+			t.Fatalf("%s: %d transfers, want %d", s.name, got, s.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"gcs_sync/internal/gsutil"
+	"maps"
+)
+
+// remoteChanged compares the destination's object generations with the last successful poll.
+//
+// Before the first successful poll every listing counts as a change. It must only be
+// called from the runner's main loop, which owns lastGens.
+//
+// Returns:
+//   - map[string]int64: The current listing, to be stored once the pull succeeded.
+//   - bool: true if an object was added, removed or overwritten since the last poll.
+//   - error: An error if the destination could not be listed.
+func (rr *ruleRunner) remoteChanged() (map[string]int64, bool, error) {
+	gens, err := gsutil.Generations(rr.ctx, rr.rule.Dst, gsutil.Options{StateDir: rr.stateDir, Binary: rr.cfg.GsutilPath, GlobalFlags: rr.cfg.GsutilGlobalFlags}, rr.log)
+	if err != nil {
+		return nil, false, err
+	}
+	return gens, rr.lastGens == nil || !maps.Equal(gens, rr.lastGens), nil
+}
+
+// poll handles a tick of the remote polling ticker. With poll_generations enabled the
+// sync is skipped when no remote object changed since the previous poll.
+//
+// Returns:
+//   - bool: true if the poll found no remote change (and therefore did not pull).
+func (rr *ruleRunner) poll() bool {
+	var gens map[string]int64
+	if rr.rule.PollGenerations {
+		var changed bool
+		var err error
+		gens, changed, err = rr.remoteChanged()
+		switch {
+		case err != nil:
+			rr.log.WithError(err).Warn("failed to list remote generations, pulling anyway")
+		case !changed:
+			rr.log.Debug("remote unchanged since last poll, skipping pull")
+			return true
+		}
+	}
+	if rr.pullOnce("periodic pull") && gens != nil {
+		rr.lastGens = gens
+	}
+	return false
+}
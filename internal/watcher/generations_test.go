@@ -0,0 +1,125 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestPollGenerations polls a destination whose listing the test edits between ticks and
+// checks that only a changed listing, a listing failure or a failed pull lead to a pull.
+func TestPollGenerations(t *testing.T) {
+	listing := filepath.Join(t.TempDir(), "listing")
+	fakeGsutil(t, "cat "+listing+" 2>/dev/null || exit 2\n") // exit 1 would mean an empty prefix
+	list := func(content string) func() {
+		return func() {
+			if err := os.WriteFile(listing, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	fail := false
+	b := &fakeBackend{rsync: func(context.Context, fakeCall) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}}
+	rr := testRunner(t, config.SyncRule{Directions: []config.SyncDirection{config.Full},
+		RemotePollWindow: time.Minute, PollGenerations: true}, b)
+	rr.ctx = context.Background()
+
+	steps := []struct {
+		name     string
+		before   func()
+		wantSkip bool
+	}{
+		{"first poll", list("gs://bucket/data/a#1\n"), false},
+		{"unchanged", nil, true},
+		{"overwritten", list("gs://bucket/data/a#2\n"), false},
+		{"added", list("gs://bucket/data/a#2\ngs://bucket/data/b#1\n"), false},
+		{"removed", list("gs://bucket/data/b#1\n"), false},
+		{"unchanged again", nil, true},
+		{"listing fails", func() { os.Remove(listing) }, false},
+		{"pull fails", func() { list("gs://bucket/data/c#1\n")(); fail = true }, false},
+		{"retried after the failed pull", func() { fail = false }, false},
+		{"unchanged after the retry", nil, true},
+	}
+	for _, s := range steps {
+		if s.before != nil {
+			s.before()
+		}
+		before := len(b.transfers())
+		if skipped := rr.poll(); skipped != s.wantSkip {
+			t.Fatalf("%s: poll skipped = %v, want %v", s.name, skipped, s.wantSkip)
+		}
+		if pulled := len(b.transfers()) > before; pulled == s.wantSkip {
+			t.Fatalf("%s: pulled = %v, want %v", s.name, pulled, !s.wantSkip)
+		}
+	}
+}
+
+// TestEmptyPollBackoff runs a polling rule against an unchanged remote and checks that
+// empty polls stretch the interval by empty_poll_backoff until a poll finds a change.
+func TestEmptyPollBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff time.Duration
+		min     int // listings within the first 250ms
+		max     int
+	}{
+		{"no backoff", 0, 4, 100},
+		{"backoff", 300 * time.Millisecond, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listing := filepath.Join(t.TempDir(), "listing")
+			if err := os.WriteFile(listing, []byte("gs://bucket/data/a#1\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			calls := fakeGsutil(t, "cat "+listing+"\n")
+			polls := func() int {
+				n := 0
+				for _, c := range calls() {
+					if slices.Contains(c, "ls") {
+						n++
+					}
+				}
+				return n
+			}
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{Directions: []config.SyncDirection{config.Full},
+				RemotePollWindow: 30 * time.Millisecond, PollGenerations: true, EmptyPollBackoff: tt.backoff}, b)
+			stop := make(chan struct{})
+			done := startRunner(context.Background(), rr, stop)
+			defer func() {
+				close(stop)
+				<-done
+			}()
+			time.Sleep(250 * time.Millisecond)
+			if n := polls(); n < tt.min || n > tt.max {
+				t.Fatalf("%d polls in 250ms, want %d to %d", n, tt.min, tt.max)
+			}
+			if tt.backoff == 0 {
+				return
+			}
+
+			// the stretched poll finds the change and restores the normal interval
+			pulls := len(b.transfers())
+			if err := os.WriteFile(listing, []byte("gs://bucket/data/a#2\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			eventually(t, "a pull of the changed remote", func() bool { return len(b.transfers()) > pulls })
+			n := polls()
+			time.Sleep(150 * time.Millisecond) // well below the stretched 330ms
+			if polls() == n {
+				t.Error("no poll at the normal interval after a change")
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Ready reports whether every running rule has finished its initial sync (successfully
+// or not), and lists the rules that have not.
+func (m *Manager) Ready() (ready bool, pending []string) {
+	for _, rr := range m.snapshot() {
+		if !rr.initialDone.Load() {
+			pending = append(pending, rr.rule.ID())
+		}
+	}
+	return m.isStarted() && len(pending) == 0, pending
+}
+
+// isStarted reports whether Start succeeded.
+func (m *Manager) isStarted() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.started
+}
+
+// HealthHandler serves a liveness probe: 200 once the Manager has started, 503 before.
+func (m *Manager) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !m.isStarted() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadyHandler serves a readiness probe: 200 once every rule finished its initial sync,
+// 503 with the pending rule IDs as JSON before.
+func (m *Manager) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		ready, pending := m.Ready()
+		if ready {
+			_, _ = w.Write([]byte("ok\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string][]string{"pending": pending})
+	})
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus/hooks/test"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestReadyProbe runs two rules whose initial syncs are held back, and checks that /readyz
+// only turns ready once both finished, whether they succeeded or not.
+func TestReadyProbe(t *testing.T) {
+	release := map[string]chan struct{}{"slow": make(chan struct{}), "failing": make(chan struct{})}
+	var runners []*ruleRunner
+	for _, name := range []string{"slow", "failing"} {
+		b := &fakeBackend{rsync: func(ctx context.Context, c fakeCall) error {
+			<-release[name]
+			if name == "failing" {
+				return errors.New("boom")
+			}
+			return nil
+		}}
+		runners = append(runners, testRunner(t, config.SyncRule{Name: name}, b))
+	}
+	logger, _ := test.NewNullLogger()
+	m := NewManager(runners[0].cfg, logger, nil)
+	m.runners = runners
+
+	get := func(h http.Handler) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		b, _ := io.ReadAll(rec.Body)
+		return rec.Code, strings.TrimSpace(string(b))
+	}
+	check := func(what string, h http.Handler, wantCode int, wantBody string) {
+		t.Helper()
+		if code, body := get(h); code != wantCode || body != wantBody {
+			t.Errorf("%s: %d %s, want %d %s", what, code, body, wantCode, wantBody)
+		}
+	}
+
+	check("healthz before Start", m.HealthHandler(), http.StatusServiceUnavailable, "starting")
+	m.started = true
+	check("healthz after Start", m.HealthHandler(), http.StatusOK, "ok")
+
+	stop := make(chan struct{})
+	var done []<-chan error
+	for _, rr := range runners {
+		done = append(done, startRunner(context.Background(), rr, stop))
+	}
+	defer func() {
+		close(stop)
+		for _, d := range done {
+			<-d
+		}
+	}()
+
+	check("readyz during both initial syncs", m.ReadyHandler(), http.StatusServiceUnavailable, `{"pending":["slow","failing"]}`)
+	close(release["failing"])
+	eventually(t, "the failing initial sync", runners[1].initialDone.Load)
+	check("readyz after a failed initial sync", m.ReadyHandler(), http.StatusServiceUnavailable, `{"pending":["slow"]}`)
+	close(release["slow"])
+	eventually(t, "the slow initial sync", runners[0].initialDone.Load)
+	check("readyz after both", m.ReadyHandler(), http.StatusOK, "ok")
+}
@@ -0,0 +1,26 @@
+package watcher
+
+import "time"
+
+// Heartbeat reports that the daemon and each of its rules are alive, for liveness
+// monitoring without the status API. It logs one "heartbeat" line with the number of
+// running rules and one "rule heartbeat" line per rule telling how long ago its last sync
+// finished, and passes the same durations to the metrics recorder.
+//
+// Before a rule's first sync the time since it started is reported, with last_sync=never.
+func (m *Manager) Heartbeat() {
+	runners := m.snapshot()
+	m.log.WithField("rules", len(runners)).Info("heartbeat")
+	for _, rr := range runners {
+		since, synced := rr.stats.sinceLastSync()
+		entry := rr.log.WithField("since_last_sync", since.Round(time.Second).String())
+		if !synced {
+			entry = entry.WithField("last_sync", "never")
+		}
+		if err := rr.stats.lastError(); err != nil {
+			entry = entry.WithField("last_error", err.Error())
+		}
+		entry.Info("rule heartbeat")
+		rr.rec.Heartbeat(rr.rule.ID(), since)
+	}
+}
@@ -1,49 +1,291 @@
 package watcher
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"gcs_sync/internal/audit"
 	"gcs_sync/internal/config"
+	"gcs_sync/internal/filter"
 	"gcs_sync/internal/gsutil"
+	"gcs_sync/internal/hook"
 	"gcs_sync/internal/ignore"
 	"gcs_sync/internal/logging"
+	"gcs_sync/internal/metrics"
+	"gcs_sync/internal/schedule"
 	"gcs_sync/internal/util"
 	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
+// errRootDelete blocks deleting syncs when running as root without allow_root_delete.
+var errRootDelete = errors.New("refusing to sync with deletion enabled as root; set allow_root_delete: true to permit it")
+
+// geteuid returns the effective user id; -1 on platforms without uids (Windows).
+var geteuid = os.Geteuid
+
+// watchLimitPollInterval is how often a pushing rule syncs when the inotify watch limit
+// left part of its tree unwatched. Such trees are large, so a full sync is not cheap.
+const watchLimitPollInterval = 5 * time.Minute
+
+// windowCheckInterval is how often a rule with deferred syncs checks whether it may sync again.
+const windowCheckInterval = time.Minute
+
 type ruleRunner struct {
-	rule    config.SyncRule
-	srcRoot string
-	ign     []*regexp.Regexp
-	log     *logrus.Entry
+	cfg             *config.Config
+	rule            config.SyncRule
+	srcRoot         string
+	subpaths        []string // unix-style, relative to srcRoot; empty means the whole tree
+	stateDir        string
+	quarantineDir   string      // expanded quarantine_dir for case collisions
+	fileMode        fs.FileMode // permissions for pulled files, 0 to keep gsutil's
+	levels          map[int]logrus.Level
+	stderr          *gsutil.Classifier
+	backend         gsutil.Backend // runs the rule's rsyncs (backend)
+	bandwidth       int64          // bytes per second (bandwidth_limit); 0 means unlimited
+	maintenanceFile string         // expanded maintenance_file; syncs pause while it exists
+	maintenance     atomic.Bool    // whether the maintenance file was present at the last check
+	slots           syncSlots      // shared across rules (max_concurrent_syncs); nil means unlimited
+	rootLost        atomic.Bool    // the source root disappeared at runtime; see rootMissing
+	rootGone        chan struct{}  // signals the run loop to start re-checking a lost source root
+	initialDone     atomic.Bool    // the initial sync finished, for the readiness probe
+	ign             []ignore.Pattern
+	filters         []filter.Func
+	broken          filter.BrokenLinkFunc // handles broken symlinks (broken_symlinks); nil leaves them to gsutil -e
+	window          *schedule.Window
+	now             func() time.Time // clock, replaceable for tests
+	deferred        atomic.Bool      // a sync was skipped outside the active window
+	stats           runnerStats
+	rec             metrics.Recorder
+	mapper          DstMapper // optional per-file destination override
+	callbacks       Callbacks
+	openFiles       filter.OpenDetector // skips files open for writing (skip_open_files); nil otherwise
+	gate            NetworkGate         // defers syncs on metered connections (pause_when_metered); nil otherwise
+	lastFP          atomic.Uint64       // source fingerprint of the last successful sync (skip_unchanged)
+	repairing       atomic.Bool         // a drift repair is running; syncs compare checksums
+	watches         *watchSet
+	recreates       *recreates         // removals that may still be undone (recreate_grace)
+	deletes         *deletes           // removals awaiting a targeted delete (immediate_deletes); nil otherwise
+	guard           syncGuard          // serialises syncs
+	logTmpl         *template.Template // renders the per-sync log line (log_template); nil otherwise
+	absent          *absences          // delays local deletions (remote_delete_polls); nil otherwise
+	audit           *audit.Log         // nil when audit_log is not set
+	ctx             context.Context    // cancelled to kill in-flight transfers on shutdown
+	shutdown        context.Context    // bounds the work done after stop, e.g. the reconcile report; ends at Stop's deadline
+	lastGens        map[string]int64   // remote object generations seen by the last poll
+	confirm         chan struct{}      // releases a paused initial sync (initial_confirm); nil otherwise
+	paused          atomic.Bool        // the initial sync waits on confirm; cleared by the first Confirm
+	quit            chan struct{}      // closed to stop the watcher (Manager.launch)
+	kill            context.CancelFunc // kills in-flight transfers regardless of flush_on_shutdown and drain_on_shutdown
+	done            chan struct{}      // closed once the watcher exited
+	log             *logrus.Entry
+	logFile         *logging.RuleFile // the rule's own log file (log_file); nil when it has none
 }
 
 // newRuleRunner creates and initializes a new ruleRunner instance.
 //
-// It sets up a ruleRunner with the provided SyncRule, expanding the source path,
-// compiling ignore patterns, and initializing a logger.
+// It sets up a ruleRunner with the provided SyncRule, expanding environment variables in
+// src and dst and the tilde in src, compiling ignore patterns and source filters, and initializing a logger.
 //
 // Parameters:
+//   - cfg: The global configuration the rule belongs to.
 //   - rule: A config.SyncRule that defines the synchronization configuration.
 //
 // Returns:
 //   - *ruleRunner: A pointer to the newly created ruleRunner instance.
-//   - error: An error if there was a problem compiling the ignore patterns, or nil if successful.
-func newRuleRunner(rule config.SyncRule) (*ruleRunner, error) {
-	src := util.Expand(rule.Src)
-	ign, err := ignore.Compile(src, rule.Ignore)
+//   - error: An error if src is a file or there was a problem compiling the ignore patterns or filters,
+//     or nil if successful.
+func newRuleRunner(cfg *config.Config, rule config.SyncRule) (*ruleRunner, error) {
+	src, missing := util.ExpandEnv(rule.Src)
+	src = util.Expand(src)
+	var missingDst []string
+	rule.Dst, missingDst = util.ExpandEnv(rule.Dst)
+	for _, name := range append(missing, missingDst...) {
+		logging.L().WithField("rule", rule.ID()).Warnf("environment variable %s is not set, expanding it to an empty string", name)
+	}
+	// rsync and the recursive watcher both need a directory; a file would silently watch nothing
+	fi, err := os.Stat(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("rule %s: src %q does not exist", rule.ID(), src)
+	}
+	if err == nil && !fi.IsDir() {
+		return nil, fmt.Errorf("rule %s: src %q is a file, not a directory; point src at its parent and use include to select it", rule.ID(), src)
+	}
+	ign, err := ignore.CompileFile(src, util.Expand(rule.IgnoreFile), rule.IgnorePatterns(), rule.IgnoreCaseInsensitive, cfg.MaxIgnorePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", rule.ID(), err)
+	}
+	var filters []filter.Func
+	if len(rule.Include) > 0 {
+		inc, err := ignore.Compile(src, rule.Include, false)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter.Include(inc, rule.StrictAllowlist))
+	}
+	if rule.ContentKind != "" {
+		fn, err := filter.ContentKind(rule.ContentKind)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fn)
+	}
+	if len(rule.ExcludeTypes) > 0 {
+		fn, err := filter.MagicBytes(rule.ExcludeTypes)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: exclude_types: %w", rule.ID(), err)
+		}
+		filters = append(filters, fn)
+	}
+	if rule.ExcludeOlderThan > 0 {
+		filters = append(filters, filter.OlderThan(rule.ExcludeOlderThan, time.Now))
+	}
+	if rule.SkipEmptyFiles {
+		filters = append(filters, filter.Empty())
+	}
+	if rule.MaxFileSize != "" {
+		limit, err := util.ParseSize(rule.MaxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: max_file_size: %w", rule.ID(), err)
+		}
+		filters = append(filters, filter.LargerThan(limit))
+	}
+	if rule.Owner != "" {
+		fn, err := filter.Owner(rule.Owner)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fn)
+	}
+	var window *schedule.Window
+	if rule.ActiveWindow != nil {
+		if window, err = schedule.Parse(*rule.ActiveWindow); err != nil {
+			return nil, err
+		}
+	}
+	fileMode, err := parseFileMode(rule.PullFileMode)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := cfg.ExitLevels()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := gsutil.NewClassifier(cfg.StderrErrors, cfg.StderrNoise)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := gsutil.NewBackend(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+	var bandwidth int64
+	if rule.BandwidthLimit != "" {
+		if bandwidth, err = util.ParseSize(rule.BandwidthLimit); err != nil {
+			return nil, fmt.Errorf("rule %s: bandwidth_limit: %w", rule.ID(), err)
+		}
+		if _, err := exec.LookPath(gsutil.Trickle); err != nil {
+			return nil, fmt.Errorf("rule %s: bandwidth_limit needs %s on PATH: %w", rule.ID(), gsutil.Trickle, err)
+		}
+	}
+	// the file is only opened once the runner is complete, see Manager.newRunner
+	rot := logging.Rotation{MaxAge: rule.LogFileMaxAge}
+	if rule.LogFileMaxSize != "" {
+		if rot.MaxSize, err = util.ParseSize(rule.LogFileMaxSize); err != nil {
+			return nil, fmt.Errorf("rule %s: log_file_max_size: %w", rule.ID(), err)
+		}
+	}
+	if rule.LogFileMaxTotal != "" {
+		if rot.MaxTotal, err = util.ParseSize(rule.LogFileMaxTotal); err != nil {
+			return nil, fmt.Errorf("rule %s: log_file_max_total: %w", rule.ID(), err)
+		}
+	}
+	logFile := logging.NewRuleFile(util.Expand(rule.LogFile), rot)
+	log := logFile.Entry(logging.L().WithField("rule", src))
+	// without follow_symlinks gsutil -e already skips broken links silently, so only the
+	// other policies need a walk
+	var broken filter.BrokenLinkFunc
+	if rule.FollowSymlinks || (rule.BrokenSymlinks != "" && rule.BrokenSymlinks != filter.BrokenSkip) {
+		if broken, err = filter.BrokenLinks(rule.BrokenSymlinks, log); err != nil {
+			return nil, fmt.Errorf("rule %s: broken_symlinks: %w", rule.ID(), err)
+		}
+	}
+	var subpaths []string
+	for _, sp := range rule.Subpaths {
+		subpaths = append(subpaths, path.Clean(filepath.ToSlash(sp)))
+	}
+	stateDir := rule.StateDir
+	if stateDir != "" {
+		stateDir = util.Expand(stateDir)
+	}
+	if rule.Mirror {
+		if err := expandMirror(&rule); err != nil {
+			return nil, err
+		}
+	}
+	if rule.DriftCheck > 0 && rule.AppendOnly {
+		return nil, fmt.Errorf("rule %q: drift_check would delete objects that append_only keeps", rule.ID())
+	}
+	if rule.DeleteOrphans && containsDir(rule.Directions, config.Full) {
+		return nil, fmt.Errorf("rule %q: delete_orphans would delete local files of a full rule before they are pushed", rule.ID())
+	}
+	if err := parseCollisionPolicy(rule.ID(), rule.CaseCollisions, rule.QuarantineDir); err != nil {
+		return nil, err
+	}
+	logTmpl, err := parseLogTemplate(rule)
 	if err != nil {
 		return nil, err
 	}
+	var absent *absences
+	if rule.RemoteDeletePolls > 1 {
+		absent = &absences{polls: rule.RemoteDeletePolls}
+	}
+	var confirm chan struct{}
+	if rule.InitialConfirm {
+		confirm = make(chan struct{}, 1)
+	}
 	return &ruleRunner{
-		rule:    rule,
-		srcRoot: src,
-		ign:     ign,
-		log:     logging.L().WithField("rule", src),
+		cfg:             cfg,
+		rule:            rule,
+		srcRoot:         src,
+		subpaths:        subpaths,
+		stateDir:        stateDir,
+		quarantineDir:   util.Expand(rule.QuarantineDir),
+		fileMode:        fileMode,
+		levels:          levels,
+		stderr:          stderr,
+		backend:         backend,
+		bandwidth:       bandwidth,
+		maintenanceFile: util.Expand(cfg.MaintenanceFile),
+		ign:             ign,
+		filters:         filters,
+		broken:          broken,
+		window:          window,
+		now:             time.Now,
+		ctx:             context.Background(),
+		shutdown:        context.Background(),
+		stats:           runnerStats{started: time.Now()},
+		rec:             metrics.Nop{},
+		watches:         newWatchSet(),
+		recreates:       &recreates{grace: rule.RecreateGrace},
+		deletes:         newDeletes(rule),
+		rootGone:        make(chan struct{}, 1),
+		absent:          absent,
+		logTmpl:         logTmpl,
+		confirm:         confirm,
+		log:             log,
+		logFile:         logFile,
 	}, nil
 }
 
@@ -54,64 +296,250 @@ func newRuleRunner(rule config.SyncRule) (*ruleRunner, error) {
 // It uses a debounce mechanism to avoid excessive synchronizations during rapid file changes.
 //
 // Parameters:
+//   - ctx: Kills in-flight gsutil transfers when cancelled, bounding a shutdown flush.
 //   - stop: A receive-only channel of struct{} used to signal when the watcher should stop.
-//     When a value is received on this channel, the function will terminate its execution.
+//     When a value is received on this channel, the function will terminate its execution,
+//     after a final sync of pending changes if flush_on_shutdown or drain_on_shutdown is set.
 //
 // Returns:
 //   - error: An error if there was a problem setting up or running the watcher,
 //     or nil if the watcher was stopped normally via the stop channel.
-func (rr *ruleRunner) run(stop <-chan struct{}) error {
+func (rr *ruleRunner) run(ctx context.Context, stop <-chan struct{}) error {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 	defer w.Close()
+	defer rr.deletes.stop()
+	rr.watches.attach(w)
+
+	// in-flight transfers are killed on stop, unless the rule flushes or drains on
+	// shutdown; then they only end with ctx (the shutdown deadline)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rr.ctx = ctx
+	if !rr.rule.FlushOnShutdown && !rr.rule.DrainOnShutdown {
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
 
 	// watch existing tree
-	if err := addRecursive(w, rr.srcRoot); err != nil {
+	if err := addRecursive(rr.watches, rr.srcRoot, rr.rule.FollowSymlinks, rr.ignoredDir); err != nil {
 		return err
 	}
 
-	// initial sync
+	// initial sync, optionally previewed and confirmed first
+	if rr.rule.InitialPreview || rr.rule.InitialConfirm {
+		rr.preview(rr.log.WithField("reason", "initial preview"))
+	}
+	if rr.confirm != nil {
+		rr.paused.Store(true)
+		rr.log.Warnf("initial sync paused: confirm with POST /status/confirm?rule=%s", rr.rule.ID())
+		select {
+		case <-rr.confirm:
+			rr.log.Info("initial sync confirmed")
+		case <-stop:
+			rr.log.Info("stopping watcher before the initial sync was confirmed")
+			return nil
+		}
+	}
 	rr.syncOnce("initial")
+	rr.initialDone.Store(true)
 
 	// ───────────────────── debounce state ────────────────────────
 	var mu sync.Mutex
 	var timer *time.Timer
-	resetDebounce := func(reason string) {
+	armed := false    // a debounced sync is scheduled
+	stopping := false // no debounced sync may start any more
+	var inflight sync.WaitGroup
+	// extend=false only schedules a sync if none is pending, so that a constant stream of
+	// events (max_event_rate exceeded) still syncs every debounce window
+	resetDebounce := func(reason string, extend bool) {
 		mu.Lock()
 		defer mu.Unlock()
-		if timer == nil {
-			timer = time.AfterFunc(rr.rule.DebounceWindow, func() { rr.syncOnce("debounce") })
+		switch {
+		case timer == nil:
+			timer = time.AfterFunc(rr.rule.DebounceWindow, func() {
+				mu.Lock()
+				// a file removed moments ago may be recreated (recreate_grace)
+				if d := rr.recreates.wait(rr.now()); d > 0 {
+					rr.log.Debugf("delaying sync by %s for removed files to be recreated", d)
+					timer.Reset(d)
+					mu.Unlock()
+					return
+				}
+				if stopping {
+					mu.Unlock()
+					return
+				}
+				armed = false
+				// the stop case waits for it, so that shutdown never cuts it short
+				inflight.Add(1)
+				mu.Unlock()
+				defer inflight.Done()
+				rr.syncOnce("debounce")
+			})
 			rr.log.Debugf("debounce timer started (%s) reason=%s", rr.rule.DebounceWindow, reason)
-		} else {
+		case extend || !armed:
 			timer.Reset(rr.rule.DebounceWindow)
 		}
+		armed = true
 	}
+	rate := eventRate{limit: rr.rule.MaxEventRate}
 
 	// ───────────────────── polling ticker ────────────────────────
 	var ticker *time.Ticker
-	if containsDir(rr.rule.Directions, config.RemoteToLocal) || containsDir(rr.rule.Directions, config.Full) {
+	backedOff := false
+	if rr.pulls() {
 		ticker = time.NewTicker(rr.rule.RemotePollWindow)
 		defer ticker.Stop()
 		rr.log.Infof("remote polling enabled (%s)", rr.rule.RemotePollWindow)
+		// the ticker only fires after one full interval; optionally poll right away
+		if rr.rule.RemotePollImmediate {
+			rr.pullOnce("immediate pull")
+		}
+	}
+
+	// ──────────────────────── drift check ────────────────────────
+	var driftTicker *time.Ticker
+	if rr.rule.DriftCheck > 0 && rr.pushes() {
+		driftTicker = time.NewTicker(rr.rule.DriftCheck)
+		defer driftTicker.Stop()
+		rr.log.Infof("drift check enabled (%s)", rr.rule.DriftCheck)
+	}
+
+	// ───────────────── active window / network gate ──────────────
+	var windowTicker *time.Ticker
+	if rr.window != nil || rr.gate != nil || rr.maintenanceFile != "" {
+		windowTicker = time.NewTicker(windowCheckInterval)
+		defer windowTicker.Stop()
+	}
+
+	// ───────────────── inotify watch limit fallback ──────────────
+	var limitTicker *time.Ticker
+	limitWarned := false
+	checkWatchLimit := func() {
+		watched, skipped := rr.watches.counts()
+		if skipped == 0 || limitWarned {
+			return
+		}
+		limitWarned = true
+		rr.log.Warnf("inotify watch limit reached: watching %d directories, %d skipped; raise fs.inotify.max_user_watches, e.g. sysctl -w fs.inotify.max_user_watches=524288", watched, skipped)
+		if rr.pushes() {
+			rr.log.Warnf("changes in unwatched directories go unnoticed, syncing every %s as a fallback", watchLimitPollInterval)
+			limitTicker = time.NewTicker(watchLimitPollInterval)
+		}
 	}
+	checkWatchLimit()
+	defer func() {
+		if limitTicker != nil {
+			limitTicker.Stop()
+		}
+	}()
+
+	// ───────────────── source root recovery ──────────────────
+	var rootTimer *time.Timer
+	rootDelay := rootRetryMin
+	defer func() {
+		if rootTimer != nil {
+			rootTimer.Stop()
+		}
+	}()
 
 	// ───────────────────────── main loop ─────────────────────────
 	for {
 		select {
 		case ev := <-w.Events:
-			rr.handleEvent(ev, w)
-			resetDebounce(ev.Op.String())
+			if ev.Name == rr.srcRoot && ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				rr.rootMissing()
+			}
+			relevant := rr.handleEvent(ev)
+			checkWatchLimit() // new directories may have hit the limit
+			// local changes only matter to rules that push them
+			if relevant && rr.pushes() {
+				high, changed := rate.observe(time.Now())
+				if changed && high {
+					rr.log.Warnf("more than %d events/s, syncing every %s instead of waiting for quiet", rate.limit, rr.rule.DebounceWindow)
+				} else if changed {
+					rr.log.Info("event rate back to normal")
+				}
+				resetDebounce(ev.Op.String(), !high)
+			}
 
 		case err := <-w.Errors:
 			rr.log.WithError(err).Warn("watcher error")
+			rr.callbacks.error(rr.rule.ID(), err)
 
 		case <-tickerTick(ticker):
-			rr.syncOnce("periodic pull")
+			// an empty poll stretches the next interval by empty_poll_backoff
+			if empty := rr.poll(); empty && rr.rule.EmptyPollBackoff > 0 {
+				ticker.Reset(rr.rule.RemotePollWindow + rr.rule.EmptyPollBackoff)
+				backedOff = true
+			} else if !empty && backedOff {
+				ticker.Reset(rr.rule.RemotePollWindow)
+				backedOff = false
+			}
+
+		case <-tickerTick(driftTicker):
+			rr.checkDrift()
+
+		case <-rr.rootGone:
+			if rootTimer == nil {
+				rootDelay = rootRetryMin
+				rootTimer = time.NewTimer(rootDelay)
+			}
+
+		case <-timerC(rootTimer):
+			if !rr.rootBack() {
+				rootDelay = min(rootDelay*2, rootRetryMax)
+				rr.log.Warnf("source root %s still missing, checking again in %s", rr.srcRoot, rootDelay)
+				rootTimer.Reset(rootDelay)
+				break
+			}
+			rootTimer = nil
+			if err := addRecursive(rr.watches, rr.srcRoot, rr.rule.FollowSymlinks, rr.ignoredDir); err != nil {
+				rr.log.WithError(err).Warn("failed to watch the restored source root")
+			}
+			checkWatchLimit()
+			rr.syncOnce("source root restored")
+
+		case <-tickerTick(limitTicker):
+			rr.syncOnce("watch limit poll")
+
+		case <-tickerTick(windowTicker):
+			if rr.deferred.Load() && rr.blocked(rr.log) == "" {
+				rr.syncOnce("deferred sync")
+			}
 
 		case <-stop:
 			rr.log.Info("stopping watcher")
+			mu.Lock()
+			stopping = true
+			if timer != nil {
+				timer.Stop()
+			}
+			// still armed if the timer fired but its sync had not started yet
+			pending := armed
+			mu.Unlock()
+			inflight.Wait()
+			switch {
+			case rr.rule.DrainOnShutdown && (pending || rr.deferred.Load()):
+				rr.drain()
+			case pending && rr.rule.FlushOnShutdown:
+				rr.syncOnce("shutdown flush")
+			case pending:
+				rr.log.Warn("discarding changes still waiting for the debounce window")
+			}
+			if rr.rule.ReconcileReport != "" {
+				if _, err := rr.writeReport(rr.shutdown); err != nil {
+					rr.log.WithError(err).Warn("failed to write reconcile report")
+				}
+			}
 			return nil
 		}
 	}
@@ -124,45 +552,461 @@ func (rr *ruleRunner) run(stop <-chan struct{}) error {
 // remote-to-local synchronizations, using the gsutil.RSync function for the actual
 // file transfer.
 //
+// Outside the rule's active window, or on a metered connection with pause_when_metered,
+// the sync is skipped and remembered, so that it runs once syncing is allowed again.
+//
 // Parameters:
 //   - reason: A string describing the reason for this synchronization (e.g., "initial", "debounce").
 //     This is used for logging purposes.
 //
-// The function does not return any value, but it logs the synchronization activities
-// and any errors that occur during the process.
-func (rr *ruleRunner) syncOnce(reason string) {
+// Returns:
+//   - bool: true if the rule is in sync afterwards, false if the sync failed or was deferred.
+//     Errors are logged rather than returned.
+func (rr *ruleRunner) syncOnce(reason string) bool {
+	return rr.runSync(reason, false)
+}
+
+// pullOnce is syncOnce restricted to the remote → local half of the rule. The remote
+// polling ticker uses it, since a poll has no local changes to push.
+//
+// Parameters:
+//   - reason: Why the pull was triggered, for logging.
+//
+// Returns:
+//   - bool: true if the pull succeeded, false if it failed or was deferred.
+func (rr *ruleRunner) pullOnce(reason string) bool {
+	return rr.runSync(reason, true)
+}
+
+// runSync implements syncOnce and pullOnce. A request while another sync of the rule is
+// running is coalesced into one more sync after it (see syncGuard).
+func (rr *ruleRunner) runSync(reason string, pullOnly bool) bool {
+	if !rr.guard.begin(pullOnly) {
+		rr.log.WithField("reason", reason).Info("sync already running, queued one more after it")
+		return false
+	}
+	return rr.syncHeld(reason, pullOnly)
+}
+
+// syncHeld syncs while holding the rule's syncGuard, runs the syncs coalesced meanwhile
+// and releases the guard.
+func (rr *ruleRunner) syncHeld(reason string, pullOnly bool) bool {
+	for {
+		ok := rr.syncGuarded(reason, pullOnly)
+		again, nextPullOnly := rr.guard.next()
+		if !again {
+			return ok
+		}
+		reason, pullOnly = "coalesced", nextPullOnly
+	}
+}
+
+// syncGuarded runs one sync while holding the rule's syncGuard.
+func (rr *ruleRunner) syncGuarded(reason string, pullOnly bool) bool {
 	l := rr.log.WithField("reason", reason)
-	gsutil.RSync(rr.srcRoot, rr.rule.Dst, true, rr.ign, l)
+	if blocked := rr.blocked(l); blocked != "" {
+		l.Debugf("%s, deferring sync", blocked)
+		rr.deferred.Store(true)
+		return false
+	}
+	rr.deferred.Store(false)
+
+	// a push-only rule whose source did not change has nothing to transfer
+	var fp uint64
+	if rr.rule.SkipUnchanged && !rr.pulls() {
+		var err error
+		if fp, err = fingerprint(rr.srcRoot, rr.ign); err != nil {
+			l.WithError(err).Warn("failed to fingerprint source")
+		} else if fp != 0 && fp == rr.lastFP.Load() {
+			l.Debug("source unchanged since last sync, skipping")
+			return true
+		}
+	}
+
+	release, ok := rr.acquireSlot()
+	if !ok {
+		return false
+	}
+	rr.rec.SyncStarted(rr.rule.ID())
+	rr.callbacks.syncStart(rr.rule.ID(), reason)
+	start := time.Now()
+	res, err := rr.doSync(reason, pullOnly, l)
+	d := time.Since(start)
+	release()
+	rr.rec.SyncFinished(rr.rule.ID(), d, res.Bytes, err)
+	syncs, failures := rr.stats.record(d, err)
+	data := SyncLogData{
+		Name:      rr.rule.ID(),
+		Reason:    reason,
+		Direction: rr.direction(pullOnly),
+		Duration:  d,
+		DryRun:    rr.cfg.RuleDryRun(rr.rule),
+		Syncs:     syncs,
+		Failures:  failures,
+		Copied:    res.Copied,
+		Removed:   res.Removed,
+		Skipped:   res.Skipped,
+		Bytes:     res.Bytes,
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	rr.logResult(l, data)
+	rr.callbacks.syncEnd(SyncResult{
+		Rule:     rr.rule.ID(),
+		Reason:   reason,
+		Start:    start,
+		Duration: d,
+		DryRun:   rr.cfg.RuleDryRun(rr.rule),
+		Err:      err,
+		Transfer: res,
+	})
+	if err == nil {
+		rr.lastFP.Store(fp)
+	}
+	return err == nil
+}
+
+// blocked returns why syncing is not allowed right now (source root missing, maintenance
+// file present, outside the active window, or on a metered connection), or "" when it is.
+func (rr *ruleRunner) blocked(l *logrus.Entry) string {
+	if rr.rootMissing() {
+		return "source root missing"
+	}
+	if rr.inMaintenance() {
+		return "maintenance file present"
+	}
+	if rr.window != nil && !rr.window.Active(rr.now()) {
+		return "outside active window"
+	}
+	if rr.metered(l) {
+		return "on a metered connection"
+	}
+	return ""
+}
+
+// doSync runs the transfer for syncOnce once it has been decided that a sync should happen.
+// A configured pre_sync hook runs first; if it fails, nothing is transferred.
+//
+// Parameters:
+//   - reason: Why the sync was triggered, passed on to the hook.
+//   - pullOnly: Skip the push half of every scope.
+//   - l: The logger entry carrying the sync reason.
+//
+// Returns:
+//   - gsutil.SyncResult: What the rsync transfers reported, summed over every scope; per-file
+//     transfers (destination mappers, append_only) are not counted.
+//   - error: An error if the hook failed, the exclusion list could not be built or gsutil failed.
+func (rr *ruleRunner) doSync(reason string, pullOnly bool, l *logrus.Entry) (gsutil.SyncResult, error) {
+	var total gsutil.SyncResult
+	if rr.stateDir != "" {
+		if n, err := gsutil.PruneTrackers(rr.stateDir, rr.rule.TrackerMaxAge, l); err != nil {
+			l.WithError(err).Warn("failed to prepare gsutil state dir")
+		} else if n > 0 {
+			l.Infof("pruned %d stale resumable tracker(s)", n)
+		}
+	}
+	opts := rr.options()
+	if err := rr.checkRoot(opts); err != nil {
+		l.Error(err)
+		return total, err
+	}
+	if rr.rule.PreSync != "" {
+		if err := hook.Run("pre_sync", rr.rule.PreSync, rr.hookEnv(reason, opts.DryRun), l); err != nil {
+			l.WithError(err).Error("pre_sync hook failed, skipping sync")
+			return total, err
+		}
+	}
+	if rr.mapper != nil {
+		ign, err := rr.excludes(rr.srcRoot)
+		if err != nil {
+			l.WithError(err).Error("failed to build exclusion list, skipping sync")
+			return total, err
+		}
+		return total, rr.mappedSync(ign, opts, l)
+	}
+
+	var errs []error
+	for _, sc := range rr.scopes() {
+		for _, h := range rr.halves(sc) {
+			if pullOnly && !h.pull {
+				continue
+			}
+			hopts, err := rr.halfOptions(h, opts)
+			if err != nil {
+				l.WithError(err).Errorf("failed to build exclusion list for %s, skipping it", h.src)
+				errs = append(errs, err)
+				break
+			}
+			switch {
+			case rr.rule.AppendOnly && !h.pull:
+				err = rr.appendOnlySync(scope{src: h.src, dst: h.dst}, hopts, l)
+			default:
+				var res gsutil.SyncResult
+				res, err = rr.rsync(h, hopts, l)
+				total.Add(res)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				if h.pull && rr.twoWay() {
+					// pushing with -d now could delete remote objects that were never pulled
+					l.Warnf("pull of %s failed, skipping the push", h.src)
+					break
+				}
+			}
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// rsync runs a single gsutil rsync and applies the rule's post-processing for the
+// direction of the transfer.
+//
+// Parameters:
+//   - h: The transfer and its direction.
+//   - opts: The gsutil options for the transfer.
+//   - l: The logger entry carrying the sync reason.
+//
+// Returns:
+//   - gsutil.SyncResult: What gsutil reported to have transferred.
+//   - error: An error if gsutil or the post-processing failed.
+func (rr *ruleRunner) rsync(h half, opts gsutil.Options, l *logrus.Entry) (gsutil.SyncResult, error) {
+	src, dst := h.src, h.dst
+	if h.pull {
+		// our own marker files stay at the destination
+		opts.Ignore = append(append([]ignore.Pattern(nil), opts.Ignore...), ignore.Markers()...)
+		if rr.rule.CaseCollisions != "" && util.IsRemote(src) {
+			re, err := rr.collisionExcludes(src, opts, l)
+			if err != nil {
+				l.WithError(err).Error("case collision check failed, skipping pull")
+				return gsutil.SyncResult{}, err
+			}
+			if re != nil {
+				opts.Ignore = append(opts.Ignore, ignore.Pattern{Regexp: re})
+			}
+		}
+	}
+	// only the files this pull copied get pull_file_mode, not the user's own files
+	var pulled pulledFiles
+	if h.pull && rr.fileMode != 0 && !opts.DryRun {
+		opts.OnCopy = pulled.recorder(src, dst)
+	}
+	var res gsutil.SyncResult
+	var err error
+	if h.pull && opts.Delete && rr.absent != nil {
+		res, err = rr.pullDelayingDeletes(src, dst, opts, l)
+	} else {
+		err = rr.retry().Do(rr.ctx, func() (err error) {
+			res, err = rr.backend.RSync(rr.ctx, src, dst, opts, l)
+			return err
+		}, l)
+	}
+	if err != nil {
+		return res, err
+	}
+	if h.pull && rr.fileMode != 0 && !opts.DryRun {
+		if err := chmodFiles(pulled.paths, rr.fileMode); err != nil {
+			l.WithError(err).Warn("failed to apply pull_file_mode")
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// retry returns the retry policy for the rule's transfers (max_retries, retry_backoff,
+// retry_jitter).
+func (rr *ruleRunner) retry() gsutil.Retry {
+	jitter := gsutil.DefaultRetryJitter
+	if rr.rule.RetryJitter != nil {
+		jitter = *rr.rule.RetryJitter
+	}
+	return gsutil.Retry{Max: rr.rule.MaxRetries, Backoff: rr.rule.RetryBackoff, Jitter: jitter}
+}
+
+// options returns the gsutil options shared by every transfer of the rule.
+// Exclusions are per scope and left to the caller.
+func (rr *ruleRunner) options() gsutil.Options {
+	return gsutil.Options{
+		Delete:             !rr.rule.AppendOnly,
+		FollowSymlinks:     rr.rule.FollowSymlinks,
+		StateDir:           rr.stateDir,
+		Checksum:           rr.rule.Mirror || rr.repairing.Load(),
+		CompositeThreshold: rr.rule.CompositeThreshold,
+		ParallelProcesses:  rr.rule.ParallelProcessCount,
+		ParallelThreads:    rr.rule.ParallelThreadCount,
+		SlicedThreshold:    rr.rule.SlicedDownloadThreshold,
+		Binary:             rr.cfg.GsutilPath,
+		GlobalFlags:        rr.cfg.GsutilGlobalFlags,
+		BandwidthLimit:     rr.bandwidth,
+		DryRun:             rr.cfg.RuleDryRun(rr.rule),
+		ExitLevels:         rr.levels,
+		Stderr:             rr.stderr,
+		OnRemove:           rr.audited,
+	}
+}
+
+// checkRoot refuses deleting transfers when running as root without allow_root_delete:
+// a wrong src or dst with -d as root can wipe anything, so it needs an explicit opt-in.
+//
+// Returns:
+//   - error: errRootDelete if opts would delete as root, nil otherwise.
+func (rr *ruleRunner) checkRoot(opts gsutil.Options) error {
+	if opts.Delete && !opts.DryRun && geteuid() == 0 && !rr.cfg.AllowRootDelete {
+		return errRootDelete
+	}
+	return nil
+}
+
+// audited records an object deleted by a sync of this rule in the audit log.
+func (rr *ruleRunner) audited(url string) {
+	if err := rr.audit.Deleted(rr.rule.ID(), "sync", url); err != nil {
+		rr.log.WithError(err).Errorf("failed to write audit entry for deleted %s", url)
+	}
+}
+
+// hookEnv describes the sync about to run to hook commands.
+func (rr *ruleRunner) hookEnv(reason string, dryRun bool) []string {
+	return []string{
+		"GCS_SYNC_RULE=" + rr.rule.ID(),
+		"GCS_SYNC_SRC=" + rr.srcRoot,
+		"GCS_SYNC_DST=" + rr.rule.Dst,
+		"GCS_SYNC_REASON=" + reason,
+		"GCS_SYNC_DRY_RUN=" + strconv.FormatBool(dryRun),
+	}
+}
+
+// scope is one rsync source/destination pair of a rule.
+type scope struct {
+	src, dst string
+}
+
+// scopes returns the transfers making up a sync: the whole tree, or one per configured subpath
+// with the subpath appended to the destination.
+func (rr *ruleRunner) scopes() []scope {
+	if len(rr.subpaths) == 0 {
+		return []scope{{src: rr.srcRoot, dst: rr.rule.Dst}}
+	}
+	res := make([]scope, 0, len(rr.subpaths))
+	for _, sp := range rr.subpaths {
+		res = append(res, scope{
+			src: filepath.Join(rr.srcRoot, filepath.FromSlash(sp)),
+			dst: util.JoinLocation(rr.rule.Dst, sp),
+		})
+	}
+	return res
+}
+
+// scopeRel maps a path relative to srcRoot onto the scope it belongs to.
+//
+// Returns:
+//   - string: The path relative to its scope root, which is what ignore patterns are matched against.
+//   - bool: false if the path lies outside every configured subpath.
+func (rr *ruleRunner) scopeRel(rel string) (string, bool) {
+	if len(rr.subpaths) == 0 {
+		return rel, true
+	}
+	for _, sp := range rr.subpaths {
+		if rel == sp {
+			return ".", true
+		}
+		if strings.HasPrefix(rel, sp+"/") {
+			return rel[len(sp)+1:], true
+		}
+	}
+	return rel, false
+}
+
+// ignoredDir reports whether dir matches one of the rule's ignore patterns, matched like
+// event paths: relative to srcRoot in unix style and scoped to its subpath. The source
+// root and directories outside every subpath (e.g. a subpath's parents) never are.
+func (rr *ruleRunner) ignoredDir(dir string) bool {
+	rel, err := filepath.Rel(rr.srcRoot, dir)
+	if err != nil {
+		return false
+	}
+	scoped, inScope := rr.scopeRel(filepath.ToSlash(rel))
+	return inScope && scoped != "." && ignore.Match(scoped, rr.ign)
+}
+
+// excludes returns the rule's ignore expressions extended with the files under root
+// rejected by its source filters. Filters are evaluated against the current state of
+// the source tree, so the result must be rebuilt before every sync.
+func (rr *ruleRunner) excludes(root string) ([]ignore.Pattern, error) {
+	fns := rr.filters
+	if rr.openFiles != nil {
+		open, err := rr.openFiles.OpenForWriting(root)
+		if err != nil {
+			return nil, fmt.Errorf("detecting open files: %w", err)
+		}
+		if len(open) > 0 {
+			rr.log.Debugf("%d file(s) open for writing by other processes", len(open))
+			fns = append(fns[:len(fns):len(fns)], filter.Open(open))
+		}
+	}
+	if len(fns) == 0 && rr.broken == nil {
+		return rr.ign, nil
+	}
+	paths, err := filter.Excludes(root, rr.ign, fns, rr.broken)
+	if err != nil {
+		return nil, err
+	}
+	re := filter.Regex(paths)
+	if re == nil {
+		return rr.ign, nil
+	}
+	rr.log.Debugf("filters excluded %d file(s)", len(paths))
+	return append(append([]ignore.Pattern(nil), rr.ign...), ignore.Pattern{Regexp: re}), nil
 }
 
 // handleEvent processes a file system event and updates the watcher accordingly.
 //
 // This function is responsible for handling individual file system events. It checks
-// if the event should be ignored based on the ignore patterns, logs the event,
-// and adds new directories to the watcher if they are created.
+// if the event should be ignored based on the ignore patterns and configured subpaths,
+// logs the event, adds new directories to the watcher if they are created and forgets
+// watched directories that were removed or renamed away.
 //
 // Parameters:
 //   - ev: An fsnotify.Event representing the file system event that occurred.
-//   - w: A pointer to the fsnotify.Watcher that is monitoring the file system.
 //
-// The function does not return any value, but it may modify the watcher's state
-// by adding new directories to be watched.
-func (rr *ruleRunner) handleEvent(ev fsnotify.Event, w *fsnotify.Watcher) {
+// Returns:
+//   - bool: true if the event concerns synced content and should trigger a sync.
+func (rr *ruleRunner) handleEvent(ev fsnotify.Event) bool {
 	rel, _ := filepath.Rel(rr.srcRoot, ev.Name)
 	rel = filepath.ToSlash(rel)
 
-	if ignore.Match(rel, rr.ign) {
+	scoped, inScope := rr.scopeRel(rel)
+	if inScope && ignore.Match(scoped, rr.ign) {
 		rr.log.Debugf("ignored %s %s", ev.Op, rel)
-		return
+		return false
 	}
-	rr.log.Debugf("event %s %s", ev.Op, rel)
+	removed := ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0
+	wasDir := removed && rr.watches.has(ev.Name)
 
-	// if new dir created → watch it too
+	// if new dir created → watch it too (it may be the parent of a subpath)
 	if ev.Op&fsnotify.Create != 0 {
-		if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
-			_ = addRecursive(w, ev.Name)
+		if isWatchableDir(ev.Name, rr.rule.FollowSymlinks) {
+			_ = addRecursive(rr.watches, ev.Name, rr.rule.FollowSymlinks, rr.ignoredDir)
 		}
 	}
+	if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		rr.watches.forget(ev.Name)
+	}
+
+	if !inScope {
+		rr.log.Debugf("outside subpaths %s %s", ev.Op, rel)
+		return false
+	}
+	if removed {
+		rr.recreates.remove(ev.Name, rr.now())
+		if rr.deletes != nil && !wasDir && rr.pushes() {
+			rr.deletes.add(ev.Name, util.JoinLocation(rr.rule.Dst, rel), rr.flushDeletes)
+		}
+	}
+	if ev.Op&fsnotify.Create != 0 && rr.recreates.create(ev.Name, rr.now()) {
+		rr.log.Debugf("recreated %s, treating it as modified", rel)
+	}
+	rr.log.Debugf("event %s %s", ev.Op, rel)
+	return true
 }
 
 // containsDir checks if a given SyncDirection is present in a slice of SyncDirections.
@@ -190,24 +1034,81 @@ func containsDir(slice []config.SyncDirection, v config.SyncDirection) bool {
 //
 // This function recursively walks through the directory tree starting from the given root,
 // and adds each directory to the watcher. It skips files and only adds directories.
+// Symlinked directories are skipped, matching gsutil's `-e` behaviour, unless
+// followSymlinks is set; in that case they are descended into once per resolved target
+// so symlink cycles cannot recurse forever. Directories for which skip returns true are
+// neither watched nor descended into, so ignored trees such as node_modules cost no
+// watch descriptors and their churn never reaches the debounce.
 //
 // Parameters:
-//   - w: A pointer to the fsnotify.Watcher to which directories will be added.
+//   - w: The watchSet to which directories will be added.
 //   - root: A string representing the path to the root directory from which to start the recursive walk.
+//   - followSymlinks: Whether symlinked directories should be watched as well.
+//   - skip: Reports whether a directory is ignored; nil watches every directory.
+//
+// Directories that cannot be watched because the inotify watch limit is exhausted are
+// skipped (and counted by the watchSet) rather than aborting the walk, so that at least
+// part of the tree stays watched.
 //
 // Returns:
 //   - error: An error if there was a problem walking the directory tree or adding a directory to the watcher,
-//     or nil if all directories were successfully added.
-func addRecursive(w *fsnotify.Watcher, root string) error {
-	return filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+//     or nil if all directories were successfully added or only skipped at the watch limit.
+func addRecursive(w *watchSet, root string, followSymlinks bool, skip func(dir string) bool) error {
+	if skip != nil && skip(root) {
+		return nil
+	}
+	return addTree(w, root, followSymlinks, skip, make(map[string]bool))
+}
+
+// addTree watches dir and recurses into its subdirectories. When following symlinks,
+// seen records the resolved path of every visited directory.
+func addTree(w *watchSet, dir string, followSymlinks bool, skip func(dir string) bool, seen map[string]bool) error {
+	if followSymlinks {
+		real, err := filepath.EvalSymlinks(dir)
 		if err != nil {
 			return err
 		}
-		if d.IsDir() {
-			return w.Add(p)
+		if seen[real] {
+			return nil
 		}
-		return nil
-	})
+		seen[real] = true
+	}
+	if err := w.Add(dir); err != nil && !errors.Is(err, errWatchLimit) {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if !isWatchableDir(p, followSymlinks) || (skip != nil && skip(p)) {
+			continue
+		}
+		if err := addTree(w, p, followSymlinks, skip, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isWatchableDir reports whether p is a directory that should be watched.
+// Symlinks only qualify when followSymlinks is set and they resolve to a directory.
+func isWatchableDir(p string, followSymlinks bool) bool {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return false
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if !followSymlinks {
+			return false
+		}
+		fi, err = os.Stat(p)
+		if err != nil {
+			return false
+		}
+	}
+	return fi.IsDir()
 }
 
 // tickerTick safely selects on a ticker that may be nil.
@@ -217,3 +1118,10 @@ func tickerTick(t *time.Ticker) <-chan time.Time {
 	}
 	return nil
 }
+
+// closeLog closes the rule's log file, once nothing is logged on its behalf anymore.
+func (rr *ruleRunner) closeLog() {
+	if err := rr.logFile.Close(); err != nil {
+		logging.L().WithField("rule", rr.rule.ID()).WithError(err).Warn("failed to close log_file")
+	}
+}
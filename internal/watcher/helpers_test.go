@@ -0,0 +1,381 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/gsutil"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend stands in for gsutil and records every transfer a runner starts.
+type fakeBackend struct {
+	mu    sync.Mutex
+	calls []fakeCall
+	// rsync, when set, decides the outcome of each transfer
+	rsync func(ctx context.Context, c fakeCall) error
+}
+
+// fakeCall is one transfer seen by a fakeBackend.
+type fakeCall struct {
+	src, dst string
+	opts     gsutil.Options
+}
+
+// RSync implements gsutil.Backend.
+func (b *fakeBackend) RSync(ctx context.Context, src, dst string, opts gsutil.Options, _ *logrus.Entry) (gsutil.SyncResult, error) {
+	c := fakeCall{src: src, dst: dst, opts: opts}
+	b.mu.Lock()
+	b.calls = append(b.calls, c)
+	b.mu.Unlock()
+	if b.rsync == nil {
+		return gsutil.SyncResult{}, nil
+	}
+	return gsutil.SyncResult{}, b.rsync(ctx, c)
+}
+
+// transfers returns the transfers started so far.
+func (b *fakeBackend) transfers() []fakeCall {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeCall(nil), b.calls...)
+}
+
+// testRunner returns a runner of rule that transfers through b. An empty src is a fresh
+// directory, an empty dst a bucket prefix; deletions are allowed, since tests may run as root.
+func testRunner(t *testing.T, rule config.SyncRule, b *fakeBackend) *ruleRunner {
+	t.Helper()
+	rule.Enabled = true
+	if rule.Src == "" {
+		rule.Src = t.TempDir()
+	}
+	if rule.Dst == "" {
+		rule.Dst = "gs://bucket/data"
+	}
+	if rule.DebounceWindow == 0 {
+		rule.DebounceWindow = 10 * time.Millisecond
+	}
+	rr, err := newRuleRunner(&config.Config{AllowRootDelete: true, Sync: []config.SyncRule{rule}}, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger, _ := test.NewNullLogger()
+	rr.log = logrus.NewEntry(logger)
+	rr.backend = b
+	return rr
+}
+
+// startRunner runs rr until stop is closed and returns the channel run's result arrives on.
+func startRunner(ctx context.Context, rr *ruleRunner, stop <-chan struct{}) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- rr.run(ctx, stop) }()
+	return done
+}
+
+// fakeGsutil puts a gsutil first on PATH that runs script after appending its arguments,
+// one invocation per line, to a log, and returns a function reading the logged invocations.
+func fakeGsutil(t *testing.T, script string) func() [][]string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gsutil needs a unix shell")
+	}
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	body := "#!/bin/sh\nprintf '%s\\n' \"$*\" >> " + calls + "\n" + script
+	if err := os.WriteFile(filepath.Join(bin, "gsutil"), []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return func() [][]string {
+		b, err := os.ReadFile(calls)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+		var got [][]string
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			if line != "" {
+				got = append(got, strings.Fields(line))
+			}
+		}
+		return got
+	}
+}
+
+// eventually fails t unless cond becomes true within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// symlinkTree builds a source tree with a real subdirectory, a symlink to it, a symlink
+// to a directory outside the tree, a symlink loop and a dangling link, and returns its root.
+func symlinkTree(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	root, outside := t.TempDir(), t.TempDir()
+	for _, dir := range []string{"real/sub", "skipped"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(outside, "deep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"inner":         filepath.Join(root, "real"),
+		"outer":         outside,
+		"real/sub/loop": root,
+		"dangling":      filepath.Join(root, "missing"),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestAddRecursiveSymlinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		follow bool
+		want   []string // relative to the root
+	}{
+		{"symlinked directories are skipped", false, []string{".", "real", "real/sub"}},
+		// every resolved directory is watched once, through the first path in name order
+		{"follow_symlinks watches their targets", true, []string{".", "inner", "inner/sub", "outer", "outer/deep"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := symlinkTree(t)
+			fw, err := fsnotify.NewWatcher()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fw.Close()
+			ws := newWatchSet()
+			ws.attach(fw)
+
+			skip := func(dir string) bool { return filepath.Base(dir) == "skipped" }
+			if err := addRecursive(ws, root, tt.follow, skip); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, dir := range fw.WatchList() {
+				rel, err := filepath.Rel(root, dir)
+				if err != nil || strings.HasPrefix(rel, "..") {
+					t.Fatalf("watching %s outside the tree", dir)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("watched %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsWatchableDir(t *testing.T) {
+	root := symlinkTree(t)
+	if err := os.WriteFile(filepath.Join(root, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		follow bool
+		want   bool
+	}{
+		{"real", false, true},
+		{"real", true, true},
+		{"inner", false, false},
+		{"inner", true, true},
+		{"dangling", true, false},
+		{"file", true, false},
+		{"missing", true, false},
+	}
+	for _, tt := range tests {
+		if got := isWatchableDir(filepath.Join(root, tt.name), tt.follow); got != tt.want {
+			t.Errorf("isWatchableDir(%s, follow=%v) = %v, want %v", tt.name, tt.follow, got, tt.want)
+		}
+	}
+}
+
+func TestShutdownDuringDebouncedSync(t *testing.T) {
+	tests := []struct {
+		name     string
+		flush    bool
+		deadline bool // Stop's deadline expires while the sync runs
+		want     error
+	}{
+		{"killed without flush_on_shutdown", false, false, context.Canceled},
+		{"flush_on_shutdown waits for it", true, false, nil},
+		{"the deadline kills a flush", true, true, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started, release, result := make(chan struct{}), make(chan struct{}), make(chan error, 1)
+			b := &fakeBackend{}
+			b.rsync = func(ctx context.Context, c fakeCall) error {
+				if len(b.transfers()) == 1 {
+					return nil // the initial sync
+				}
+				close(started)
+				select {
+				case <-release:
+					result <- nil
+				case <-ctx.Done():
+					result <- ctx.Err()
+				}
+				return ctx.Err()
+			}
+			rr := testRunner(t, config.SyncRule{FlushOnShutdown: tt.flush}, b)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stop := make(chan struct{})
+			done := startRunner(ctx, rr, stop)
+			eventually(t, "the initial sync", rr.initialDone.Load)
+
+			if err := os.WriteFile(filepath.Join(rr.srcRoot, "new.txt"), []byte("x"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the debounced sync did not start")
+			}
+			close(stop)
+			if tt.flush {
+				select {
+				case <-done:
+					t.Fatal("run returned while the debounced sync was still running")
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+			if tt.deadline {
+				cancel()
+			} else if tt.flush {
+				close(release)
+			}
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			if err := <-result; !errors.Is(err, tt.want) {
+				t.Errorf("debounced sync ended with %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestShutdownReconcileReport checks that the report written on stop is not diffed with
+// the already cancelled transfer context.
+func TestShutdownReconcileReport(t *testing.T) {
+	fakeGsutil(t, "exit 0\n")
+	path := filepath.Join(t.TempDir(), "report.json")
+	rr := testRunner(t, config.SyncRule{ReconcileReport: path}, &fakeBackend{})
+	stop := make(chan struct{})
+	done := startRunner(context.Background(), rr, stop)
+	eventually(t, "the initial sync", rr.initialDone.Load)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep ReconcileReport
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Scopes) != 1 || rep.Scopes[0].Error != "" {
+		t.Errorf("report scopes = %+v, want one without error", rep.Scopes)
+	}
+}
+
+func TestPollTicker(t *testing.T) {
+	tests := []struct {
+		name       string
+		directions []config.SyncDirection
+		initial    int // transfers of the initial sync
+		wantPolls  bool
+	}{
+		{"remote_to_local polls", []config.SyncDirection{config.RemoteToLocal}, 1, true},
+		{"full polls without pushing", []config.SyncDirection{config.Full}, 2, true},
+		{"local_to_remote never polls", []config.SyncDirection{config.LocalToRemote}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{Directions: tt.directions, RemotePollWindow: 20 * time.Millisecond}, b)
+			stop := make(chan struct{})
+			done := startRunner(context.Background(), rr, stop)
+			defer func() {
+				close(stop)
+				<-done
+			}()
+
+			if !tt.wantPolls {
+				time.Sleep(100 * time.Millisecond)
+				if got := len(b.transfers()); got != tt.initial {
+					t.Errorf("%d transfers after five poll windows, want only the %d of the initial sync", got, tt.initial)
+				}
+				return
+			}
+			eventually(t, "a poll", func() bool { return len(b.transfers()) > tt.initial+1 })
+			for _, c := range b.transfers()[tt.initial:] {
+				if c.src != rr.rule.Dst || c.dst != rr.srcRoot {
+					t.Errorf("poll transferred %s → %s, want a pull %s → %s", c.src, c.dst, rr.rule.Dst, rr.srcRoot)
+				}
+			}
+		})
+	}
+}
+
+func TestRemotePollImmediate(t *testing.T) {
+	tests := []struct {
+		name      string
+		immediate bool
+		want      int
+	}{
+		{"pulls right after the initial sync", true, 2},
+		{"waits for the poll window", false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{Directions: []config.SyncDirection{config.RemoteToLocal},
+				RemotePollWindow: time.Hour, RemotePollImmediate: tt.immediate}, b)
+			stop := make(chan struct{})
+			done := startRunner(context.Background(), rr, stop)
+			defer func() {
+				close(stop)
+				<-done
+			}()
+			eventually(t, "the initial sync", func() bool { return len(b.transfers()) >= 1 })
+			time.Sleep(100 * time.Millisecond)
+			if got := len(b.transfers()); got != tt.want {
+				t.Errorf("%d transfers, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package watcher
+
+import (
+	"fmt"
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SyncLogData is the data a rule's log_template is rendered with after every sync.
+type SyncLogData struct {
+	Name      string        // the rule ID
+	Reason    string        // what triggered the sync, e.g. "debounce"
+	Direction string        // the directions synced, e.g. "local_to_remote" or "remote_to_local,local_to_remote"
+	Duration  time.Duration // how long the sync took
+	DryRun    bool          // whether nothing was actually transferred
+	Error     string        // the failure, empty on success
+	Syncs     int           // syncs of the rule so far, including this one
+	Failures  int           // failed syncs of the rule so far
+	Copied    int           // objects copied, as reported by gsutil
+	Removed   int           // objects deleted, as reported by gsutil
+	Skipped   int           // objects gsutil skipped
+	Bytes     int64         // bytes transferred; 0 when gsutil printed no summary
+}
+
+// parseLogTemplate compiles a rule's log_template.
+//
+// Returns:
+//   - *template.Template: The template, or nil when text is empty.
+//   - error: An error naming the rule if the template is invalid.
+func parseLogTemplate(rule config.SyncRule) (*template.Template, error) {
+	if rule.LogTemplate == "" {
+		return nil, nil
+	}
+	t, err := template.New(rule.ID()).Parse(rule.LogTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: log_template: %w", rule.ID(), err)
+	}
+	return t, nil
+}
+
+// direction describes what a sync transferred, for SyncLogData.
+func (rr *ruleRunner) direction(pullOnly bool) string {
+	if pullOnly {
+		return config.RemoteToLocal.String()
+	}
+	if len(rr.rule.Directions) == 0 {
+		return config.LocalToRemote.String()
+	}
+	dirs := make([]string, len(rr.rule.Directions))
+	for i, d := range rr.rule.Directions {
+		dirs[i] = d.String()
+	}
+	return strings.Join(dirs, ",")
+}
+
+// logResult logs the outcome of a sync, with the rule's log_template if it has one.
+// A template that fails to render falls back to the default message. The transfer counts
+// are attached as copied, removed, skipped and bytes fields either way.
+//
+// Parameters:
+//   - l: The logger entry carrying the sync reason.
+//   - data: The sync's outcome.
+func (rr *ruleRunner) logResult(l *logrus.Entry, data SyncLogData) {
+	l = l.WithFields(logrus.Fields{
+		"copied":  data.Copied,
+		"removed": data.Removed,
+		"skipped": data.Skipped,
+		"bytes":   data.Bytes,
+	})
+	lvl := logrus.InfoLevel
+	if data.Error != "" {
+		lvl = logrus.WarnLevel
+	}
+	if rr.logTmpl != nil {
+		var b strings.Builder
+		err := rr.logTmpl.Execute(&b, data)
+		if err == nil {
+			l.Log(lvl, b.String())
+			return
+		}
+		l.WithError(err).Debug("failed to render log_template")
+	}
+	if data.Error != "" {
+		l.Logf(lvl, "sync failed after %s: %s", data.Duration.Round(time.Millisecond), data.Error)
+		return
+	}
+	l.Logf(lvl, "sync finished in %s", data.Duration.Round(time.Millisecond))
+}
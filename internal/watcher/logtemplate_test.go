@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"testing"
+	"time"
+)
+
+func TestParseLogTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		wantNil bool
+		wantErr bool
+	}{
+		{"", true, false},
+		{"{{.Name}} done", false, false},
+		{"{{.Name", true, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLogTemplate(config.SyncRule{Name: "r", LogTemplate: tt.tmpl})
+		if (got == nil) != tt.wantNil || (err != nil) != tt.wantErr {
+			t.Errorf("parseLogTemplate(%q) = %v, %v, want nil %v, error %v", tt.tmpl, got, err, tt.wantNil, tt.wantErr)
+		}
+	}
+}
+
+func TestLogResult(t *testing.T) {
+	data := SyncLogData{Name: "photos", Reason: "debounce", Direction: "local_to_remote", Duration: 1500 * time.Millisecond,
+		Syncs: 3, Failures: 1, Copied: 2, Removed: 1, Bytes: 2048}
+	failed := data
+	failed.Error = "boom"
+	tests := []struct {
+		name      string
+		tmpl      string
+		data      SyncLogData
+		wantLevel logrus.Level
+		wantMsg   string
+	}{
+		{"default", "", data, logrus.InfoLevel, "sync finished in 1.5s"},
+		{"default failure", "", failed, logrus.WarnLevel, "sync failed after 1.5s: boom"},
+		{"template", "{{.Name}} {{.Direction}} #{{.Syncs}} copied {{.Copied}} in {{.Duration}}", data,
+			logrus.InfoLevel, "photos local_to_remote #3 copied 2 in 1.5s"},
+		{"template failure", "{{.Name}}: {{if .Error}}failed: {{.Error}}{{end}}", failed, logrus.WarnLevel, "photos: failed: boom"},
+		{"template that cannot render", "{{.Name.Missing}}", data, logrus.InfoLevel, "sync finished in 1.5s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := testRunner(t, config.SyncRule{Name: "photos", LogTemplate: tt.tmpl}, &fakeBackend{})
+			logger, hook := test.NewNullLogger()
+			rr.log = logrus.NewEntry(logger)
+			rr.logResult(rr.log, tt.data)
+			e := hook.LastEntry()
+			if e == nil || e.Level != tt.wantLevel || e.Message != tt.wantMsg {
+				t.Fatalf("logged %v, want %s %q", e, tt.wantLevel, tt.wantMsg)
+			}
+			for k, v := range map[string]any{"copied": 2, "removed": 1, "skipped": 0, "bytes": int64(2048)} {
+				if e.Data[k] != v {
+					t.Errorf("field %s = %v, want %v", k, e.Data[k], v)
+				}
+			}
+		})
+	}
+}
+
+// TestLogTemplateSync renders a template with the data of real syncs.
+func TestLogTemplateSync(t *testing.T) {
+	fail := false
+	b := &fakeBackend{rsync: func(context.Context, fakeCall) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}}
+	rr := testRunner(t, config.SyncRule{Name: "photos",
+		LogTemplate: "{{.Name}} {{.Reason}} {{.Syncs}}/{{.Failures}} {{.Error}}"}, b)
+	logger, hook := test.NewNullLogger()
+	rr.log = logrus.NewEntry(logger)
+	for i, want := range []string{"photos debounce 1/0 ", "photos debounce 2/1 boom", "photos debounce 3/1 "} {
+		fail = i == 1
+		rr.syncOnce("debounce")
+		if got := hook.LastEntry().Message; got != want {
+			t.Errorf("sync %d logged %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestDirection(t *testing.T) {
+	tests := []struct {
+		dirs     []config.SyncDirection
+		pullOnly bool
+		want     string
+	}{
+		{nil, false, "local_to_remote"},
+		{[]config.SyncDirection{config.Full}, false, "full"},
+		{[]config.SyncDirection{config.RemoteToLocal, config.LocalToRemote}, false, "remote_to_local,local_to_remote"},
+		{[]config.SyncDirection{config.Full}, true, "remote_to_local"},
+	}
+	for _, tt := range tests {
+		rr := &ruleRunner{rule: config.SyncRule{Directions: tt.dirs}}
+		if got := rr.direction(tt.pullOnly); got != tt.want {
+			t.Errorf("direction(%v, %v) = %q, want %q", tt.dirs, tt.pullOnly, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package watcher
+
+import "os"
+
+// inMaintenance reports whether the global maintenance_file exists, pausing every sync.
+//
+// Operators toggle it with touch and rm. Events keep arriving while it exists; the syncs
+// they trigger are deferred and caught up once the file is gone. Entering and leaving
+// maintenance is logged once per rule.
+func (rr *ruleRunner) inMaintenance() bool {
+	if rr.maintenanceFile == "" {
+		return false
+	}
+	_, err := os.Stat(rr.maintenanceFile)
+	present := err == nil
+	if rr.maintenance.Swap(present) != present {
+		if present {
+			rr.log.Warnf("maintenance file %s present, pausing syncs", rr.maintenanceFile)
+		} else {
+			rr.log.Infof("maintenance file %s removed, resuming syncs", rr.maintenanceFile)
+		}
+	}
+	return present
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMaintenance touches and removes the maintenance file between syncs and checks that
+// syncs are deferred only while it exists and that each transition is logged once.
+func TestMaintenance(t *testing.T) {
+	maintenance := filepath.Join(t.TempDir(), "maintenance")
+	b := &fakeBackend{}
+	rr := testRunner(t, config.SyncRule{}, b)
+	logger, hook := test.NewNullLogger()
+	rr.log = logrus.NewEntry(logger)
+	rr.maintenanceFile = maintenance
+	logged := func(lvl logrus.Level, substr string) int {
+		n := 0
+		for _, e := range hook.AllEntries() {
+			if e.Level == lvl && strings.Contains(e.Message, substr) {
+				n++
+			}
+		}
+		return n
+	}
+
+	steps := []struct {
+		name         string
+		present      bool
+		wantDeferred bool
+		wantPaused   int // "pausing syncs" warnings so far
+		wantResumed  int // "resuming syncs" messages so far
+	}{
+		{"no file", false, false, 0, 0},
+		{"file touched", true, true, 1, 0},
+		{"file still present", true, true, 1, 0},
+		{"file removed", false, false, 1, 1},
+		{"file gone", false, false, 1, 1},
+		{"file touched again", true, true, 2, 1},
+	}
+	for _, s := range steps {
+		if s.present {
+			if err := os.WriteFile(maintenance, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.Remove(maintenance); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		before := len(b.transfers())
+		rr.syncOnce("test")
+		synced := len(b.transfers()) > before
+		if rr.deferred.Load() != s.wantDeferred || synced == s.wantDeferred {
+			t.Errorf("%s: deferred = %v, synced = %v, want deferred %v", s.name, rr.deferred.Load(), synced, s.wantDeferred)
+		}
+		paused, resumed := logged(logrus.WarnLevel, "pausing syncs"), logged(logrus.InfoLevel, "resuming syncs")
+		if paused != s.wantPaused || resumed != s.wantResumed {
+			t.Errorf("%s: logged %d pauses and %d resumes, want %d and %d", s.name, paused, resumed, s.wantPaused, s.wantResumed)
+		}
+	}
+
+	rr.maintenanceFile = ""
+	if reason := rr.blocked(rr.log); reason != "" {
+		t.Errorf("blocked = %q without a maintenance_file", reason)
+	}
+}
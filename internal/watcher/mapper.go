@@ -0,0 +1,80 @@
+package watcher
+
+import (
+	"fmt"
+	"gcs_sync/internal/gsutil"
+	"gcs_sync/internal/ignore"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DstMapper decides where a single file of a rule goes.
+//
+// relPath is the unix-style path of the file relative to the rule source. The mapper
+// returns the full destination URL for the file, or skip=true to leave it out.
+type DstMapper func(relPath string) (dstURL string, skip bool)
+
+// mappedSync transfers the source tree file by file, asking the rule's DstMapper for
+// every destination.
+//
+// Unlike rsync this starts one gsutil process per file (up to transfer_workers at a time)
+// and always re-uploads, so it is considerably slower and never deletes anything at the
+// destination. It is intended for small trees with layouts rsync cannot express.
+//
+// Parameters:
+//   - ign: The exclusion expressions for this sync.
+//   - opts: The gsutil options for the transfers.
+//   - l: The logger entry carrying the sync reason.
+//
+// Returns:
+//   - error: An error if the walk failed or any transfer failed.
+func (rr *ruleRunner) mappedSync(ign []ignore.Pattern, opts gsutil.Options, l *logrus.Entry) error {
+	type transfer struct{ src, dst, rel string }
+	var transfers []transfer
+	err := filepath.WalkDir(rr.srcRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(rr.srcRoot, p)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && ignore.Match(rel, ign) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() && !(rr.rule.FollowSymlinks && isRegularTarget(p)) {
+			return nil
+		}
+		dst, skip := rr.mapper(rel)
+		if skip {
+			l.Debugf("mapper skipped %s", rel)
+			return nil
+		}
+		transfers = append(transfers, transfer{src: p, dst: dst, rel: rel})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	failed := parallel(rr.workers(), len(transfers), func(i int) error {
+		t := transfers[i]
+		fl := l.WithField("file", t.rel)
+		return rr.retry().Do(rr.ctx, func() error { return gsutil.Copy(rr.ctx, t.src, t.dst, opts, fl) }, fl)
+	})
+	if failed > 0 {
+		return fmt.Errorf("%d mapped transfer(s) failed", failed)
+	}
+	return nil
+}
+
+// isRegularTarget reports whether the symlink p resolves to a regular file.
+func isRegularTarget(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.Mode().IsRegular()
+}
@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/metrics"
+	"github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDstMapper syncs a tree through a DstMapper and checks that every file not ignored
+// or skipped is copied to the URL the mapper chose, and nothing is rsynced.
+func TestDstMapper(t *testing.T) {
+	calls := fakeGsutil(t, "exit 0\n")
+	src := t.TempDir()
+	for _, name := range []string{"a.txt", "dir/b.txt", "c.log", "tmp/d.txt"} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rule := config.SyncRule{Name: "mapped", Enabled: true, Src: src, Dst: "gs://bucket/data", DebounceWindow: time.Second,
+		Ignore: []string{"tmp"}}
+	logger, _ := test.NewNullLogger()
+	m := NewManager(&config.Config{AllowRootDelete: true, Sync: []config.SyncRule{rule}}, logger, metrics.Nop{})
+	m.DstMappers["mapped"] = func(rel string) (string, bool) {
+		if strings.HasSuffix(rel, ".log") {
+			return "", true
+		}
+		return "gs://bucket/flat/" + path.Base(rel), false
+	}
+	if err := m.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range calls() {
+		i := len(c) - 3
+		if i < 0 || c[i] != "cp" {
+			t.Errorf("gsutil %q, want only cp", c)
+			continue
+		}
+		rel, _ := filepath.Rel(src, c[i+1])
+		got = append(got, filepath.ToSlash(rel)+" → "+c[i+2])
+	}
+	sort.Strings(got)
+	want := []string{"a.txt → gs://bucket/flat/a.txt", "dir/b.txt → gs://bucket/flat/b.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("copied %q, want %q", got, want)
+	}
+}
+
+func TestDstMapperRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(r *config.SyncRule)
+		want string
+	}{
+		{"pulls", func(r *config.SyncRule) {
+			r.Directions, r.RemotePollWindow = []config.SyncDirection{config.Full}, time.Minute
+		}, "only supports local_to_remote"},
+		{"immediate deletes", func(r *config.SyncRule) { r.ImmediateDeletes = true }, "immediate_deletes"},
+		{"drift check", func(r *config.SyncRule) { r.DriftCheck = time.Hour }, "drift_check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := config.SyncRule{Name: "mapped", Enabled: true, Src: t.TempDir(), Dst: "gs://bucket/data", DebounceWindow: time.Second}
+			tt.edit(&rule)
+			logger, _ := test.NewNullLogger()
+			m := NewManager(&config.Config{Sync: []config.SyncRule{rule}}, logger, metrics.Nop{})
+			m.DstMappers["mapped"] = func(rel string) (string, bool) { return "gs://bucket/" + rel, false }
+			if err := m.RunOnce(context.Background()); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("RunOnce = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/ignore"
+	"gcs_sync/internal/util"
+	"testing"
+	"time"
+)
+
+// TestPullSkipsMarkers checks that only pulls leave the tool's marker files behind.
+func TestPullSkipsMarkers(t *testing.T) {
+	b := &fakeBackend{}
+	rr := testRunner(t, config.SyncRule{Directions: []config.SyncDirection{config.Full}, RemotePollWindow: time.Minute}, b)
+	rr.ctx = context.Background()
+	if _, err := rr.doSync("test", false, rr.log); err != nil {
+		t.Fatal(err)
+	}
+	calls := b.transfers()
+	if len(calls) != 2 {
+		t.Fatalf("%d transfers, want a push and a pull", len(calls))
+	}
+	for _, c := range calls {
+		pull := util.IsRemote(c.src)
+		for _, path := range []string{".gcs-sync-state", "dir/.gcs-sync.manifest"} {
+			if got := ignore.Match(path, c.opts.Ignore); got != pull {
+				t.Errorf("%s → %s excludes %s = %v, want %v", c.src, c.dst, path, got, pull)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/hook"
+	"github.com/sirupsen/logrus"
+	"os/exec"
+)
+
+// NetworkGate tells whether the machine is on a metered (e.g. mobile) connection.
+//
+// Detecting this is platform-specific, so gcs-sync ships no detector of its own:
+// embedders set Manager.NetworkGate, and the daemon can use a shell command through
+// the global metered_command option (see CommandGate). Rules with pause_when_metered
+// defer their syncs while the gate reports a metered connection.
+type NetworkGate interface {
+	// Metered reports whether syncing now would use a metered connection.
+	// An error is logged and treated as unmetered.
+	Metered() (bool, error)
+}
+
+// NoGate is the default NetworkGate; it never reports a metered connection.
+type NoGate struct{}
+
+// Metered implements NetworkGate.
+func (NoGate) Metered() (bool, error) { return false, nil }
+
+// CommandGate is a NetworkGate backed by a shell command: exit code 0 means metered,
+// any other exit code means unmetered.
+type CommandGate struct {
+	Command string
+	Log     *logrus.Entry
+}
+
+// Metered implements NetworkGate.
+func (g CommandGate) Metered() (bool, error) {
+	err := hook.Run("metered_command", g.Command, nil, g.Log)
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &ee):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// metered asks the rule's gate whether the connection is metered.
+func (rr *ruleRunner) metered(l *logrus.Entry) bool {
+	if rr.gate == nil {
+		return false
+	}
+	m, err := rr.gate.Metered()
+	if err != nil {
+		l.WithError(err).Warn("network gate failed, assuming an unmetered connection")
+		return false
+	}
+	return m
+}
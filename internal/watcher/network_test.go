@@ -0,0 +1,110 @@
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGate is a NetworkGate whose answer the test controls.
+type fakeGate struct {
+	metered atomic.Bool
+	err     error
+}
+
+func (g *fakeGate) Metered() (bool, error) { return g.metered.Load(), g.err }
+
+func TestCommandGate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands need a unix shell")
+	}
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"exit 0", true},
+		{"exit 1", false},
+		{"test 2 -gt 3", false},
+		{"nmcli_absent_gcs_sync 2>/dev/null", false}, // command not found is exit 127
+	}
+	logger, _ := test.NewNullLogger()
+	for _, tt := range tests {
+		got, err := CommandGate{Command: tt.command, Log: logrus.NewEntry(logger)}.Metered()
+		if err != nil || got != tt.want {
+			t.Errorf("CommandGate(%q) = %v, %v, want %v", tt.command, got, err, tt.want)
+		}
+	}
+}
+
+func TestNetworkGate(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	cfg := &config.Config{MeteredCommand: "exit 0", Sync: []config.SyncRule{
+		{Name: "paused", Enabled: true, Src: t.TempDir(), Dst: "gs://b/p", DebounceWindow: time.Second, PauseWhenMetered: true},
+		{Name: "always", Enabled: true, Src: t.TempDir(), Dst: "gs://b/a", DebounceWindow: time.Second},
+	}}
+	tests := []struct {
+		name string
+		gate NetworkGate
+	}{
+		{"metered_command", nil},
+		{"embedder's gate wins", &fakeGate{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(cfg, logger, nil)
+			m.NetworkGate = tt.gate
+			gate := m.networkGate()
+			if _, ok := gate.(CommandGate); ok != (tt.gate == nil) {
+				t.Errorf("networkGate = %T, want the command gate only without an embedder's gate", gate)
+			}
+			if err := m.prepare(gate, nil); err != nil {
+				t.Fatal(err)
+			}
+			for _, rr := range m.runners {
+				if (rr.gate != nil) != rr.rule.PauseWhenMetered {
+					t.Errorf("rule %s has gate %v, want one only with pause_when_metered", rr.rule.ID(), rr.gate)
+				}
+			}
+		})
+	}
+	if gate := NewManager(&config.Config{}, logger, nil).networkGate(); gate != (NoGate{}) {
+		t.Errorf("networkGate without a command = %T, want NoGate", gate)
+	}
+}
+
+// TestMeteredDefersSync syncs a rule with pause_when_metered while the gate reports a
+// metered connection, and again once it no longer does.
+func TestMeteredDefersSync(t *testing.T) {
+	tests := []struct {
+		name         string
+		metered      bool
+		err          error
+		wantDeferred bool
+	}{
+		{"unmetered", false, nil, false},
+		{"metered", true, nil, true},
+		{"gate failure counts as unmetered", true, errors.New("no network manager"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{PauseWhenMetered: true}, b)
+			g := &fakeGate{err: tt.err}
+			g.metered.Store(tt.metered)
+			rr.gate = g
+			rr.syncOnce("test")
+			if rr.deferred.Load() != tt.wantDeferred || (len(b.transfers()) == 0) != tt.wantDeferred {
+				t.Fatalf("deferred = %v after %d transfers, want %v", rr.deferred.Load(), len(b.transfers()), tt.wantDeferred)
+			}
+			g.metered.Store(false)
+			if reason := rr.blocked(rr.log); reason != "" {
+				t.Errorf("blocked = %q once unmetered", reason)
+			}
+		})
+	}
+}
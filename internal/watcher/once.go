@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gcs_sync/internal/audit"
+	"sync"
+)
+
+// errSyncFailed stands in for a failure whose error was not recorded.
+var errSyncFailed = errors.New("sync failed")
+
+// RunOnce syncs every enabled rule exactly once, in its configured directions, and
+// returns when all of them are done. No file watchers or polling tickers are set up,
+// which suits cron jobs and CI. Use it instead of Start, not together with it.
+//
+// Rules run concurrently. A rule outside its active window or on a metered connection
+// is skipped with a warning and does not count as failed; initial_preview and
+// initial_confirm do not apply.
+//
+// Parameters:
+//   - ctx: Cancelling it kills the running transfers.
+//
+// Returns:
+//   - error: An error if the configuration is invalid or a rule could not be prepared, or
+//     the joined errors of every rule whose sync failed.
+func (m *Manager) RunOnce(ctx context.Context) error {
+	if err := m.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	auditLog, err := audit.Open(m.cfg.AuditLog)
+	if err != nil {
+		return fmt.Errorf("audit_log: %w", err)
+	}
+	defer auditLog.Close()
+	if err := m.prepare(m.networkGate(), auditLog); err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, rr := range m.runners {
+			rr.closeLog()
+		}
+	}()
+
+	errs := make([]error, len(m.runners))
+	var wg sync.WaitGroup
+	for i, rr := range m.runners {
+		rr.ctx = ctx
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rr.syncOnce("one-shot") {
+				return
+			}
+			if rr.deferred.Load() {
+				rr.log.Warn("sync not allowed right now (active_window or metered connection), skipped")
+				return
+			}
+			err := rr.stats.lastError()
+			if err == nil {
+				err = errSyncFailed
+			}
+			errs[i] = fmt.Errorf("rule %s: %w", rr.rule.ID(), err)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
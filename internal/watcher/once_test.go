@@ -0,0 +1,105 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/metrics"
+	"github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunOnceSince(t *testing.T) {
+	tests := []struct {
+		name         string
+		since        time.Duration
+		excludeOlder time.Duration
+		wantSkipped  []string
+	}{
+		{"all files without since", 0, 0, nil},
+		{"since skips older files", 24 * time.Hour, 0, []string{"old.txt", "dir/older.txt"}},
+		{"since overrides exclude_older_than", 24 * time.Hour, 365 * 24 * time.Hour, []string{"old.txt", "dir/older.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeGsutil(t, "exit 0\n")
+			src := t.TempDir()
+			now := time.Now()
+			for name, age := range map[string]time.Duration{
+				"new.txt":       time.Hour,
+				"dir/fresh.txt": 0,
+				"old.txt":       48 * time.Hour,
+				"dir/older.txt": 30 * 24 * time.Hour,
+			} {
+				p := filepath.Join(src, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rule := config.SyncRule{Enabled: true, Src: src, Dst: "gs://bucket/data", DebounceWindow: time.Second,
+				ExcludeOlderThan: tt.excludeOlder}
+			logger, _ := test.NewNullLogger()
+			m := NewManager(&config.Config{AllowRootDelete: true, Sync: []config.SyncRule{rule}}, logger, metrics.Nop{})
+			m.Since = tt.since
+			if err := m.RunOnce(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			got := calls()
+			if len(got) != 1 {
+				t.Fatalf("ran gsutil %d times, want 1", len(got))
+			}
+			excludes := strings.Join(got[0], " ")
+			for _, name := range []string{"new.txt", "dir/fresh.txt", "old.txt", "dir/older.txt"} {
+				skipped := strings.Contains(excludes, strings.ReplaceAll(name, ".", `\.`))
+				want := false
+				for _, s := range tt.wantSkipped {
+					want = want || s == name
+				}
+				if skipped != want {
+					t.Errorf("%s skipped = %v, want %v (gsutil %s)", name, skipped, want, excludes)
+				}
+			}
+		})
+	}
+}
+
+// TestRunOnceFailure checks that a failing rule fails the run without stopping the others.
+func TestRunOnceFailure(t *testing.T) {
+	calls := fakeGsutil(t, `case "$*" in
+*gs://bucket/broken*) echo "AccessDeniedException: 403 no access" >&2; exit 1 ;;
+esac
+`)
+	var rules []config.SyncRule
+	for _, name := range []string{"first", "broken", "last"} {
+		rules = append(rules, config.SyncRule{Name: name, Enabled: true, Src: t.TempDir(), Dst: "gs://bucket/" + name,
+			DebounceWindow: time.Second})
+	}
+	logger, _ := test.NewNullLogger()
+	m := NewManager(&config.Config{AllowRootDelete: true, Sync: rules}, logger, metrics.Nop{})
+	err := m.RunOnce(context.Background())
+	if err == nil {
+		t.Fatal("RunOnce = nil, want the failure of rule broken")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "rule broken") || strings.Contains(msg, "rule first") || strings.Contains(msg, "rule last") {
+		t.Errorf("RunOnce = %q, want only rule broken to fail", msg)
+	}
+	ran := map[string]bool{}
+	for _, c := range calls() {
+		for _, name := range []string{"first", "broken", "last"} {
+			ran[name] = ran[name] || strings.Contains(strings.Join(c, " "), "gs://bucket/"+name)
+		}
+	}
+	if len(ran) != 3 || !ran["first"] || !ran["broken"] || !ran["last"] {
+		t.Errorf("synced %v, want every rule", ran)
+	}
+}
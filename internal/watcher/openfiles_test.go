@@ -0,0 +1,64 @@
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/ignore"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeDetector reports fixed files, relative to the root it is asked about, as open.
+type fakeDetector struct {
+	rel []string
+	err error
+}
+
+func (d fakeDetector) OpenForWriting(root string) (map[string]bool, error) {
+	open := make(map[string]bool)
+	for _, rel := range d.rel {
+		open[filepath.Join(root, filepath.FromSlash(rel))] = true
+	}
+	return open, d.err
+}
+
+func TestSkipOpenFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		detector *fakeDetector
+		want     map[string]bool // excluded
+		wantErr  bool
+	}{
+		{"disabled", nil, map[string]bool{"busy.log": false, "done.log": false}, false},
+		{"nothing open", &fakeDetector{}, map[string]bool{"busy.log": false, "done.log": false}, false},
+		{"open file skipped", &fakeDetector{rel: []string{"busy.log"}}, map[string]bool{"busy.log": true, "done.log": false}, false},
+		{"detector failure", &fakeDetector{err: errors.New("no /proc")}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"busy.log", "done.log"} {
+				if err := os.WriteFile(filepath.Join(src, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rr := testRunner(t, config.SyncRule{Src: src, SkipOpenFiles: true}, &fakeBackend{})
+			if tt.detector != nil {
+				rr.openFiles = *tt.detector
+			}
+			ign, err := rr.excludes(rr.srcRoot)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("excludes = %v, want error %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if got := ignore.Match(name, ign); got != want {
+					t.Errorf("%s excluded = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// parseFileMode parses an octal permission string such as "0640".
+// An empty string yields 0, meaning "leave permissions alone".
+func parseFileMode(s string) (fs.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v > 0o777 {
+		return 0, fmt.Errorf("invalid pull_file_mode %q, want octal permissions like 0640", s)
+	}
+	return fs.FileMode(v), nil
+}
+
+// pulledFiles collects the local paths of the files a pull copied, from the copies
+// gsutil reports. It is safe for concurrent use, as gsutil -m reports from several
+// processes.
+type pulledFiles struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// recorder returns a gsutil.Options.OnCopy callback adding the local path of every file
+// copied from src to dst.
+//
+// Parameters:
+//   - src: The source URL of the pull, e.g. gs://bucket/prefix.
+//   - dst: The local directory receiving the files.
+//
+// Returns:
+//   - func(from, to string): The callback.
+func (p *pulledFiles) recorder(src, dst string) func(from, to string) {
+	prefix := strings.TrimSuffix(src, "/") + "/"
+	return func(from, to string) {
+		var local string
+		if path, ok := strings.CutPrefix(to, "file://"); ok {
+			local = path
+		} else if rel, ok := strings.CutPrefix(from, prefix); ok {
+			local = filepath.Join(dst, filepath.FromSlash(rel))
+		} else {
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.paths = append(p.paths, local)
+	}
+}
+
+// chmodFiles applies mode to the given files, e.g. those a pull copied.
+//
+// gsutil creates pulled files with the process umask; this normalises them afterwards.
+// Only regular files are touched, so a path replaced by a directory or symlink since is
+// left as it is, and files gone since are skipped. On Windows only the owner write bit
+// has an effect (it toggles the read-only attribute).
+//
+// Parameters:
+//   - paths: The files to change.
+//   - mode: The permission bits to apply.
+//
+// Returns:
+//   - error: The first error encountered while changing permissions.
+func chmodFiles(paths []string, mode fs.FileMode) error {
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || info.Mode().Perm() == mode {
+			continue
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
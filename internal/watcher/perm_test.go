@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/gsutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    fs.FileMode
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0640", 0o640, false},
+		{"644", 0o644, false},
+		{"0777", 0o777, false},
+		{"1777", 0, true},
+		{"0648", 0, true},
+		{"rw-r--r--", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFileMode(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseFileMode(%q) = %o, %v, want %o, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPulledFilesRecorder(t *testing.T) {
+	dst := filepath.FromSlash("/data/pulled")
+	tests := []struct {
+		name     string
+		from, to string
+		want     []string
+	}{
+		{"gsutil names the source", "gs://bucket/prefix/a.txt", "", []string{filepath.Join(dst, "a.txt")}},
+		{"nested object", "gs://bucket/prefix/dir/b.txt", "", []string{filepath.Join(dst, "dir", "b.txt")}},
+		{"gcloud names the destination", "gs://bucket/prefix/a.txt", "file:///elsewhere/a.txt", []string{"/elsewhere/a.txt"}},
+		{"outside the prefix", "gs://bucket/other/a.txt", "", nil},
+		{"prefix of a sibling", "gs://bucket/prefix2/a.txt", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p pulledFiles
+			p.recorder("gs://bucket/prefix/", dst)(tt.from, tt.to)
+			if !reflect.DeepEqual(p.paths, tt.want) {
+				t.Errorf("recorded %q, want %q", p.paths, tt.want)
+			}
+		})
+	}
+}
+
+// TestPullFileMode pulls with a fake gsutil and checks that only the files it reports as
+// copied get the mode, not the files already in the destination.
+func TestPullFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits and the fake gsutil need unix")
+	}
+	bin, dst := t.TempDir(), t.TempDir()
+	script := `#!/bin/sh
+for last; do :; done
+mkdir -p "$last/dir" && echo a > "$last/a.txt" && echo b > "$last/dir/b.txt"
+chmod 600 "$last/a.txt" "$last/dir/b.txt"
+echo "Copying gs://bucket/prefix/a.txt [Content-Type=text/plain]..." >&2
+echo "Copying gs://bucket/prefix/dir/b.txt [Content-Type=text/plain]..." >&2
+`
+	if err := os.WriteFile(filepath.Join(bin, "gsutil"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	for name, mode := range map[string]fs.FileMode{"mine.sh": 0o700, "dir/keep": 0o600} {
+		p := filepath.Join(dst, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var pulled pulledFiles
+	logger, _ := test.NewNullLogger()
+	opts := gsutil.Options{OnCopy: pulled.recorder("gs://bucket/prefix", dst)}
+	if _, err := gsutil.RSync(context.Background(), "gs://bucket/prefix", dst, opts, logrus.NewEntry(logger)); err != nil {
+		t.Fatal(err)
+	}
+	if err := chmodFiles(append(pulled.paths, filepath.Join(dst, "gone")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]fs.FileMode{"a.txt": 0o644, "dir/b.txt": 0o644, "mine.sh": 0o700, "dir/keep": 0o600} {
+		info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %o, want %o", name, got, want)
+		}
+	}
+}
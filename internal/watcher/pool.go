@@ -0,0 +1,51 @@
+package watcher
+
+import "sync"
+
+// workers returns how many gsutil transfers of an explicit list may run at once:
+// the rule's transfer_workers (at least 1), capped by the global max_transfer_workers.
+func (rr *ruleRunner) workers() int {
+	n := max(rr.rule.TransferWorkers, 1)
+	if limit := rr.cfg.MaxTransferWorkers; limit > 0 && n > limit {
+		n = limit
+	}
+	return n
+}
+
+// parallel calls fn for every index in [0, n) using at most workers goroutines.
+//
+// Parameters:
+//   - workers: The maximum number of concurrent calls; values below 1 mean 1.
+//   - n: The number of items.
+//   - fn: The work for item i; a non-nil error counts as a failure.
+//
+// Returns:
+//   - int: The number of failed items.
+func parallel(workers, n int, fn func(i int) error) int {
+	workers = min(max(workers, 1), n)
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+	)
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if fn(i) != nil {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return failed
+}
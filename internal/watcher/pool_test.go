@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/config"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkers(t *testing.T) {
+	tests := []struct {
+		rule, limit int
+		want        int
+	}{
+		{0, 0, 1},
+		{-2, 0, 1},
+		{8, 0, 8},
+		{8, 4, 4},
+		{2, 4, 2},
+	}
+	for _, tt := range tests {
+		rr := testRunner(t, config.SyncRule{TransferWorkers: tt.rule}, &fakeBackend{})
+		rr.cfg.MaxTransferWorkers = tt.limit
+		if got := rr.workers(); got != tt.want {
+			t.Errorf("workers with transfer_workers %d, max_transfer_workers %d = %d, want %d", tt.rule, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestParallel(t *testing.T) {
+	tests := []struct {
+		workers, n int
+		wantPeak   int // calls running at once
+	}{
+		{0, 5, 1},
+		{1, 5, 1},
+		{3, 10, 3},
+		{8, 2, 2},
+		{4, 0, 0},
+	}
+	for _, tt := range tests {
+		var (
+			running, peak atomic.Int32
+			mu            sync.Mutex
+			seen          = make(map[int]int)
+		)
+		failed := parallel(tt.workers, tt.n, func(i int) error {
+			cur := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); cur > p && !peak.CompareAndSwap(p, cur); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			seen[i]++
+			mu.Unlock()
+			if i%2 == 1 {
+				return errors.New("odd")
+			}
+			return nil
+		})
+		// a slow scheduler may not reach the peak, but it must never exceed it
+		if got := int(peak.Load()); got > tt.wantPeak || (tt.wantPeak > 1 && got < 2) {
+			t.Errorf("parallel(%d, %d): %d at once, want %d", tt.workers, tt.n, got, tt.wantPeak)
+		}
+		if failed != tt.n/2 {
+			t.Errorf("parallel(%d, %d) = %d failures, want %d", tt.workers, tt.n, failed, tt.n/2)
+		}
+		for i := 0; i < tt.n; i++ {
+			if seen[i] != 1 {
+				t.Errorf("parallel(%d, %d) ran item %d %d times", tt.workers, tt.n, i, seen[i])
+			}
+		}
+	}
+}
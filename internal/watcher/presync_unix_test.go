@@ -0,0 +1,67 @@
+//go:build unix
+
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPreSyncHook runs a pre_sync hook that records its environment and checks that its
+// exit status decides whether the rule transfers anything.
+func TestPreSyncHook(t *testing.T) {
+	tests := []struct {
+		name   string
+		exit   string
+		dryRun bool
+		want   bool // transferred
+	}{
+		{"hook succeeds", "0", false, true},
+		{"hook fails", "3", false, false},
+		{"dry-run is announced", "0", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "env")
+			calls := fakeGsutil(t, "exit 0\n") // dry-runs list the changes with gsutil itself
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{Name: "photos",
+				PreSync: "env | grep '^GCS_SYNC_' | sort > " + out + "; exit " + tt.exit}, b)
+			rr.cfg.DryRun = tt.dryRun
+
+			_, err := rr.doSync("debounce", false, rr.log)
+			if (err == nil) != tt.want {
+				t.Errorf("doSync = %v, want an error %v", err, !tt.want)
+			}
+			if err != nil && !strings.Contains(err.Error(), "pre_sync hook failed") {
+				t.Errorf("doSync = %v, want the hook failure", err)
+			}
+			if ran := len(b.transfers())+len(calls()) > 0; ran != tt.want {
+				t.Errorf("transferred = %v, want %v", ran, tt.want)
+			}
+
+			env, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("the hook did not run: %v", err)
+			}
+			dryRun := "false"
+			if tt.dryRun {
+				dryRun = "true"
+			}
+			want := []string{
+				"GCS_SYNC_DRY_RUN=" + dryRun,
+				"GCS_SYNC_DST=gs://bucket/data",
+				"GCS_SYNC_REASON=debounce",
+				"GCS_SYNC_RULE=photos",
+				"GCS_SYNC_SRC=" + rr.srcRoot,
+			}
+			if got := strings.Split(strings.TrimSpace(string(env)), "\n"); !reflect.DeepEqual(got, want) {
+				t.Errorf("hook environment\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}
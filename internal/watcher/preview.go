@@ -0,0 +1,81 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"gcs_sync/internal/gsutil"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// errUnknownRule is returned by Manager methods addressing a rule that is not running.
+	errUnknownRule = errors.New("unknown rule")
+	// errNotPaused is returned by Confirm for rules that are not waiting for confirmation.
+	errNotPaused = errors.New("rule is not waiting for confirmation")
+)
+
+// preview logs what the next sync would change, using the same dry-run diff as prune.
+//
+// Failures are only logged: the preview is informational and never blocks the sync.
+// Rules with a DstMapper are transferred file by file and cannot be previewed.
+//
+// Every half is diffed against the current trees, before any of them ran. For two-way
+// rules the push therefore lists no removals: the remote-only objects it would report are
+// pulled first by the real sync, not removed.
+//
+// Parameters:
+//   - l: The logger entry carrying the preview reason.
+func (rr *ruleRunner) preview(l *logrus.Entry) {
+	if rr.mapper != nil {
+		l.Warn("preview is not supported for rules with a destination mapper")
+		return
+	}
+	opts := rr.options()
+	for _, sc := range rr.scopes() {
+		for _, h := range rr.halves(sc) {
+			hopts, err := rr.halfOptions(h, opts)
+			if err != nil {
+				l.WithError(err).Warnf("failed to build exclusion list for %s, not previewing it", h.src)
+				continue
+			}
+			if !h.pull && rr.twoWay() {
+				hopts.Delete = false
+			}
+			diff, err := gsutil.DryRunDiff(rr.ctx, h.src, h.dst, hopts, l)
+			if err != nil {
+				l.WithError(err).Warnf("failed to preview %s", h.dst)
+				continue
+			}
+			for _, c := range diff.Copies {
+				l.Infof("would copy %s to %s", c.Src, c.Dst)
+			}
+			for _, r := range diff.Removals {
+				l.Infof("would remove %s", r)
+			}
+			l.Infof("preview of %s: %d copy(ies), %d removal(s)", h.dst, len(diff.Copies), len(diff.Removals))
+		}
+	}
+}
+
+// Confirm releases the initial sync of a rule configured with initial_confirm.
+//
+// Parameters:
+//   - id: The rule ID (see config.SyncRule.ID).
+//
+// Returns:
+//   - error: An error if the rule is unknown, or errNotPaused if it is not waiting for
+//     confirmation: it has no initial_confirm, was confirmed already or has not finished
+//     its initial preview yet.
+func (m *Manager) Confirm(id string) error {
+	for _, rr := range m.snapshot() {
+		if rr.rule.ID() != id {
+			continue
+		}
+		if rr.confirm == nil || !rr.paused.CompareAndSwap(true, false) {
+			return errNotPaused
+		}
+		rr.confirm <- struct{}{} // buffered, and only sent once
+		return nil
+	}
+	return fmt.Errorf("%w %q", errUnknownRule, id)
+}
@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/config"
+	"slices"
+	"testing"
+)
+
+func TestPreviewRemovals(t *testing.T) {
+	tests := []struct {
+		name       string
+		directions []config.SyncDirection
+		wantDelete map[bool]bool // by half: pull or push
+	}{
+		{"push", []config.SyncDirection{config.LocalToRemote}, map[bool]bool{false: true}},
+		{"pull", []config.SyncDirection{config.RemoteToLocal}, map[bool]bool{true: false}},
+		// the push runs after the pull, which fetches the remote-only objects first
+		{"full", []config.SyncDirection{config.Full}, map[bool]bool{true: false, false: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeGsutil(t, "exit 0\n")
+			rr := testRunner(t, config.SyncRule{Directions: tt.directions}, &fakeBackend{})
+			rr.preview(rr.log)
+
+			got := map[bool]bool{}
+			for _, args := range calls() {
+				if len(args) < 2 || !slices.Contains(args, "-n") {
+					t.Fatalf("preview ran gsutil %q, want dry-runs", args)
+				}
+				pull := args[len(args)-2] == rr.rule.Dst
+				got[pull] = slices.Contains(args, "-d")
+			}
+			if len(got) != len(tt.wantDelete) {
+				t.Fatalf("previewed halves %v, want %v", got, tt.wantDelete)
+			}
+			for pull, want := range tt.wantDelete {
+				if got[pull] != want {
+					t.Errorf("preview of the pull=%v half passes -d = %v, want %v", pull, got[pull], want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	paused := testRunner(t, config.SyncRule{Name: "paused", InitialConfirm: true}, &fakeBackend{})
+	paused.paused.Store(true)
+	running := testRunner(t, config.SyncRule{Name: "running"}, &fakeBackend{})
+	m := &Manager{runners: []*ruleRunner{paused, running}}
+
+	tests := []struct {
+		name string
+		id   string
+		want error
+	}{
+		{"unknown", "missing", errUnknownRule},
+		{"not configured", "running", errNotPaused},
+		{"paused", "paused", nil},
+		{"confirmed twice", "paused", errNotPaused},
+	}
+	for _, tt := range tests {
+		if err := m.Confirm(tt.id); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Confirm(%q) = %v, want %v", tt.name, tt.id, err, tt.want)
+		}
+	}
+	select {
+	case <-paused.confirm:
+	default:
+		t.Error("Confirm did not release the paused rule")
+	}
+}
@@ -0,0 +1,96 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"gcs_sync/internal/config"
+	"gcs_sync/internal/gsutil"
+	"gcs_sync/internal/util"
+)
+
+// Pruner finds the destination objects of a rule that have no counterpart in its source,
+// and removes them on request. It transfers with the options a sync of the rule would use
+// (state_dir, bandwidth_limit, stderr classification, ignore patterns and filters), so
+// exactly the objects a deleting sync would remove are reported.
+type Pruner struct {
+	rr *ruleRunner
+}
+
+// NewPruner prepares the pruning of a rule, without watching or syncing anything.
+//
+// Parameters:
+//   - cfg: The global configuration the rule belongs to.
+//   - rule: The rule whose destination is pruned.
+//
+// Returns:
+//   - *Pruner: The pruner.
+//   - error: An error if the rule has no cloud destination or could not be prepared
+//     (see newRuleRunner).
+func NewPruner(cfg *config.Config, rule config.SyncRule) (*Pruner, error) {
+	if dst, _ := util.ExpandEnv(rule.Dst); !util.IsRemote(dst) {
+		return nil, fmt.Errorf("rule %q: prune only supports cloud destinations", rule.ID())
+	}
+	rr, err := newRuleRunner(cfg, rule)
+	if err != nil {
+		return nil, err
+	}
+	return &Pruner{rr: rr}, nil
+}
+
+// Dst returns the rule's destination, with environment variables expanded.
+func (p *Pruner) Dst() string {
+	return p.rr.rule.Dst
+}
+
+// DryRun reports whether the rule runs in dry-run mode, in which Remove deletes nothing.
+func (p *Pruner) DryRun() bool {
+	return p.rr.cfg.RuleDryRun(p.rr.rule)
+}
+
+// Orphans lists the destination objects missing from the source, with a `gsutil rsync -n -d`
+// dry-run of every scope of the rule.
+//
+// Parameters:
+//   - ctx: Kills gsutil when cancelled.
+//
+// Returns:
+//   - []string: The URLs of the orphaned objects.
+//   - error: An error if the exclusion list could not be built or gsutil failed.
+func (p *Pruner) Orphans(ctx context.Context) ([]string, error) {
+	rr := p.rr
+	opts := rr.options()
+	opts.Delete = true
+	var orphans []string
+	for _, sc := range rr.scopes() {
+		hopts, err := rr.halfOptions(half{src: sc.src, dst: sc.dst}, opts)
+		if err != nil {
+			return nil, fmt.Errorf("building exclusion list for %s: %w", sc.src, err)
+		}
+		d, err := gsutil.DryRunDiff(ctx, sc.src, sc.dst, hopts, rr.log)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", sc.dst, err)
+		}
+		orphans = append(orphans, d.Removals...)
+	}
+	return orphans, nil
+}
+
+// Remove deletes the given objects, refusing to as root without allow_root_delete like a
+// deleting sync does.
+//
+// Parameters:
+//   - ctx: Kills gsutil when cancelled.
+//   - urls: The objects to delete, as listed by Orphans.
+//   - onRemove: Called with every deleted URL, e.g. for the audit log; may be nil.
+//
+// Returns:
+//   - error: errRootDelete, or an error if gsutil failed.
+func (p *Pruner) Remove(ctx context.Context, urls []string, onRemove func(url string)) error {
+	rr := p.rr
+	opts := rr.options()
+	opts.Delete, opts.OnRemove = true, onRemove
+	if err := rr.checkRoot(opts); err != nil {
+		return err
+	}
+	return gsutil.Remove(ctx, urls, opts, rr.log)
+}
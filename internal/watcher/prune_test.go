@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"gcs_sync/internal/config"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestPrunerOrphans(t *testing.T) {
+	calls := fakeGsutil(t, `echo "Would copy file:///src/new.txt to gs://bucket/data/new.txt" >&2
+echo "Would remove gs://bucket/data/old.txt" >&2
+`)
+	stateDir := t.TempDir()
+	rule := config.SyncRule{Enabled: true, Src: t.TempDir(), Dst: "gs://bucket/data", Ignore: []string{"*.tmp"}, StateDir: stateDir,
+		AppendOnly: true}
+	p, err := NewPruner(&config.Config{Sync: []config.SyncRule{rule}}, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphans, err := p.Orphans(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gs://bucket/data/old.txt"}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("Orphans = %q, want %q", orphans, want)
+	}
+	got := calls()
+	if len(got) != 1 {
+		t.Fatalf("ran gsutil %d times, want 1", len(got))
+	}
+	// the rule's sync options apply, with -d even for append_only
+	for _, arg := range []string{"-n", "-d", "-x", "GSUtil:state_dir=" + stateDir} {
+		if !slices.Contains(got[0], arg) {
+			t.Errorf("gsutil %q lacks %s", got[0], arg)
+		}
+	}
+}
+
+func TestNewPrunerLocalDst(t *testing.T) {
+	rule := config.SyncRule{Enabled: true, Src: t.TempDir(), Dst: filepath.Join(t.TempDir(), "mirror")}
+	if _, err := NewPruner(&config.Config{Sync: []config.SyncRule{rule}}, rule); err == nil {
+		t.Error("NewPruner accepted a local destination")
+	}
+}
+
+func TestPrunerRemoveAsRoot(t *testing.T) {
+	tests := []struct {
+		name   string
+		euid   int
+		allow  bool
+		dryRun bool
+		want   error
+		wantRm bool
+	}{
+		{"user", 1000, false, false, nil, true},
+		{"root", 0, false, false, errRootDelete, false},
+		{"root with allow_root_delete", 0, true, false, nil, true},
+		{"root in dry-run", 0, false, true, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeGsutil(t, "cat > /dev/null\n")
+			euid := geteuid
+			geteuid = func() int { return tt.euid }
+			t.Cleanup(func() { geteuid = euid })
+			rule := config.SyncRule{Enabled: true, Src: t.TempDir(), Dst: "gs://bucket/data"}
+			cfg := &config.Config{AllowRootDelete: tt.allow, DryRun: tt.dryRun, Sync: []config.SyncRule{rule}}
+			p, err := NewPruner(cfg, rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var removed []string
+			err = p.Remove(context.Background(), []string{"gs://bucket/data/old.txt"}, func(url string) { removed = append(removed, url) })
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Remove = %v, want %v", err, tt.want)
+			}
+			ran := len(calls()) > 0
+			if ran != tt.wantRm {
+				t.Errorf("gsutil rm ran = %v, want %v", ran, tt.wantRm)
+			}
+		})
+	}
+}
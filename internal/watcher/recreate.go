@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// recreates tracks recently removed paths for recreate_grace.
+//
+// Editors often save by deleting a file and creating it again. A sync running in between
+// would propagate the deletion, so the debounced sync waits until every removal is either
+// followed by a Create of the same path (then it was a plain modification) or older than
+// the grace window. The zero value with grace 0 disables the tracking.
+type recreates struct {
+	grace   time.Duration
+	mu      sync.Mutex
+	removed map[string]time.Time // path → time of the Remove/Rename event
+}
+
+// remove records that path disappeared at now.
+func (r *recreates) remove(path string, now time.Time) {
+	if r.grace <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.removed == nil {
+		r.removed = make(map[string]time.Time)
+	}
+	r.removed[path] = now
+}
+
+// create records that path appeared at now.
+//
+// Returns:
+//   - bool: true if path was removed less than the grace window ago, i.e. it was recreated.
+func (r *recreates) create(path string, now time.Time) bool {
+	if r.grace <= 0 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at, ok := r.removed[path]
+	delete(r.removed, path)
+	return ok && now.Sub(at) < r.grace
+}
+
+// wait returns how long a sync should still be delayed for pending removals to be
+// recreated, forgetting removals whose grace window has passed.
+//
+// Returns:
+//   - time.Duration: The time until the youngest pending removal expires, or 0 if none is pending.
+func (r *recreates) wait(now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var d time.Duration
+	for path, at := range r.removed {
+		left := r.grace - now.Sub(at)
+		if left <= 0 {
+			delete(r.removed, path)
+			continue
+		}
+		d = max(d, left)
+	}
+	return d
+}
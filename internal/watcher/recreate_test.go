@@ -0,0 +1,136 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecreates(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := time.Second
+	type op struct {
+		kind string // "remove", "create" or "wait"
+		path string
+		at   time.Duration
+		want any // create: bool, wait: time.Duration
+	}
+	tests := []struct {
+		name  string
+		grace time.Duration
+		ops   []op
+	}{
+		{"disabled", 0, []op{
+			{"remove", "a", 0, nil},
+			{"create", "a", s, false},
+			{"wait", "", s, time.Duration(0)},
+		}},
+		{"recreated within the grace", 5 * s, []op{
+			{"remove", "a", 0, nil},
+			{"wait", "", s, 4 * s},
+			{"create", "a", 2 * s, true},
+			{"wait", "", 2 * s, time.Duration(0)},
+		}},
+		{"recreated too late", 5 * s, []op{
+			{"remove", "a", 0, nil},
+			{"create", "a", 6 * s, false},
+		}},
+		{"created without a removal", 5 * s, []op{
+			{"create", "a", 0, false},
+		}},
+		{"the youngest removal decides", 5 * s, []op{
+			{"remove", "a", 0, nil},
+			{"remove", "b", 3 * s, nil},
+			{"wait", "", 4 * s, 4 * s},
+			{"create", "b", 4 * s, true},
+			{"wait", "", 4 * s, s},
+		}},
+		{"expired removals are forgotten", 5 * s, []op{
+			{"remove", "a", 0, nil},
+			{"wait", "", 5 * s, time.Duration(0)},
+			{"create", "a", 5 * s, false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recreates{grace: tt.grace}
+			for i, o := range tt.ops {
+				now := t0.Add(o.at)
+				var got any
+				switch o.kind {
+				case "remove":
+					r.remove(o.path, now)
+					continue
+				case "create":
+					got = r.create(o.path, now)
+				case "wait":
+					got = r.wait(now)
+				}
+				if got != o.want {
+					t.Errorf("op %d: %s(%s) at %s = %v, want %v", i, o.kind, o.path, o.at, got, o.want)
+				}
+			}
+		})
+	}
+}
+
+// TestRecreateGraceDelaysSync checks that a removal holds the debounced sync back for
+// recreate_grace, unless the file is recreated first.
+func TestRecreateGraceDelaysSync(t *testing.T) {
+	const grace = 400 * time.Millisecond
+	tests := []struct {
+		name     string
+		recreate bool
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{"removed for good", false, grace, 10 * time.Second},
+		{"recreated", true, 0, grace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var synced []time.Time
+			b := &fakeBackend{rsync: func(ctx context.Context, c fakeCall) error {
+				mu.Lock()
+				defer mu.Unlock()
+				synced = append(synced, time.Now())
+				return nil
+			}}
+			rr := testRunner(t, config.SyncRule{DebounceWindow: 20 * time.Millisecond, RecreateGrace: grace}, b)
+			file := filepath.Join(rr.srcRoot, "doc.txt")
+			if err := os.WriteFile(file, []byte("v1"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			stop := make(chan struct{})
+			done := startRunner(context.Background(), rr, stop)
+			defer func() {
+				close(stop)
+				<-done
+			}()
+			eventually(t, "the initial sync", func() bool { return len(b.transfers()) == 1 })
+
+			removed := time.Now()
+			if err := os.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+			if tt.recreate {
+				time.Sleep(5 * time.Millisecond)
+				if err := os.WriteFile(file, []byte("v2"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			eventually(t, "a sync of the change", func() bool { return len(b.transfers()) > 1 })
+			mu.Lock()
+			delay := synced[1].Sub(removed)
+			mu.Unlock()
+			if delay < tt.minDelay || delay >= tt.maxDelay {
+				t.Errorf("synced %s after the removal, want within [%s, %s)", delay, tt.minDelay, tt.maxDelay)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus"
+	"reflect"
+)
+
+// errStopped is returned by Reload once the Manager was stopped.
+var errStopped = errors.New("manager is stopped")
+
+// ruleDiff lists, by rule ID, how the enabled rules of two configurations differ.
+type ruleDiff struct {
+	added   []string // enabled only in the new configuration
+	removed []string // enabled only in the old configuration (deleted or disabled)
+	changed []string // enabled in both, with different settings
+}
+
+// empty reports whether the configurations run the same rules.
+func (d ruleDiff) empty() bool {
+	return len(d.added)+len(d.removed)+len(d.changed) == 0
+}
+
+// diffRules compares the enabled rules of two configurations.
+//
+// Rules are matched by ID (see config.SyncRule.ID), so renaming a rule counts as removing
+// it and adding another. When the global options differ, every rule present in both is
+// reported as changed, since runners read them too.
+//
+// Parameters:
+//   - old: The running configuration.
+//   - cur: The configuration to switch to.
+//
+// Returns:
+//   - ruleDiff: The rule IDs to start, stop and restart.
+func diffRules(old, cur *config.Config) ruleDiff {
+	before, after := enabledRules(old), enabledRules(cur)
+	globals := globalsEqual(old, cur)
+	var d ruleDiff
+	for _, r := range cur.Sync {
+		if !r.Enabled {
+			continue
+		}
+		prev, ok := before[r.ID()]
+		switch {
+		case !ok:
+			d.added = append(d.added, r.ID())
+		case !globals || !reflect.DeepEqual(prev, r):
+			d.changed = append(d.changed, r.ID())
+		}
+	}
+	for _, r := range old.Sync {
+		if _, ok := after[r.ID()]; r.Enabled && !ok {
+			d.removed = append(d.removed, r.ID())
+		}
+	}
+	return d
+}
+
+// enabledRules indexes the enabled rules of cfg by ID.
+func enabledRules(cfg *config.Config) map[string]config.SyncRule {
+	res := make(map[string]config.SyncRule, len(cfg.Sync))
+	for _, r := range cfg.Sync {
+		if r.Enabled {
+			res[r.ID()] = r
+		}
+	}
+	return res
+}
+
+// globalsEqual reports whether two configurations agree on everything but their rules.
+func globalsEqual(a, b *config.Config) bool {
+	x, y := *a, *b
+	x.Sync, y.Sync = nil, nil
+	return reflect.DeepEqual(x, y)
+}
+
+// Reload switches the running Manager to a new configuration without a restart.
+//
+// Rules that were added or enabled are started, rules that were deleted or disabled are
+// stopped, and rules whose settings changed are restarted, which kills their in-flight
+// transfer and runs a new initial sync. Unchanged rules keep running undisturbed.
+// Global options reach the rules by restarting all of them; those only read by Start
+// (audit_log, metered_command, min_open_files) need a process restart.
+//
+// The new runners are prepared before anything is stopped, so an invalid configuration
+// or a rule that cannot be prepared leaves the running rules untouched.
+//
+// Parameters:
+//   - cfg: The new configuration; it is validated first.
+//
+// Returns:
+//   - error: An error if the configuration is invalid, a rule could not be prepared or
+//     the Manager was stopped.
+func (m *Manager) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return errStopped
+	}
+	d := diffRules(m.cfg, cfg)
+	if !globalsEqual(m.cfg, cfg) {
+		m.log.Warn("global options changed: restarting every rule; audit_log, metered_command and min_open_files need a process restart")
+	}
+	if d.empty() {
+		m.cfg = cfg
+		m.log.Info("configuration reloaded, no rule changed")
+		return nil
+	}
+
+	// prepare first: a rule failing here must not leave the daemon half-reconfigured
+	rules := enabledRules(cfg)
+	fresh := make(map[string]*ruleRunner, len(d.added)+len(d.changed))
+	for _, id := range append(append([]string(nil), d.added...), d.changed...) {
+		rr, err := m.newRunner(cfg, rules[id], m.gate, m.audit)
+		if err != nil {
+			for _, rr := range fresh {
+				rr.closeLog()
+			}
+			return err
+		}
+		fresh[id] = rr
+	}
+
+	running := make(map[string]*ruleRunner, len(m.runners))
+	for _, rr := range m.runners {
+		running[rr.rule.ID()] = rr
+	}
+	for _, id := range append(append([]string(nil), d.removed...), d.changed...) {
+		running[id].log.Info("stopping watcher for configuration reload")
+		m.halt(running[id])
+	}
+
+	var runners []*ruleRunner
+	for _, r := range cfg.Sync {
+		if !r.Enabled {
+			continue
+		}
+		if rr, ok := fresh[r.ID()]; ok {
+			m.launch(rr)
+			runners = append(runners, rr)
+			continue
+		}
+		runners = append(runners, running[r.ID()])
+	}
+	m.cfg, m.runners = cfg, runners
+	m.log.WithFields(logrus.Fields{
+		"added": len(d.added), "removed": len(d.removed), "changed": len(d.changed),
+	}).Info("configuration reloaded")
+	return nil
+}
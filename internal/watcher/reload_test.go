@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus/hooks/test"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffRules(t *testing.T) {
+	rule := func(name, src string, enabled bool) config.SyncRule {
+		return config.SyncRule{Name: name, Enabled: enabled, Src: src, Dst: "gs://bucket/" + name, DebounceWindow: time.Second}
+	}
+	old := &config.Config{Sync: []config.SyncRule{
+		rule("same", "/srv/same", true),
+		rule("edited", "/srv/edited", true),
+		rule("deleted", "/srv/deleted", true),
+		rule("disabled", "/srv/disabled", true),
+		rule("enabled", "/srv/enabled", false),
+	}}
+	edited := rule("edited", "/srv/edited", true)
+	edited.DebounceWindow = time.Minute
+	cur := &config.Config{Sync: []config.SyncRule{
+		rule("same", "/srv/same", true),
+		edited,
+		rule("disabled", "/srv/disabled", false),
+		rule("enabled", "/srv/enabled", true),
+		rule("new", "/srv/new", true),
+	}}
+	globals := *cur
+	globals.MaxConcurrentSyncs = 4
+
+	tests := []struct {
+		name     string
+		old, cur *config.Config
+		want     ruleDiff
+	}{
+		{"unchanged", old, old, ruleDiff{}},
+		{"added, removed and changed", old, cur, ruleDiff{
+			added:   []string{"enabled", "new"},
+			removed: []string{"deleted", "disabled"},
+			changed: []string{"edited"},
+		}},
+		{"global options restart every rule", cur, &globals, ruleDiff{
+			changed: []string{"same", "edited", "enabled", "new"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffRules(tt.old, tt.cur)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffRules = %+v, want %+v", got, tt.want)
+			}
+			if got.empty() != (tt.name == "unchanged") {
+				t.Errorf("empty() = %v", got.empty())
+			}
+		})
+	}
+}
+
+// TestReloadInvalid checks that a configuration failing validation leaves the running
+// rules and configuration alone.
+func TestReloadInvalid(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	rr := testRunner(t, config.SyncRule{}, &fakeBackend{})
+	cfg := rr.cfg
+	m := NewManager(cfg, logger, nil)
+	m.runners = []*ruleRunner{rr}
+
+	bad := *cfg
+	bad.Sync = []config.SyncRule{{Enabled: true, Src: "/srv/data"}}
+	if err := m.Reload(&bad); err == nil {
+		t.Fatal("Reload accepted a rule without dst")
+	}
+	if m.cfg != cfg || len(m.runners) != 1 || m.runners[0] != rr {
+		t.Errorf("Reload of an invalid configuration replaced the running rules")
+	}
+	if rr.quit != nil {
+		t.Errorf("Reload of an invalid configuration touched the running rule")
+	}
+}
@@ -0,0 +1,147 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gcs_sync/internal/gsutil"
+	"gcs_sync/internal/util"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errNoReport is returned for rules without a reconcile_report path.
+var errNoReport = errors.New("rule has no reconcile_report configured")
+
+// ReconcileReport lists what a sync of a rule would still change, per scope and direction.
+type ReconcileReport struct {
+	Rule        string        `json:"rule"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	DryRun      bool          `json:"dry_run"`
+	Scopes      []ScopeReport `json:"scopes"`
+}
+
+// ScopeReport is the categorised diff of one source/destination pair; pulls of two-way
+// rules appear with the rule's dst as Src.
+type ScopeReport struct {
+	Src     string   `json:"src"`
+	Dst     string   `json:"dst"`
+	Adds    []string `json:"adds"`    // missing at the destination
+	Updates []string `json:"updates"` // present at the destination, but different
+	Deletes []string `json:"deletes"` // only present at the destination
+	Error   string   `json:"error,omitempty"`
+}
+
+// reconcile builds the rule's reconciliation report from a dry-run diff of every scope.
+//
+// Copies are split into adds and updates by looking the destination up; a scope that
+// cannot be diffed is reported with its error rather than failing the whole report.
+//
+// Parameters:
+//   - ctx: Kills the gsutil invocations when cancelled.
+//   - l: The logger entry for the gsutil invocations.
+//
+// Returns:
+//   - ReconcileReport: The report.
+func (rr *ruleRunner) reconcile(ctx context.Context, l *logrus.Entry) ReconcileReport {
+	opts := rr.options()
+	rep := ReconcileReport{Rule: rr.rule.ID(), GeneratedAt: rr.now().UTC(), DryRun: opts.DryRun}
+	for _, sc := range rr.scopes() {
+		for _, h := range rr.halves(sc) {
+			sr := ScopeReport{Src: h.src, Dst: h.dst, Adds: []string{}, Updates: []string{}, Deletes: []string{}}
+			if err := rr.reconcileHalf(ctx, h, opts, &sr, l); err != nil {
+				sr.Error = err.Error()
+			}
+			rep.Scopes = append(rep.Scopes, sr)
+		}
+	}
+	return rep
+}
+
+// reconcileHalf fills sr with the categorised diff of a single transfer.
+func (rr *ruleRunner) reconcileHalf(ctx context.Context, h half, opts gsutil.Options, sr *ScopeReport, l *logrus.Entry) error {
+	opts, err := rr.halfOptions(h, opts)
+	if err != nil {
+		return err
+	}
+	diff, err := gsutil.DryRunDiff(ctx, h.src, h.dst, opts, l)
+	if err != nil {
+		return err
+	}
+	sr.Deletes = append(sr.Deletes, diff.Removals...)
+	if len(diff.Copies) == 0 {
+		return nil
+	}
+	dstTimes, err := rr.destinationTimes(ctx, h.dst, opts, l)
+	if err != nil {
+		return err
+	}
+	for _, c := range diff.Copies {
+		if _, ok := dstTimes(strings.TrimPrefix(c.Dst, "file://")); ok {
+			sr.Updates = append(sr.Updates, c.Dst)
+		} else {
+			sr.Adds = append(sr.Adds, c.Dst)
+		}
+	}
+	return nil
+}
+
+// writeReport writes the rule's reconciliation report as JSON to its reconcile_report path.
+// The file is replaced atomically, so readers never see a partial report.
+//
+// Parameters:
+//   - ctx: Kills the dry-runs when cancelled. On shutdown rr.ctx is already cancelled,
+//     so run passes rr.shutdown instead.
+//
+// Returns:
+//   - ReconcileReport: The report that was written.
+//   - error: errNoReport without a configured path, or the error that prevented writing it.
+func (rr *ruleRunner) writeReport(ctx context.Context) (ReconcileReport, error) {
+	if rr.rule.ReconcileReport == "" {
+		return ReconcileReport{}, errNoReport
+	}
+	path := util.Expand(rr.rule.ReconcileReport)
+	rep := rr.reconcile(ctx, rr.log.WithField("reason", "reconcile report"))
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return rep, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return rep, fmt.Errorf("writing reconcile report: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return rep, fmt.Errorf("writing reconcile report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return rep, fmt.Errorf("writing reconcile report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return rep, fmt.Errorf("writing reconcile report: %w", err)
+	}
+	rr.log.Infof("reconcile report written to %s", path)
+	return rep, nil
+}
+
+// Reconcile writes the reconciliation report of a rule on demand.
+//
+// Parameters:
+//   - id: The rule ID (see config.SyncRule.ID).
+//
+// Returns:
+//   - ReconcileReport: The report that was written.
+//   - error: An error if the rule is unknown, has no reconcile_report or writing failed.
+func (m *Manager) Reconcile(id string) (ReconcileReport, error) {
+	for _, rr := range m.snapshot() {
+		if rr.rule.ID() == id {
+			return rr.writeReport(rr.ctx)
+		}
+	}
+	return ReconcileReport{}, fmt.Errorf("%w %q", errUnknownRule, id)
+}
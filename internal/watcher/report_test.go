@@ -0,0 +1,89 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestReconcileReport writes the report of a rule whose dry-run proposes an add, an update
+// and a delete, through the status API.
+func TestReconcileReport(t *testing.T) {
+	fakeGsutil(t, `case "$*" in
+*" rsync "*)
+	echo "Would copy file:///src/new.txt to gs://bucket/data/new.txt"
+	echo "Would copy file:///src/changed.txt to gs://bucket/data/changed.txt"
+	echo "Would remove gs://bucket/data/gone.txt" ;;
+*" ls "*)
+	echo "         1  2024-01-01T00:00:00Z  gs://bucket/data/changed.txt"
+	echo "         1  2024-01-01T00:00:00Z  gs://bucket/data/gone.txt" ;;
+esac
+`)
+	path := filepath.Join(t.TempDir(), "report.json")
+	withReport := testRunner(t, config.SyncRule{Name: "photos", ReconcileReport: path}, &fakeBackend{})
+	without := testRunner(t, config.SyncRule{Name: "videos"}, &fakeBackend{})
+	logger, _ := test.NewNullLogger()
+	m := NewManager(withReport.cfg, logger, nil)
+	m.runners = []*ruleRunner{withReport, without}
+	for _, rr := range m.runners {
+		rr.ctx = context.Background()
+	}
+
+	tests := []struct {
+		method, rule string
+		wantCode     int
+	}{
+		{http.MethodGet, "photos", http.StatusMethodNotAllowed},
+		{http.MethodPost, "music", http.StatusNotFound},
+		{http.MethodPost, "videos", http.StatusConflict},
+		{http.MethodPost, "photos", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		m.ReconcileHandler().ServeHTTP(rec, httptest.NewRequest(tt.method, "/status/reconcile?rule="+tt.rule, nil))
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s ?rule=%s = %d, want %d", tt.method, tt.rule, rec.Code, tt.wantCode)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep ReconcileReport
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	want := []ScopeReport{{
+		Src:     withReport.srcRoot,
+		Dst:     "gs://bucket/data",
+		Adds:    []string{"gs://bucket/data/new.txt"},
+		Updates: []string{"gs://bucket/data/changed.txt"},
+		Deletes: []string{"gs://bucket/data/gone.txt"},
+	}}
+	if rep.Rule != "photos" || !reflect.DeepEqual(rep.Scopes, want) {
+		t.Errorf("report %+v, want the photos rule with scopes %+v", rep, want)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %q", matches)
+	}
+}
+
+func TestReconcileReportScopeError(t *testing.T) {
+	fakeGsutil(t, "exit 2\n")
+	rr := testRunner(t, config.SyncRule{ReconcileReport: filepath.Join(t.TempDir(), "report.json")}, &fakeBackend{})
+	rep, err := rr.writeReport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Scopes) != 1 || rep.Scopes[0].Error == "" {
+		t.Errorf("scopes %+v, want the failed dry-run reported", rep.Scopes)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build unix
+
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/config"
+	"os"
+	"testing"
+)
+
+func TestDoSyncRootGuard(t *testing.T) {
+	tests := []struct {
+		name       string
+		euid       int
+		allow      bool
+		appendOnly bool
+		dryRun     bool
+		want       error
+	}{
+		{"user", 1000, false, false, false, nil},
+		{"root", 0, false, false, false, errRootDelete},
+		{"root with allow_root_delete", 0, true, false, false, nil},
+		{"root without deletion", 0, false, true, false, nil},
+		{"root in dry-run", 0, false, false, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			euid := geteuid
+			geteuid = func() int { return tt.euid }
+			t.Cleanup(func() { geteuid = euid })
+			calls := fakeGsutil(t, "exit 0\n") // dry-runs list the changes with gsutil itself
+			b := &fakeBackend{}
+			rr := testRunner(t, config.SyncRule{AppendOnly: tt.appendOnly}, b)
+			rr.cfg.AllowRootDelete, rr.cfg.DryRun = tt.allow, tt.dryRun
+
+			_, err := rr.doSync("test", false, rr.log)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("doSync = %v, want %v", err, tt.want)
+			}
+			if ran := len(b.transfers())+len(calls()) > 0; ran != (tt.want == nil) {
+				t.Errorf("transferred = %v, want %v", ran, tt.want == nil)
+			}
+		})
+	}
+}
+
+// TestRootGuardRealUID runs the guard against the process's actual effective uid.
+func TestRootGuardRealUID(t *testing.T) {
+	b := &fakeBackend{}
+	rr := testRunner(t, config.SyncRule{}, b)
+	rr.cfg.AllowRootDelete = false
+	_, err := rr.doSync("test", false, rr.log)
+	if root := os.Geteuid() == 0; errors.Is(err, errRootDelete) != root {
+		t.Errorf("doSync as uid %d = %v", os.Geteuid(), err)
+	}
+}
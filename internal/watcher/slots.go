@@ -0,0 +1,45 @@
+package watcher
+
+// syncSlots caps how many syncs run at once across every rule (max_concurrent_syncs),
+// so that dozens of rules starting together do not saturate the link with their initial
+// syncs. A nil syncSlots imposes no limit.
+type syncSlots chan struct{}
+
+// newSyncSlots returns the semaphore for n concurrent syncs, or nil for unlimited.
+func newSyncSlots(n int) syncSlots {
+	if n <= 0 {
+		return nil
+	}
+	return make(syncSlots, n)
+}
+
+// syncSlots returns the Manager's shared semaphore for cfg's max_concurrent_syncs,
+// replacing it when the limit changed. m.mu must be held, or the Manager not started.
+func (m *Manager) syncSlots(limit int) syncSlots {
+	if cap(m.slots) != max(limit, 0) {
+		m.slots = newSyncSlots(limit)
+	}
+	return m.slots
+}
+
+// acquireSlot waits for a free sync slot, giving up when the rule is stopped.
+//
+// Returns:
+//   - release: Frees the slot again; call it once the transfer finished.
+//   - ok: false if the rule stopped while waiting, in which case nothing must run.
+func (rr *ruleRunner) acquireSlot() (release func(), ok bool) {
+	if rr.slots == nil {
+		return func() {}, true
+	}
+	select {
+	case rr.slots <- struct{}{}:
+	default:
+		rr.log.Debugf("all %d sync slots (max_concurrent_syncs) busy, waiting", cap(rr.slots))
+		select {
+		case rr.slots <- struct{}{}:
+		case <-rr.ctx.Done():
+			return nil, false
+		}
+	}
+	return func() { <-rr.slots }, true
+}
@@ -0,0 +1,97 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestManagerSyncSlots(t *testing.T) {
+	var m Manager
+	tests := []struct {
+		limit   int
+		wantCap int
+		wantNew bool
+	}{
+		{0, 0, false},
+		{2, 2, true},
+		{2, 2, false},
+		{3, 3, true},
+		{-1, 0, true},
+	}
+	var prev syncSlots
+	for _, tt := range tests {
+		got := m.syncSlots(tt.limit)
+		if cap(got) != tt.wantCap {
+			t.Errorf("syncSlots(%d) has %d slots, want %d", tt.limit, cap(got), tt.wantCap)
+		}
+		if renewed := got != prev; renewed != tt.wantNew {
+			t.Errorf("syncSlots(%d) replaced the semaphore: %v, want %v", tt.limit, renewed, tt.wantNew)
+		}
+		prev = got
+	}
+}
+
+// TestMaxConcurrentSyncs runs several rules sharing two slots, each syncing repeatedly,
+// and checks that no more than two transfers are ever in flight.
+func TestMaxConcurrentSyncs(t *testing.T) {
+	const limit, rules, rounds = 2, 6, 5
+	var active, peak atomic.Int32
+	b := &fakeBackend{rsync: func(ctx context.Context, c fakeCall) error {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}}
+	slots := newSyncSlots(limit)
+	var wg sync.WaitGroup
+	for range rules {
+		rr := testRunner(t, config.SyncRule{}, b)
+		rr.slots = slots
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				rr.syncOnce("debounce")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > limit {
+		t.Errorf("%d transfers ran at once, want at most %d", p, limit)
+	}
+	if n := len(b.transfers()); n != rules*rounds {
+		t.Errorf("%d transfers ran, want %d", n, rules*rounds)
+	}
+	if n := len(slots); n != 0 {
+		t.Errorf("%d slots still held after every sync finished", n)
+	}
+}
+
+func TestAcquireSlotStopped(t *testing.T) {
+	rr := testRunner(t, config.SyncRule{}, &fakeBackend{})
+	rr.slots = newSyncSlots(1)
+	rr.slots <- struct{}{} // taken by another rule
+	ctx, cancel := context.WithCancel(context.Background())
+	rr.ctx = ctx
+	done := make(chan bool)
+	go func() {
+		_, ok := rr.acquireSlot()
+		done <- ok
+	}()
+	cancel()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("acquireSlot succeeded with every slot taken")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquireSlot kept waiting after the rule stopped")
+	}
+}
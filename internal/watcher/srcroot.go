@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"os"
+	"time"
+)
+
+// Backoff bounds for re-checking a source root that disappeared at runtime.
+const (
+	rootRetryMin = time.Second
+	rootRetryMax = time.Minute
+)
+
+// rootMissing reports whether the rule's source root is gone, e.g. deleted or unmounted
+// while running. Syncing a missing root would let a deleting push wipe the destination,
+// so blocked refuses to sync until it is back.
+//
+// The first call noticing the loss logs it and signals rootGone, on which the run loop
+// starts re-checking with backoff (see rootBack).
+func (rr *ruleRunner) rootMissing() bool {
+	if fi, err := os.Stat(rr.srcRoot); err == nil && fi.IsDir() {
+		return false
+	}
+	if !rr.rootLost.Swap(true) {
+		rr.log.Warnf("source root %s disappeared, pausing syncs until it is back", rr.srcRoot)
+		select {
+		case rr.rootGone <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// rootBack re-checks a lost source root and clears the loss once it is a directory again.
+func (rr *ruleRunner) rootBack() bool {
+	if fi, err := os.Stat(rr.srcRoot); err != nil || !fi.IsDir() {
+		return false
+	}
+	rr.rootLost.Store(false)
+	rr.log.Infof("source root %s is back, resuming", rr.srcRoot)
+	return true
+}
+
+// timerC returns the channel of t, or nil (blocking forever) when t is nil.
+func timerC(t *time.Timer) <-chan time.Time {
+	if t != nil {
+		return t.C
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRootMissing(t *testing.T) {
+	rr := testRunner(t, config.SyncRule{}, &fakeBackend{})
+	if rr.rootMissing() || rr.rootLost.Load() {
+		t.Fatal("an existing root counts as missing")
+	}
+	if err := os.Remove(rr.srcRoot); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if !rr.rootMissing() {
+			t.Fatal("a removed root does not count as missing")
+		}
+	}
+	// the loss is only signalled once
+	<-rr.rootGone
+	select {
+	case <-rr.rootGone:
+		t.Error("the loss was signalled twice")
+	default:
+	}
+	if got := rr.blocked(rr.log); got != "source root missing" {
+		t.Errorf("blocked = %q, want source root missing", got)
+	}
+
+	// a file in its place is no root either
+	if err := os.WriteFile(rr.srcRoot, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if rr.rootBack() {
+		t.Error("rootBack accepted a file as the root")
+	}
+	if err := os.Remove(rr.srcRoot); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(rr.srcRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if !rr.rootBack() || rr.rootLost.Load() || rr.blocked(rr.log) != "" {
+		t.Error("the recreated root was not taken back")
+	}
+}
+
+// TestRootLossAndRecreation removes a running rule's source root, checks that nothing is
+// synced while it is gone, and that a sync runs once it is recreated.
+func TestRootLossAndRecreation(t *testing.T) {
+	b := &fakeBackend{}
+	rr := testRunner(t, config.SyncRule{}, b)
+	if err := os.WriteFile(filepath.Join(rr.srcRoot, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := startRunner(context.Background(), rr, stop)
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	eventually(t, "the initial sync", func() bool { return len(b.transfers()) == 1 })
+
+	if err := os.RemoveAll(rr.srcRoot); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the loss to be noticed", rr.rootLost.Load)
+	// the removal of a.txt was debounced, but must not be pushed
+	time.Sleep(10 * rr.rule.DebounceWindow)
+	if n := len(b.transfers()); n != 1 {
+		t.Fatalf("%d transfers while the root was gone, want none after the initial one", n)
+	}
+
+	if err := os.Mkdir(rr.srcRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "a sync of the restored root", func() bool { return len(b.transfers()) == 2 })
+	if rr.rootLost.Load() {
+		t.Error("the root still counts as lost")
+	}
+	// and it is watched again
+	if err := os.WriteFile(filepath.Join(rr.srcRoot, "b.txt"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "a sync of the new file", func() bool { return len(b.transfers()) == 3 })
+}
@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"gcs_sync/internal/metrics"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// runnerStats collects per-rule activity counters for the shutdown summary.
+type runnerStats struct {
+	mu       sync.Mutex
+	started  time.Time
+	syncs    int
+	failures int
+	lastErr  error
+	lastSync time.Time // when the most recent sync attempt finished; zero before any
+	latency  metrics.Histogram
+}
+
+// record counts one completed sync attempt, its duration and whether it failed.
+// It returns the updated totals of syncs and failures.
+func (s *runnerStats) record(d time.Duration, err error) (syncs, failures int) {
+	s.latency.Observe(d)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncs++
+	if err != nil {
+		s.failures++
+	}
+	s.lastErr, s.lastSync = err, time.Now()
+	return s.syncs, s.failures
+}
+
+// lastError returns the error of the most recent sync attempt, nil if it succeeded.
+func (s *runnerStats) lastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// sinceLastSync returns the time since the most recent sync attempt finished, or since
+// the rule started when it has not synced yet.
+func (s *runnerStats) sinceLastSync() (since time.Duration, synced bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastSync.IsZero() {
+		return time.Since(s.started), false
+	}
+	return time.Since(s.lastSync), true
+}
+
+// fields returns the counters as log fields.
+func (s *runnerStats) fields() logrus.Fields {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := logrus.Fields{
+		"syncs":    s.syncs,
+		"failures": s.failures,
+		"uptime":   time.Since(s.started).Round(time.Second).String(),
+	}
+	if s.syncs > 0 {
+		p := s.latency.Percentiles()
+		f["p50"], f["p95"], f["p99"] = p.P50.String(), p.P95.String(), p.P99.String()
+	}
+	return f
+}
+
+// logSummary writes a one-line report of the rule's activity since it started.
+func (rr *ruleRunner) logSummary() {
+	rr.log.WithFields(rr.stats.fields()).Info("rule summary")
+}
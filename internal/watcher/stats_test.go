@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"errors"
+	"gcs_sync/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"testing"
+	"time"
+)
+
+func TestRunnerStats(t *testing.T) {
+	s := runnerStats{started: time.Now().Add(-time.Hour)}
+	if since, synced := s.sinceLastSync(); synced || since < time.Hour {
+		t.Errorf("sinceLastSync before any sync = %s, %v, want the uptime", since, synced)
+	}
+	if f := s.fields(); f["syncs"] != 0 || f["p50"] != nil {
+		t.Errorf("fields before any sync = %v, want no syncs and no percentiles", f)
+	}
+
+	steps := []struct {
+		d            time.Duration
+		err          error
+		wantSyncs    int
+		wantFailures int
+	}{
+		{15 * time.Millisecond, nil, 1, 0},
+		{15 * time.Millisecond, errors.New("boom"), 2, 1},
+		{15 * time.Millisecond, nil, 3, 1},
+	}
+	for i, st := range steps {
+		syncs, failures := s.record(st.d, st.err)
+		if syncs != st.wantSyncs || failures != st.wantFailures {
+			t.Errorf("record %d = %d, %d, want %d, %d", i, syncs, failures, st.wantSyncs, st.wantFailures)
+		}
+		if got := s.lastError(); got != st.err {
+			t.Errorf("record %d: lastError = %v, want %v", i, got, st.err)
+		}
+	}
+	if since, synced := s.sinceLastSync(); !synced || since > time.Minute {
+		t.Errorf("sinceLastSync = %s, %v, want the time since the last record", since, synced)
+	}
+	want := logrus.Fields{"syncs": 3, "failures": 1, "uptime": "1h0m0s", "p50": "15ms", "p95": "15ms", "p99": "15ms"}
+	got := s.fields()
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("fields[%s] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestLogSummary(t *testing.T) {
+	rr := testRunner(t, config.SyncRule{}, &fakeBackend{})
+	logger, hook := test.NewNullLogger()
+	rr.log = logger.WithField("rule", "r")
+	rr.stats.started = time.Now()
+	rr.syncOnce("test")
+	rr.logSummary()
+	e := hook.LastEntry()
+	if e == nil || e.Message != "rule summary" || e.Data["syncs"] != 1 || e.Data["failures"] != 0 || e.Data["rule"] != "r" {
+		t.Errorf("summary = %+v", e)
+	}
+}
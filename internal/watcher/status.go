@@ -0,0 +1,120 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"gcs_sync/internal/metrics"
+	"net/http"
+)
+
+// Watched returns, per rule ID, the directories its watcher currently tracks.
+func (m *Manager) Watched() map[string][]string {
+	runners := m.snapshot()
+	res := make(map[string][]string, len(runners))
+	for _, rr := range runners {
+		res[rr.rule.ID()] = rr.watches.list()
+	}
+	return res
+}
+
+// WatchedHandler serves Watched as JSON. A `rule` query parameter limits the
+// response to a single rule.
+func (m *Manager) WatchedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := m.Watched()
+		if id := r.URL.Query().Get("rule"); id != "" {
+			dirs, ok := res[id]
+			if !ok {
+				http.Error(w, "unknown rule", http.StatusNotFound)
+				return
+			}
+			res = map[string][]string{id: dirs}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+// Latencies returns, per rule ID, the sync latency percentiles recorded since start.
+func (m *Manager) Latencies() map[string]metrics.Percentiles {
+	runners := m.snapshot()
+	res := make(map[string]metrics.Percentiles, len(runners))
+	for _, rr := range runners {
+		res[rr.rule.ID()] = rr.stats.latency.Percentiles()
+	}
+	return res
+}
+
+// LatencyHandler serves Latencies as JSON, with durations formatted like "1.5s".
+// A `rule` query parameter limits the response to a single rule.
+func (m *Manager) LatencyHandler() http.Handler {
+	type entry struct {
+		Count uint64 `json:"count"`
+		P50   string `json:"p50"`
+		P95   string `json:"p95"`
+		P99   string `json:"p99"`
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := make(map[string]entry)
+		for id, p := range m.Latencies() {
+			res[id] = entry{p.Count, p.P50.String(), p.P95.String(), p.P99.String()}
+		}
+		if id := r.URL.Query().Get("rule"); id != "" {
+			e, ok := res[id]
+			if !ok {
+				http.Error(w, "unknown rule", http.StatusNotFound)
+				return
+			}
+			res = map[string]entry{id: e}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	})
+}
+
+// ConfirmHandler releases a paused initial sync (initial_confirm) for the rule named by
+// the `rule` query parameter. Only POST is accepted.
+func (m *Manager) ConfirmHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := m.Confirm(r.URL.Query().Get("rule")); err != nil {
+			code := http.StatusConflict
+			if errors.Is(err, errUnknownRule) {
+				code = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ReconcileHandler writes the reconciliation report of the rule named by the `rule`
+// query parameter and serves it as JSON. Only POST is accepted.
+func (m *Manager) ReconcileHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rep, err := m.Reconcile(r.URL.Query().Get("rule"))
+		switch {
+		case errors.Is(err, errNoReport):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, errUnknownRule):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rep)
+	})
+}
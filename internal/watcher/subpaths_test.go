@@ -0,0 +1,78 @@
+package watcher
+
+import (
+	"gcs_sync/internal/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScopes(t *testing.T) {
+	b := &fakeBackend{}
+	whole := testRunner(t, config.SyncRule{}, b)
+	if got, want := whole.scopes(), []scope{{src: whole.srcRoot, dst: "gs://bucket/data"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scopes without subpaths = %+v, want %+v", got, want)
+	}
+
+	src := t.TempDir()
+	for _, d := range []string{"photos/raw", "docs"} {
+		if err := os.MkdirAll(filepath.Join(src, filepath.FromSlash(d)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rr := testRunner(t, config.SyncRule{Src: src, Subpaths: []string{"photos/raw/", "./docs"}}, b)
+	want := []scope{
+		{src: filepath.Join(src, "photos", "raw"), dst: "gs://bucket/data/photos/raw"},
+		{src: filepath.Join(src, "docs"), dst: "gs://bucket/data/docs"},
+	}
+	if got := rr.scopes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("scopes = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		rel       string
+		want      string
+		wantScope bool
+	}{
+		{"photos/raw", ".", true},
+		{"photos/raw/a.jpg", "a.jpg", true},
+		{"docs/x/y.pdf", "x/y.pdf", true},
+		{"photos", "photos", false},
+		{"photos/rawer/a.jpg", "photos/rawer/a.jpg", false},
+		{"other.txt", "other.txt", false},
+	}
+	for _, tt := range tests {
+		got, inScope := rr.scopeRel(tt.rel)
+		if got != tt.want || inScope != tt.wantScope {
+			t.Errorf("scopeRel(%q) = %q, %v, want %q, %v", tt.rel, got, inScope, tt.want, tt.wantScope)
+		}
+	}
+}
+
+// TestSubpathsSync checks that a sync of a rule with subpaths transfers each of them to
+// the matching destination prefix.
+func TestSubpathsSync(t *testing.T) {
+	src := t.TempDir()
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(src, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := &fakeBackend{}
+	rr := testRunner(t, config.SyncRule{Src: src, Subpaths: []string{"a", "b"}}, b)
+	if !rr.syncOnce("test") {
+		t.Fatal("sync failed")
+	}
+	var got [][2]string
+	for _, c := range b.transfers() {
+		got = append(got, [2]string{c.src, c.dst})
+	}
+	want := [][2]string{
+		{filepath.Join(src, "a"), "gs://bucket/data/a"},
+		{filepath.Join(src, "b"), "gs://bucket/data/b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transferred %q, want %q", got, want)
+	}
+}
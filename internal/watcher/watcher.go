@@ -2,60 +2,287 @@ package watcher
 
 import (
 	"context"
+	"fmt"
+	"gcs_sync/internal/audit"
 	"gcs_sync/internal/config"
+	"gcs_sync/internal/filter"
+	"gcs_sync/internal/limits"
+	"gcs_sync/internal/metrics"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/fx"
 	"sync"
+	"time"
 )
 
+// Manager owns the rule runners for a configuration.
+//
+// Besides driving the daemon, it is the entry point for embedding gcs-sync: hooks set
+// on the exported fields customise individual rules before Start is called. The
+// configuration need not come from a file; a Config literal or config.Parse works as well.
+type Manager struct {
+	// DstMappers overrides where files of a rule are transferred to, keyed by rule ID
+	// (see config.SyncRule.ID).
+	DstMappers map[string]DstMapper
+	// NetworkGate reports metered connections to rules with pause_when_metered. When nil,
+	// the global metered_command is used if set, and NoGate otherwise.
+	NetworkGate NetworkGate
+	// Callbacks are invoked for the activity of every rule.
+	Callbacks Callbacks
+	// OpenDetector finds files open for writing for rules with skip_open_files. When nil,
+	// the platform default is used (Linux only).
+	OpenDetector filter.OpenDetector
+	// Since, when positive, overrides every rule's exclude_older_than: pushes only consider
+	// files modified within it (sync --since).
+	Since time.Duration
+
+	cfg     *config.Config
+	log     *logrus.Logger
+	rec     metrics.Recorder
+	audit   *audit.Log
+	gate    NetworkGate
+	ctx     context.Context // cancelled when the shutdown deadline passes
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex // guards cfg, runners, started and stopped once started
+	runners []*ruleRunner
+	started bool
+	stopped bool
+	slots   syncSlots // shared by the runners; see syncSlots
+}
+
+// NewManager creates a Manager for the enabled rules of cfg. Nothing runs until Start.
+//
+// Parameters:
+//   - cfg: A pointer to the config.Config struct containing synchronization rules and settings.
+//   - log: A pointer to a logrus.Logger for logging errors and other information.
+//   - rec: The metrics.Recorder receiving sync events.
+//
+// Returns:
+//   - *Manager: The new, idle manager.
+func NewManager(cfg *config.Config, log *logrus.Logger, rec metrics.Recorder) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		DstMappers: make(map[string]DstMapper),
+		cfg:        cfg,
+		log:        log,
+		rec:        rec,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start creates a runner for every enabled rule and launches its watcher in the background.
+//
+// Returns:
+//   - error: An error if the configuration is invalid or a rule could not be prepared; no
+//     watchers are started in that case.
+func (m *Manager) Start() error {
+	// configurations built in code skip config.Load and its validation
+	if err := m.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if _, err := limits.EnsureOpenFiles(m.cfg.MinOpenFiles, m.log.WithField("check", "min_open_files")); err != nil {
+		m.log.WithError(err).Warn("failed to read the open file limit")
+	}
+	gate := m.networkGate()
+	auditLog, err := audit.Open(m.cfg.AuditLog)
+	if err != nil {
+		return fmt.Errorf("audit_log: %w", err)
+	}
+	if err := m.prepare(gate, auditLog); err != nil {
+		_ = auditLog.Close()
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit, m.gate = auditLog, gate
+	for _, rr := range m.runners {
+		m.launch(rr)
+	}
+	m.started = true
+	return nil
+}
+
+// networkGate returns NetworkGate, falling back to metered_command and then to NoGate.
+func (m *Manager) networkGate() NetworkGate {
+	if m.NetworkGate != nil {
+		return m.NetworkGate
+	}
+	if m.cfg.MeteredCommand != "" {
+		return CommandGate{Command: m.cfg.MeteredCommand, Log: m.log.WithField("hook", "metered_command")}
+	}
+	return NoGate{}
+}
+
+// launch runs the watcher of rr in the background until halt or Stop.
+func (m *Manager) launch(rr *ruleRunner) {
+	ctx, kill := context.WithCancel(m.ctx)
+	rr.quit, rr.kill, rr.done = make(chan struct{}), kill, make(chan struct{})
+	// halt kills ctx before the watcher stops; the shutdown work only ends with Stop's deadline
+	rr.shutdown = m.ctx
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(rr.done)
+		defer kill()
+		if err := rr.run(ctx, rr.quit); err != nil {
+			rr.log.WithError(err).Error("watcher stopped with error")
+		}
+	}()
+}
+
+// halt stops the watcher of a single rule, killing its in-flight transfer even with
+// flush_on_shutdown or drain_on_shutdown, and waits for it to exit.
+func (m *Manager) halt(rr *ruleRunner) {
+	close(rr.quit)
+	rr.kill()
+	<-rr.done
+	rr.logSummary()
+	rr.closeLog()
+}
+
+// snapshot returns the current runners; Reload may replace them at any time.
+func (m *Manager) snapshot() []*ruleRunner {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*ruleRunner(nil), m.runners...)
+}
+
+// prepare creates the runners of the enabled rules.
+//
+// Parameters:
+//   - gate: The network gate for rules with pause_when_metered.
+//   - auditLog: The audit log deletions are recorded in; nil disables auditing.
+//
+// Returns:
+//   - error: An error naming the first rule that could not be prepared; no runner is kept
+//     in that case.
+func (m *Manager) prepare(gate NetworkGate, auditLog *audit.Log) error {
+	for _, r := range m.cfg.Sync {
+		if !r.Enabled {
+			continue
+		}
+		runner, err := m.newRunner(m.cfg, r, gate, auditLog)
+		if err != nil {
+			// the rules prepared so far never run; release their log files
+			for _, rr := range m.runners {
+				rr.closeLog()
+			}
+			m.runners = nil
+			return err
+		}
+		m.runners = append(m.runners, runner)
+	}
+	return nil
+}
+
+// newRunner creates the runner of an enabled rule and applies the Manager's hooks to it.
+//
+// Parameters:
+//   - cfg: The configuration the rule belongs to.
+//   - r: The rule.
+//   - gate: The network gate for rules with pause_when_metered.
+//   - auditLog: The audit log deletions are recorded in; nil disables auditing.
+//
+// Returns:
+//   - *ruleRunner: The idle runner.
+//   - error: An error naming the rule if it could not be prepared.
+func (m *Manager) newRunner(cfg *config.Config, r config.SyncRule, gate NetworkGate, auditLog *audit.Log) (*ruleRunner, error) {
+	if m.Since > 0 {
+		r.ExcludeOlderThan = m.Since
+	}
+	runner, err := newRuleRunner(cfg, r)
+	if err != nil {
+		return nil, err
+	}
+	runner.audit = auditLog
+	runner.mapper = m.DstMappers[r.ID()]
+	if runner.mapper != nil && runner.pulls() {
+		return nil, fmt.Errorf("rule %q: a destination mapper only supports local_to_remote", r.ID())
+	}
+	if runner.mapper != nil && r.ImmediateDeletes {
+		return nil, fmt.Errorf("rule %q: immediate_deletes does not support destination mappers", r.ID())
+	}
+	if runner.mapper != nil && r.DriftCheck > 0 {
+		return nil, fmt.Errorf("rule %q: drift_check does not support destination mappers", r.ID())
+	}
+	runner.rec = m.rec
+	runner.callbacks = m.Callbacks
+	runner.slots = m.syncSlots(cfg.MaxConcurrentSyncs)
+	if r.SkipOpenFiles {
+		if runner.openFiles = m.OpenDetector; runner.openFiles == nil {
+			if runner.openFiles, err = filter.NewOpenDetector(); err != nil {
+				return nil, fmt.Errorf("rule %q: skip_open_files: %w", r.ID(), err)
+			}
+		}
+	}
+	if r.PauseWhenMetered {
+		runner.gate = gate
+	}
+	// opened last, so a rule failing above leaves no file open
+	if err := runner.logFile.Open(); err != nil {
+		return nil, fmt.Errorf("rule %q: log_file: %w", r.ID(), err)
+	}
+	return runner, nil
+}
+
+// Stop signals every watcher to stop and waits for them to exit.
+// In-flight transfers are killed right away, except for rules with flush_on_shutdown or
+// drain_on_shutdown, which may finish until ctx expires. Once every watcher has stopped, a
+// per-rule activity summary is logged.
+//
+// Parameters:
+//   - ctx: Bounds how long to wait for the watchers.
+//
+// Returns:
+//   - error: ctx.Err() if the watchers did not stop in time, nil otherwise.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopped = true
+	for _, rr := range m.runners {
+		if rr.quit != nil { // nil when Start failed
+			close(rr.quit)
+		}
+	}
+	m.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		// kill transfers still running, e.g. a flush_on_shutdown or drain_on_shutdown sync; lines the
+		// watchers still log are dropped from the closed files
+		m.cancel()
+		for _, rr := range m.snapshot() {
+			rr.closeLog()
+		}
+		_ = m.audit.Close()
+		return ctx.Err()
+	case <-done:
+		m.cancel()
+		for _, rr := range m.snapshot() {
+			rr.logSummary()
+			rr.closeLog()
+		}
+		return m.audit.Close()
+	}
+}
+
 // StartAll initializes and manages watchers for all enabled synchronization rules.
 // It sets up watchers to start when the application begins and ensures they stop
 // gracefully when the application shuts down.
 //
 // Parameters:
 //   - lc: An fx.Lifecycle instance used to register start and stop hooks for the watchers.
-//   - cfg: A pointer to the config.Config struct containing synchronization rules and settings.
-//   - log: A pointer to a logrus.Logger for logging errors and other information.
+//   - m: The Manager holding the configuration and rule runners.
 //
 // This function doesn't return any value, but it sets up the necessary hooks for
 // starting and stopping the watchers as part of the application's lifecycle.
-func StartAll(lc fx.Lifecycle, cfg *config.Config, log *logrus.Logger) {
-	stop := make(chan struct{})
-	var wg sync.WaitGroup
-
+func StartAll(lc fx.Lifecycle, m *Manager) {
 	lc.Append(fx.Hook{
-		OnStart: func(ctx context.Context) error {
-			for _, r := range cfg.Sync {
-				if !r.Enabled {
-					continue
-				}
-				runner, err := newRuleRunner(r)
-				if err != nil {
-					return err
-				}
-				wg.Add(1)
-				go func(rr *ruleRunner) {
-					defer wg.Done()
-					if err := rr.run(stop); err != nil {
-						log.WithError(err).Error("watcher stopped with error")
-					}
-				}(runner)
-			}
-			return nil
-		},
-		OnStop: func(ctx context.Context) error {
-			close(stop)
-			done := make(chan struct{})
-			go func() {
-				wg.Wait()
-				close(done)
-			}()
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-done:
-				return nil
-			}
-		},
+		OnStart: func(ctx context.Context) error { return m.Start() },
+		OnStop:  m.Stop,
 	})
 }
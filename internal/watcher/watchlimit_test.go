@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"context"
+	"gcs_sync/internal/config"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// limitWatches makes the kernel refuse more than n watches for the rest of the test.
+func limitWatches(t *testing.T, n int) {
+	var mu sync.Mutex
+	added := 0
+	old := addWatch
+	addWatch = func(w *fsnotify.Watcher, dir string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if added == n {
+			return syscall.ENOSPC
+		}
+		added++
+		return old(w, dir)
+	}
+	t.Cleanup(func() { addWatch = old })
+}
+
+// TestWatchLimit runs rules over a tree with more directories than the watch limit allows
+// and checks that the walk keeps going, the shortfall is reported once and pushing rules
+// fall back to periodic syncs.
+func TestWatchLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		dirs         []config.SyncDirection
+		wantFallback bool
+	}{
+		{"push", nil, true},
+		{"pull only", []config.SyncDirection{config.RemoteToLocal}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			for _, d := range []string{"a", "b", "c/d"} {
+				if err := os.MkdirAll(filepath.Join(src, filepath.FromSlash(d)), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			limitWatches(t, 3)
+			rr := testRunner(t, config.SyncRule{Src: src, Directions: tt.dirs, RemotePollWindow: time.Hour}, &fakeBackend{})
+			logger, hook := test.NewNullLogger()
+			rr.log = logrus.NewEntry(logger)
+			stop := make(chan struct{})
+			done := startRunner(context.Background(), rr, stop)
+			eventually(t, "the initial sync", rr.initialDone.Load)
+			close(stop)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			if watched, skipped := rr.watches.counts(); watched != 3 || skipped != 2 {
+				t.Errorf("watching %d directories, %d skipped, want 3 and 2", watched, skipped)
+			}
+			var warnings []string
+			for _, e := range hook.AllEntries() {
+				if e.Level == logrus.WarnLevel {
+					warnings = append(warnings, e.Message)
+				}
+			}
+			if len(warnings) == 0 || !strings.HasPrefix(warnings[0], "inotify watch limit reached: watching 3 directories, 2 skipped") {
+				t.Errorf("warnings %q, want the watch limit reported", warnings)
+			}
+			fallback := 0
+			for _, w := range warnings[1:] {
+				if strings.Contains(w, "syncing every 5m0s as a fallback") {
+					fallback++
+				}
+			}
+			if (fallback == 1) != tt.wantFallback || len(warnings) != 1+fallback {
+				t.Errorf("warnings %q, want the limit reported once and a fallback %v", warnings, tt.wantFallback)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package watcher
+
+import (
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// errWatchLimit reports that a directory could not be watched because the inotify watch
+// limit is exhausted.
+var errWatchLimit = errors.New("inotify watch limit reached")
+
+// addWatch adds a kernel watch for dir; replaceable for tests.
+var addWatch = func(w *fsnotify.Watcher, dir string) error { return w.Add(dir) }
+
+// watchSet wraps an fsnotify.Watcher and remembers which directories it watches,
+// so the set can be reported and pruned when directories disappear.
+type watchSet struct {
+	mu      sync.Mutex
+	w       *fsnotify.Watcher
+	dirs    map[string]struct{}
+	skipped int // directories not watched because the inotify watch limit was reached
+}
+
+// newWatchSet returns an empty set; attach connects it to a watcher.
+func newWatchSet() *watchSet {
+	return &watchSet{dirs: make(map[string]struct{})}
+}
+
+// attach starts tracking watches of w, forgetting anything recorded before.
+func (ws *watchSet) attach(w *fsnotify.Watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.w = w
+	ws.dirs = make(map[string]struct{})
+	ws.skipped = 0
+}
+
+// Add watches dir and records it. When the kernel refuses more watches (ENOSPC, see
+// fs.inotify.max_user_watches), dir is counted as skipped and errWatchLimit returned.
+func (ws *watchSet) Add(dir string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if err := addWatch(ws.w, dir); err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			ws.skipped++
+			return errWatchLimit
+		}
+		return err
+	}
+	ws.dirs[dir] = struct{}{}
+	return nil
+}
+
+// forget drops dir and everything below it from the set. fsnotify removes the
+// kernel watches of deleted directories by itself.
+func (ws *watchSet) forget(dir string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	prefix := dir + string(filepath.Separator)
+	for d := range ws.dirs {
+		if d == dir || strings.HasPrefix(d, prefix) {
+			delete(ws.dirs, d)
+		}
+	}
+}
+
+// counts returns the number of watched directories and of those skipped at the watch limit.
+func (ws *watchSet) counts() (watched, skipped int) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return len(ws.dirs), ws.skipped
+}
+
+// has reports whether dir is watched, i.e. a known directory of the tree.
+func (ws *watchSet) has(dir string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	_, ok := ws.dirs[dir]
+	return ok
+}
+
+// list returns the watched directories in sorted order.
+func (ws *watchSet) list() []string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	res := make([]string, 0, len(ws.dirs))
+	for d := range ws.dirs {
+		res = append(res, d)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,121 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"gcs_sync/internal/config"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestWatchedDirs runs a rule and follows the directories it watches as the tree changes,
+// through the status API.
+func TestWatchedDirs(t *testing.T) {
+	src := t.TempDir()
+	for _, d := range []string{"a/b", "node_modules/x"} {
+		if err := os.MkdirAll(filepath.Join(src, filepath.FromSlash(d)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rr := testRunner(t, config.SyncRule{Name: "photos", Src: src, Ignore: []string{"node_modules"}}, &fakeBackend{})
+	logger, _ := test.NewNullLogger()
+	m := NewManager(rr.cfg, logger, nil)
+	m.runners = []*ruleRunner{rr}
+
+	get := func(query string) (int, map[string][]string) {
+		rec := httptest.NewRecorder()
+		m.WatchedHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status/watched"+query, nil))
+		var res map[string][]string
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, res
+	}
+	watching := func(dirs ...string) func() bool {
+		want := []string{rr.srcRoot}
+		for _, d := range dirs {
+			want = append(want, filepath.Join(rr.srcRoot, filepath.FromSlash(d)))
+		}
+		return func() bool {
+			_, res := get("")
+			return reflect.DeepEqual(res, map[string][]string{"photos": want})
+		}
+	}
+
+	stop := make(chan struct{})
+	done := startRunner(context.Background(), rr, stop)
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	eventually(t, "the initial directories, without ignored ones", watching("a", "a/b"))
+	if err := os.MkdirAll(filepath.Join(rr.srcRoot, "c", "d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "a new directory tree", watching("a", "a/b", "c", "c/d"))
+	if err := os.RemoveAll(filepath.Join(rr.srcRoot, "a")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "a removed directory tree to be forgotten", watching("c", "c/d"))
+
+	if code, res := get("?rule=photos"); code != http.StatusOK || len(res) != 1 {
+		t.Errorf("?rule=photos = %d %v, want the one rule", code, res)
+	}
+	if code, _ := get("?rule=videos"); code != http.StatusNotFound {
+		t.Errorf("?rule=videos = %d, want 404", code)
+	}
+}
+
+// TestIgnoredDirsNotWatched walks a tree with the rule's ignore patterns and checks that
+// ignored directories and everything below them get no watch, scoped to subpaths.
+func TestIgnoredDirsNotWatched(t *testing.T) {
+	tests := []struct {
+		name     string
+		ignore   []string
+		subpaths []string
+		root     string
+		want     []string
+	}{
+		{"no patterns", nil, nil, ".", []string{".", "a", "a/cache", "a/cache/x", "build", "build/out", "keep", "keep/cache"}},
+		{"ignored trees", []string{"build", "**/cache"}, nil, ".", []string{".", "a", "keep"}},
+		{"negated", []string{"**/cache", "!keep/cache"}, nil, ".", []string{".", "a", "build", "build/out", "keep", "keep/cache"}},
+		{"ignored root of a new tree", []string{"build"}, nil, "build", nil},
+		// patterns are relative to the subpath, and its parents are never ignored
+		{"subpath", []string{"cache", "a"}, []string{"a"}, ".", []string{".", "a", "build", "build/out", "keep", "keep/cache"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			for _, d := range []string{"a/cache/x", "build/out", "keep/cache"} {
+				if err := os.MkdirAll(filepath.Join(src, filepath.FromSlash(d)), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rr := testRunner(t, config.SyncRule{Src: src, Ignore: tt.ignore, Subpaths: tt.subpaths}, &fakeBackend{})
+			w, err := fsnotify.NewWatcher()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			rr.watches.attach(w)
+			if err := addRecursive(rr.watches, filepath.Join(rr.srcRoot, filepath.FromSlash(tt.root)), false, rr.ignoredDir); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{}
+			for _, d := range tt.want {
+				want = append(want, filepath.Join(rr.srcRoot, filepath.FromSlash(d)))
+			}
+			if got := rr.watches.list(); !reflect.DeepEqual(got, want) {
+				t.Errorf("watching %q, want %q", got, want)
+			}
+		})
+	}
+}